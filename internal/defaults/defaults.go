@@ -1,6 +1,8 @@
 package defaults
 
 const (
-	InProgressLabelKey   = "rollouts.argoproj.io/gatewayapi-canary"
-	InProgressLabelValue = "in-progress"
+	InProgressLabelKey        = "rollouts.argoproj.io/gatewayapi-canary"
+	InProgressLabelValue      = "in-progress"
+	OriginalSpecAnnotationKey = "rollouts.argoproj.io/gatewayapi-original-spec"
+	FieldManager              = "argo-rollouts-gatewayapi-plugin"
 )
@@ -25,19 +25,35 @@ func main() {
 	kubeClientQPS := flag.Int("kubeClientQPS", 5, "The QPS to use for the Kubernetes client.")
 	kubeClientBurst := flag.Int("kubeClientBurst", 10, "The Burst to use for the Kubernetes client.")
 	logFormat := flag.String("logformat", "text", "Set the logging format. One of: text|json")
+	enableInformerCache := flag.Bool("enableInformerCache", false, "Serve route and Service reads from shared informers instead of the API server.")
+	informerNamespace := flag.String("informerNamespace", "", "Restrict the informer cache to a single namespace. Defaults to all namespaces.")
+	dryRun := flag.Bool("dry-run", false, "Log the changes the plugin would make to routes instead of writing them.")
+	metricsAddr := flag.String("metricsAddr", "", "The address the Prometheus metrics endpoint binds to, e.g. :8090. Metrics are disabled if empty.")
 	flag.Parse()
 
 	// Create the plugin implementation, injecting command line options:
 	rpcPluginImp := &plugin.RpcPlugin{
 		CommandLineOpts: plugin.CommandLineOpts{
-			KubeClientQPS:   float32(*kubeClientQPS),
-			KubeClientBurst: *kubeClientBurst,
+			KubeClientQPS:       float32(*kubeClientQPS),
+			KubeClientBurst:     *kubeClientBurst,
+			EnableInformerCache: *enableInformerCache,
+			InformerNamespace:   *informerNamespace,
+			DryRun:              *dryRun,
 		},
 		LogCtx: utils.SetupLog(*logFormat),
 	}
 
+	if *metricsAddr != "" {
+		go func() {
+			rpcPluginImp.LogCtx.Infof("Serving metrics on %s", *metricsAddr)
+			if err := plugin.ServeMetrics(*metricsAddr); err != nil {
+				rpcPluginImp.LogCtx.Errorf("Metrics server stopped: %v", err)
+			}
+		}()
+	}
+
 	pluginMap := map[string]goPlugin.Plugin{
-		"RpcTrafficRouterPlugin": &rolloutsPlugin.RpcTrafficRouterPlugin{Impl: rpcPluginImp},
+		"RpcTrafficRouterPlugin": &rolloutsPlugin.RpcTrafficRouterPlugin{Impl: &plugin.InstrumentedRpcPlugin{RpcPlugin: rpcPluginImp}},
 	}
 
 	goPlugin.Serve(&goPlugin.ServeConfig{
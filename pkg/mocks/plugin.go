@@ -12,12 +12,14 @@ const (
 	HTTPRoute         = "HTTPRoute"
 	TCPRoute          = "TCPRoute"
 	TLSRoute          = "TLSRoute"
+	UDPRoute          = "UDPRoute"
 	StableServiceName = "argo-rollouts-stable-service"
 	CanaryServiceName = "argo-rollouts-canary-service"
 	HTTPRouteName     = "argo-rollouts-http-route"
 	GRPCRouteName     = "argo-rollouts-grpc-route"
 	TCPRouteName      = "argo-rollouts-tcp-route"
 	TLSRouteName      = "argo-rollouts-tls-route"
+	UDPRouteName      = "argo-rollouts-udp-route"
 	RolloutNamespace  = "default"
 	ManagedRouteName  = "test-header-route"
 )
@@ -220,6 +222,40 @@ func CreateTLSRouteWithLabels(name string, labels map[string]string) *v1alpha2.T
 	}
 }
 
+func CreateUDPRouteWithLabels(name string, labels map[string]string) *v1alpha2.UDPRoute {
+	stableWeight := int32(100)
+	canaryWeight := int32(0)
+	return &v1alpha2.UDPRoute{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: RolloutNamespace,
+			Labels:    labels,
+		},
+		Spec: v1alpha2.UDPRouteSpec{
+			Rules: []v1alpha2.UDPRouteRule{
+				{
+					BackendRefs: []v1alpha2.BackendRef{
+						{
+							BackendObjectReference: v1alpha2.BackendObjectReference{
+								Name: StableServiceName,
+								Port: &port,
+							},
+							Weight: &stableWeight,
+						},
+						{
+							BackendObjectReference: v1alpha2.BackendObjectReference{
+								Name: CanaryServiceName,
+								Port: &port,
+							},
+							Weight: &canaryWeight,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
 var GRPCRouteObj = gatewayv1.GRPCRoute{
 	ObjectMeta: metav1.ObjectMeta{
 		Name:      GRPCRouteName,
@@ -310,3 +346,32 @@ var TLSRouteObj = v1alpha2.TLSRoute{
 		},
 	},
 }
+
+var UDPRouteObj = v1alpha2.UDPRoute{
+	ObjectMeta: metav1.ObjectMeta{
+		Name:      UDPRouteName,
+		Namespace: RolloutNamespace,
+	},
+	Spec: v1alpha2.UDPRouteSpec{
+		Rules: []v1alpha2.UDPRouteRule{
+			{
+				BackendRefs: []v1alpha2.BackendRef{
+					{
+						BackendObjectReference: v1alpha2.BackendObjectReference{
+							Name: StableServiceName,
+							Port: &port,
+						},
+						Weight: &weight,
+					},
+					{
+						BackendObjectReference: v1alpha2.BackendObjectReference{
+							Name: CanaryServiceName,
+							Port: &port,
+						},
+						Weight: &weight,
+					},
+				},
+			},
+		},
+	},
+}
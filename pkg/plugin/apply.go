@@ -0,0 +1,133 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	"sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-gatewayapi/internal/defaults"
+)
+
+var (
+	httpRouteGVK = gatewayv1.SchemeGroupVersion.WithKind("HTTPRoute")
+	grpcRouteGVK = gatewayv1.SchemeGroupVersion.WithKind("GRPCRoute")
+	tcpRouteGVK  = v1alpha2.SchemeGroupVersion.WithKind("TCPRoute")
+	tlsRouteGVK  = v1alpha2.SchemeGroupVersion.WithKind("TLSRoute")
+	udpRouteGVK  = v1alpha2.SchemeGroupVersion.WithKind("UDPRoute")
+)
+
+// routeClient is the subset of the typed Gateway API route clients used to write routes.
+type routeClient[T metav1.Object] interface {
+	Update(ctx context.Context, route T, opts metav1.UpdateOptions) (T, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (T, error)
+}
+
+// routeApplyConfiguration is the server-side apply body sent for a route. It only carries
+// the fields the plugin owns. Rules are sent as a whole because Gateway API declares
+// them as an atomic list.
+type routeApplyConfiguration struct {
+	metav1.TypeMeta `json:",inline"`
+	Metadata        routeApplyMetadata `json:"metadata"`
+	Spec            routeApplySpec     `json:"spec"`
+}
+
+type routeApplyMetadata struct {
+	Name            string            `json:"name"`
+	Namespace       string            `json:"namespace,omitempty"`
+	ResourceVersion string            `json:"resourceVersion,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	Annotations     map[string]string `json:"annotations,omitempty"`
+}
+
+type routeApplySpec struct {
+	Rules any `json:"rules"`
+}
+
+// updateRoute writes route back to the cluster. By default the whole object is sent with
+// an Update. With serverSideApply enabled, only rules and the plugin's own label and
+// annotation are applied under the plugin field manager, so other managers keep ownership
+// of the rest of the route.
+func updateRoute[T metav1.Object](ctx context.Context, client routeClient[T], route T, rules any, gvk schema.GroupVersionKind, config *GatewayAPITrafficRouting) error {
+	if config == nil || !config.ServerSideApply {
+		_, err := client.Update(ctx, route, metav1.UpdateOptions{})
+		if apierrors.IsConflict(err) {
+			routeUpdateConflictsTotal.WithLabelValues(gvk.Kind).Inc()
+		}
+		return err
+	}
+	data, err := json.Marshal(newRouteApplyConfiguration(route, rules, gvk, config))
+	if err != nil {
+		return err
+	}
+	force := config.ForceConflicts
+	_, err = client.Patch(ctx, route.GetName(), types.ApplyPatchType, data, metav1.PatchOptions{
+		FieldManager: config.fieldManager(),
+		Force:        &force,
+	})
+	if apierrors.IsConflict(err) {
+		routeUpdateConflictsTotal.WithLabelValues(gvk.Kind).Inc()
+	}
+	if isFieldManagerConflict(err) {
+		// Conflicts between field managers are not resolved by retrying, so they are
+		// reported without the Conflict reason that retry.RetryOnConflict looks for.
+		return newPluginError("FieldManagerConflictError", FieldManagerConflictError, route.GetName(), err)
+	}
+	return err
+}
+
+func newRouteApplyConfiguration(route metav1.Object, rules any, gvk schema.GroupVersionKind, config *GatewayAPITrafficRouting) *routeApplyConfiguration {
+	applyConfig := &routeApplyConfiguration{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: gvk.GroupVersion().String(),
+			Kind:       gvk.Kind,
+		},
+		Metadata: routeApplyMetadata{
+			Name:            route.GetName(),
+			Namespace:       route.GetNamespace(),
+			ResourceVersion: route.GetResourceVersion(),
+		},
+		Spec: routeApplySpec{
+			Rules: rules,
+		},
+	}
+	// The label and the annotation are left out once the plugin removed them, which makes
+	// the API server drop them as well since the plugin field manager owned them.
+	if value, ok := route.GetLabels()[config.inProgressLabelKey()]; ok && !config.DisableInProgressLabel {
+		applyConfig.Metadata.Labels = map[string]string{config.inProgressLabelKey(): value}
+	}
+	if value, ok := route.GetAnnotations()[defaults.OriginalSpecAnnotationKey]; ok && config.RestoreOriginalSpec {
+		applyConfig.Metadata.Annotations = map[string]string{defaults.OriginalSpecAnnotationKey: value}
+	}
+	return applyConfig
+}
+
+func isFieldManagerConflict(err error) bool {
+	var statusErr apierrors.APIStatus
+	if err == nil || !errors.As(err, &statusErr) || !apierrors.IsConflict(err) {
+		return false
+	}
+	details := statusErr.Status().Details
+	if details == nil {
+		return false
+	}
+	for _, cause := range details.Causes {
+		if cause.Type == metav1.CauseTypeFieldManagerConflict {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *GatewayAPITrafficRouting) fieldManager() string {
+	if c.FieldManager != "" {
+		return c.FieldManager
+	}
+	return defaults.FieldManager
+}
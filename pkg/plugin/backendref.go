@@ -0,0 +1,193 @@
+package plugin
+
+import (
+	"github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+const (
+	serviceKind       = "Service"
+	serviceImportKind = "ServiceImport"
+	inferencePoolKind = "InferencePool"
+	// coreGroup is accepted by some meshes, such as Linkerd, as the group of Services
+	coreGroup = "core"
+)
+
+// backendKindGroups are the API groups of the backend kinds a rollout can declare for its
+// stable and canary backendRefs.
+var backendKindGroups = map[string]string{
+	serviceKind:       "",
+	serviceImportKind: "multicluster.x-k8s.io",
+	inferencePoolKind: "inference.networking.k8s.io",
+}
+
+// backendRefMatcher matches the backendRefs of a route that point to the stable or canary
+// backend of a rollout, by name, group, kind, namespace and optionally port.
+type backendRefMatcher struct {
+	name      string
+	group     string
+	kind      string
+	namespace string
+	// routeNamespace is the namespace of a backendRef without namespace
+	routeNamespace string
+	port           *gatewayv1.PortNumber
+}
+
+func newBackendRefMatcher(name string, backendRefMatch *BackendRefMatch, namespace, routeNamespace string) backendRefMatcher {
+	matcher := backendRefMatcher{
+		name:           name,
+		group:          backendKindGroups[serviceKind],
+		kind:           serviceKind,
+		namespace:      namespace,
+		routeNamespace: routeNamespace,
+	}
+	if backendRefMatch == nil {
+		return matcher
+	}
+	if backendRefMatch.Name != "" {
+		matcher.name = backendRefMatch.Name
+	}
+	if backendRefMatch.Kind != "" {
+		matcher.kind = backendRefMatch.Kind
+		matcher.group = backendKindGroups[backendRefMatch.Kind]
+	}
+	if backendRefMatch.Group != "" {
+		matcher.group = backendRefMatch.Group
+	}
+	if backendRefMatch.Port != nil {
+		port := gatewayv1.PortNumber(*backendRefMatch.Port)
+		matcher.port = &port
+	}
+	return matcher
+}
+
+// newExperimentBackendRefMatcher returns the matcher of the backendRefs of the experiment
+// service serviceName. Like the backendRefs the plugin adds for it, they are ServiceImports
+// next to a canary ServiceImport and Services otherwise.
+func newExperimentBackendRefMatcher(serviceName string, canaryMatcher backendRefMatcher) backendRefMatcher {
+	matcher := backendRefMatcher{
+		name:           serviceName,
+		group:          backendKindGroups[serviceKind],
+		kind:           serviceKind,
+		namespace:      canaryMatcher.namespace,
+		routeNamespace: canaryMatcher.routeNamespace,
+	}
+	if canaryMatcher.kind == serviceImportKind {
+		matcher.group = canaryMatcher.group
+		matcher.kind = canaryMatcher.kind
+	}
+	return matcher
+}
+
+// getPreviousExperimentBackendRefMatchers returns the matchers of the experiment services
+// recorded in the rollout status, which the plugin added on the previous reconcile.
+func getPreviousExperimentBackendRefMatchers(rollout *v1alpha1.Rollout, canaryMatcher backendRefMatcher) []backendRefMatcher {
+	if rollout.Status.Canary.Weights == nil {
+		return nil
+	}
+	matchers := make([]backendRefMatcher, 0, len(rollout.Status.Canary.Weights.Additional))
+	for _, destination := range rollout.Status.Canary.Weights.Additional {
+		matchers = append(matchers, newExperimentBackendRefMatcher(destination.ServiceName, canaryMatcher))
+	}
+	return matchers
+}
+
+// getOwnedBackendRefMatchers returns the matchers of the backends of a route in
+// routeNamespace whose weights the plugin sets: the stable and canary backends, the
+// experiment services of additionalDestinations and the ones recorded in the rollout status,
+// which are about to be removed.
+func (c *GatewayAPITrafficRouting) getOwnedBackendRefMatchers(rollout *v1alpha1.Rollout, routeNamespace string, additionalDestinations []v1alpha1.WeightDestination) []backendRefMatcher {
+	canaryMatcher := c.getCanaryBackendRefMatcher(rollout, routeNamespace)
+	ownedMatchers := []backendRefMatcher{c.getStableBackendRefMatcher(rollout, routeNamespace), canaryMatcher}
+	for _, destination := range additionalDestinations {
+		ownedMatchers = append(ownedMatchers, newExperimentBackendRefMatcher(destination.ServiceName, canaryMatcher))
+	}
+	return append(ownedMatchers, getPreviousExperimentBackendRefMatchers(rollout, canaryMatcher)...)
+}
+
+// getStableBackendRefMatcher returns the matcher of the stable backendRefs of a route in
+// routeNamespace.
+func (c *GatewayAPITrafficRouting) getStableBackendRefMatcher(rollout *v1alpha1.Rollout, routeNamespace string) backendRefMatcher {
+	return newBackendRefMatcher(rollout.Spec.Strategy.Canary.StableService, c.StableBackendRef, rollout.Namespace, routeNamespace)
+}
+
+// getCanaryBackendRefMatcher returns the matcher of the canary backendRefs of a route in
+// routeNamespace.
+func (c *GatewayAPITrafficRouting) getCanaryBackendRefMatcher(rollout *v1alpha1.Rollout, routeNamespace string) backendRefMatcher {
+	return newBackendRefMatcher(rollout.Spec.Strategy.Canary.CanaryService, c.CanaryBackendRef, rollout.Namespace, routeNamespace)
+}
+
+// matches reports whether backendRef points to the backend of m.
+func (m backendRefMatcher) matches(backendRef gatewayv1.BackendObjectReference) bool {
+	key := newBackendRefKey(backendRef, m.routeNamespace)
+	if key.name != m.name || key.group != m.group || key.kind != m.kind || key.namespace != m.namespace {
+		return false
+	}
+	return m.port == nil || (backendRef.Port != nil && *backendRef.Port == *m.port)
+}
+
+// matchesAny reports whether backendRef points to the backend of one of matchers.
+func matchesAny(matchers []backendRefMatcher, backendRef gatewayv1.BackendObjectReference) bool {
+	for _, matcher := range matchers {
+		if matcher.matches(backendRef) {
+			return true
+		}
+	}
+	return false
+}
+
+// backendRefKey identifies the backend a backendRef points to, so that backendRefs that
+// spell the defaults of their group, kind or namespace differently compare equal.
+type backendRefKey struct {
+	group     string
+	kind      string
+	namespace string
+	name      string
+}
+
+// newBackendRefKey returns the key of backendRef in a route in routeNamespace. A backendRef
+// without group, kind or namespace refers to a Service in the namespace of its route.
+func newBackendRefKey(backendRef gatewayv1.BackendObjectReference, routeNamespace string) backendRefKey {
+	group := ""
+	if backendRef.Group != nil {
+		group = string(*backendRef.Group)
+	}
+	kind := serviceKind
+	if backendRef.Kind != nil {
+		kind = string(*backendRef.Kind)
+	}
+	namespace := routeNamespace
+	if backendRef.Namespace != nil {
+		namespace = string(*backendRef.Namespace)
+	}
+	return backendRefKey{
+		group:     normalizeBackendGroup(group, kind),
+		kind:      kind,
+		namespace: namespace,
+		name:      string(backendRef.Name),
+	}
+}
+
+// normalizeBackendGroup returns the API group of backends of kind, which is empty for
+// Services referenced with the "core" group.
+func normalizeBackendGroup(group, kind string) string {
+	if kind == serviceKind && group == coreGroup {
+		return ""
+	}
+	return group
+}
+
+// getGroupKind returns the group and kind of backendRef, a backendRef matched by m, so that
+// the rules generated from it keep the spelling of the route, such as the "core" group of
+// Services in Linkerd.
+func (m backendRefMatcher) getGroupKind(backendRef gatewayv1.BackendObjectReference) (*gatewayv1.Group, *gatewayv1.Kind) {
+	group := gatewayv1.Group(m.group)
+	if backendRef.Group != nil {
+		group = *backendRef.Group
+	}
+	kind := gatewayv1.Kind(m.kind)
+	if backendRef.Kind != nil {
+		kind = *backendRef.Kind
+	}
+	return &group, &kind
+}
@@ -0,0 +1,334 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	kubeInformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	coreListers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/util/retry"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	"sigs.k8s.io/gateway-api/apis/v1alpha2"
+	gatewayApiClientset "sigs.k8s.io/gateway-api/pkg/client/clientset/versioned"
+	gatewayInformers "sigs.k8s.io/gateway-api/pkg/client/informers/externalversions"
+	gatewayListersV1 "sigs.k8s.io/gateway-api/pkg/client/listers/apis/v1"
+	gatewayListersV1alpha2 "sigs.k8s.io/gateway-api/pkg/client/listers/apis/v1alpha2"
+)
+
+const cacheSyncTimeout = 2 * time.Minute
+
+// errRouteUnchanged is returned by a retryOnConflictOrStale function when the route has
+// nothing to update.
+var errRouteUnchanged = errors.New("route unchanged")
+
+// informerCache serves route and Service reads from shared informers. A lister is nil when
+// the cluster does not serve its kind, in which case reads of that kind go to the API server.
+type informerCache struct {
+	namespace       string
+	httpRouteLister gatewayListersV1.HTTPRouteLister
+	grpcRouteLister gatewayListersV1.GRPCRouteLister
+	tcpRouteLister  gatewayListersV1alpha2.TCPRouteLister
+	tlsRouteLister  gatewayListersV1alpha2.TLSRouteLister
+	udpRouteLister  gatewayListersV1alpha2.UDPRouteLister
+	serviceLister   coreListers.ServiceLister
+}
+
+// newInformerCache starts the informers and waits for their first sync. An empty namespace
+// watches every namespace.
+func newInformerCache(ctx context.Context, clientset kubernetes.Interface, gatewayAPIClientset gatewayApiClientset.Interface, namespace string, logger *logrus.Entry) (*informerCache, error) {
+	gatewayAPIInformerFactory := gatewayInformers.NewSharedInformerFactoryWithOptions(gatewayAPIClientset, 0, gatewayInformers.WithNamespace(namespace))
+	kubeInformerFactory := kubeInformers.NewSharedInformerFactoryWithOptions(clientset, 0, kubeInformers.WithNamespace(namespace))
+	routeInformers := gatewayAPIInformerFactory.Gateway()
+	discoveryClient := gatewayAPIClientset.Discovery()
+
+	cache := &informerCache{
+		namespace:     namespace,
+		serviceLister: kubeInformerFactory.Core().V1().Services().Lister(),
+	}
+	if isResourceServed(discoveryClient, gatewayv1.SchemeGroupVersion.String(), "httproutes") {
+		cache.httpRouteLister = routeInformers.V1().HTTPRoutes().Lister()
+	}
+	if isResourceServed(discoveryClient, gatewayv1.SchemeGroupVersion.String(), "grpcroutes") {
+		cache.grpcRouteLister = routeInformers.V1().GRPCRoutes().Lister()
+	}
+	if isResourceServed(discoveryClient, v1alpha2.SchemeGroupVersion.String(), "tcproutes") {
+		cache.tcpRouteLister = routeInformers.V1alpha2().TCPRoutes().Lister()
+	}
+	if isResourceServed(discoveryClient, v1alpha2.SchemeGroupVersion.String(), "tlsroutes") {
+		cache.tlsRouteLister = routeInformers.V1alpha2().TLSRoutes().Lister()
+	}
+	if isResourceServed(discoveryClient, v1alpha2.SchemeGroupVersion.String(), "udproutes") {
+		cache.udpRouteLister = routeInformers.V1alpha2().UDPRoutes().Lister()
+	}
+
+	gatewayAPIInformerFactory.Start(ctx.Done())
+	kubeInformerFactory.Start(ctx.Done())
+
+	syncCtx, cancel := context.WithTimeout(ctx, cacheSyncTimeout)
+	defer cancel()
+	for informerType, isSynced := range gatewayAPIInformerFactory.WaitForCacheSync(syncCtx.Done()) {
+		if !isSynced {
+			return nil, newPluginError("CacheSyncError", CacheSyncError, informerType)
+		}
+		logger.Infof("Informer cache synced for %v", informerType)
+	}
+	for informerType, isSynced := range kubeInformerFactory.WaitForCacheSync(syncCtx.Done()) {
+		if !isSynced {
+			return nil, newPluginError("CacheSyncError", CacheSyncError, informerType)
+		}
+		logger.Infof("Informer cache synced for %v", informerType)
+	}
+	return cache, nil
+}
+
+// isResourceServed reports whether the API server serves resource in groupVersion. Gateway
+// API kinds from the experimental channel are often not installed, and an informer for a
+// missing kind would never sync.
+func isResourceServed(discoveryClient discovery.DiscoveryInterface, groupVersion, resource string) bool {
+	resourceList, err := discoveryClient.ServerResourcesForGroupVersion(groupVersion)
+	if err != nil || resourceList == nil {
+		return false
+	}
+	for _, apiResource := range resourceList.APIResources {
+		if apiResource.Name == resource {
+			return true
+		}
+	}
+	return false
+}
+
+// hasNamespace reports whether the cache watches namespace.
+func (c *informerCache) hasNamespace(namespace string) bool {
+	return c != nil && (c.namespace == "" || c.namespace == namespace)
+}
+
+// isKindUnserved reports whether the cache watches namespace but had no informer for the
+// route kind, because the cluster did not serve it when the plugin started.
+func (c *informerCache) isKindUnserved(namespace, kind string) bool {
+	if !c.hasNamespace(namespace) {
+		return false
+	}
+	switch kind {
+	case httpRouteGVK.Kind:
+		return c.httpRouteLister == nil
+	case grpcRouteGVK.Kind:
+		return c.grpcRouteLister == nil
+	case tcpRouteGVK.Kind:
+		return c.tcpRouteLister == nil
+	case tlsRouteGVK.Kind:
+		return c.tlsRouteLister == nil
+	case udpRouteGVK.Kind:
+		return c.udpRouteLister == nil
+	}
+	return false
+}
+
+// retryOnConflict runs fn again while it fails with a conflict. Only the first attempt may
+// read from the informer cache, since a conflict means the cached object was stale.
+func retryOnConflict(gvk schema.GroupVersionKind, fn func(useCache bool) error) error {
+	useCache := true
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if !useCache {
+			routeUpdateRetriesTotal.WithLabelValues(gvk.Kind).Inc()
+		}
+		err := fn(useCache)
+		useCache = false
+		return err
+	})
+}
+
+// retryOnConflictOrStale is retryOnConflict for updates that fn skips, by returning
+// errRouteUnchanged, when the route has nothing to update. Such an update is only skipped
+// once the API server agrees, since the informer cache may not have seen the rules the
+// plugin wrote last.
+func retryOnConflictOrStale(gvk schema.GroupVersionKind, fn func(useCache bool) error) error {
+	err := retryOnConflict(gvk, func(useCache bool) error {
+		err := fn(useCache)
+		if useCache && errors.Is(err, errRouteUnchanged) {
+			return fn(false)
+		}
+		return err
+	})
+	if errors.Is(err, errRouteUnchanged) {
+		return nil
+	}
+	return err
+}
+
+func (r *RpcPlugin) getHTTPRoute(ctx context.Context, namespace, name string, useCache bool) (*gatewayv1.HTTPRoute, error) {
+	if useCache && r.cache.hasNamespace(namespace) && r.cache.httpRouteLister != nil {
+		httpRoute, err := r.cache.httpRouteLister.HTTPRoutes(namespace).Get(name)
+		if err != nil {
+			return nil, err
+		}
+		return httpRoute.DeepCopy(), nil
+	}
+	return r.GatewayAPIClientset.GatewayV1().HTTPRoutes(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+func (r *RpcPlugin) getGRPCRoute(ctx context.Context, namespace, name string, useCache bool) (*gatewayv1.GRPCRoute, error) {
+	if useCache && r.cache.hasNamespace(namespace) && r.cache.grpcRouteLister != nil {
+		grpcRoute, err := r.cache.grpcRouteLister.GRPCRoutes(namespace).Get(name)
+		if err != nil {
+			return nil, err
+		}
+		return grpcRoute.DeepCopy(), nil
+	}
+	return r.GatewayAPIClientset.GatewayV1().GRPCRoutes(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+func (r *RpcPlugin) getTCPRoute(ctx context.Context, namespace, name string, useCache bool) (*v1alpha2.TCPRoute, error) {
+	if useCache && r.cache.hasNamespace(namespace) && r.cache.tcpRouteLister != nil {
+		tcpRoute, err := r.cache.tcpRouteLister.TCPRoutes(namespace).Get(name)
+		if err != nil {
+			return nil, err
+		}
+		return tcpRoute.DeepCopy(), nil
+	}
+	return r.GatewayAPIClientset.GatewayV1alpha2().TCPRoutes(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+func (r *RpcPlugin) getTLSRoute(ctx context.Context, namespace, name string, useCache bool) (*v1alpha2.TLSRoute, error) {
+	if useCache && r.cache.hasNamespace(namespace) && r.cache.tlsRouteLister != nil {
+		tlsRoute, err := r.cache.tlsRouteLister.TLSRoutes(namespace).Get(name)
+		if err != nil {
+			return nil, err
+		}
+		return tlsRoute.DeepCopy(), nil
+	}
+	return r.GatewayAPIClientset.GatewayV1alpha2().TLSRoutes(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+func (r *RpcPlugin) getUDPRoute(ctx context.Context, namespace, name string, useCache bool) (*v1alpha2.UDPRoute, error) {
+	if useCache && r.cache.hasNamespace(namespace) && r.cache.udpRouteLister != nil {
+		udpRoute, err := r.cache.udpRouteLister.UDPRoutes(namespace).Get(name)
+		if err != nil {
+			return nil, err
+		}
+		return udpRoute.DeepCopy(), nil
+	}
+	return r.GatewayAPIClientset.GatewayV1alpha2().UDPRoutes(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+// getService reads a Service from the cache, or from the API server when the cache does not
+// have it yet. Experiment Services are created right before the controller asks the plugin to
+// add them, so the informer may not have seen them.
+func (r *RpcPlugin) getService(ctx context.Context, namespace, name string) (*corev1.Service, error) {
+	if r.cache.hasNamespace(namespace) {
+		service, err := r.cache.serviceLister.Services(namespace).Get(name)
+		if err == nil {
+			return service.DeepCopy(), nil
+		}
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+	}
+	return r.Clientset.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+// copyCachedRoutes copies the routes listed from the informer cache and sorts them by name,
+// which keeps the order of the API server, since it lists objects sorted by name.
+func copyCachedRoutes[Route any, RoutePointer interface {
+	*Route
+	GetName() string
+	DeepCopy() *Route
+}](routeList []RoutePointer) []Route {
+	routes := make([]Route, 0, len(routeList))
+	for _, route := range routeList {
+		routes = append(routes, *route.DeepCopy())
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		return RoutePointer(&routes[i]).GetName() < RoutePointer(&routes[j]).GetName()
+	})
+	return routes
+}
+
+// listHTTPRoutes returns the HTTPRoutes in namespace matching selector, sorted by name.
+func (r *RpcPlugin) listHTTPRoutes(ctx context.Context, namespace string, selector labels.Selector) ([]gatewayv1.HTTPRoute, error) {
+	if r.cache.hasNamespace(namespace) && r.cache.httpRouteLister != nil {
+		httpRouteList, err := r.cache.httpRouteLister.HTTPRoutes(namespace).List(selector)
+		if err != nil {
+			return nil, err
+		}
+		return copyCachedRoutes(httpRouteList), nil
+	}
+	httpRouteList, err := r.GatewayAPIClientset.GatewayV1().HTTPRoutes(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+	return httpRouteList.Items, nil
+}
+
+// listGRPCRoutes returns the GRPCRoutes in namespace matching selector, sorted by name.
+func (r *RpcPlugin) listGRPCRoutes(ctx context.Context, namespace string, selector labels.Selector) ([]gatewayv1.GRPCRoute, error) {
+	if r.cache.hasNamespace(namespace) && r.cache.grpcRouteLister != nil {
+		grpcRouteList, err := r.cache.grpcRouteLister.GRPCRoutes(namespace).List(selector)
+		if err != nil {
+			return nil, err
+		}
+		return copyCachedRoutes(grpcRouteList), nil
+	}
+	grpcRouteList, err := r.GatewayAPIClientset.GatewayV1().GRPCRoutes(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+	return grpcRouteList.Items, nil
+}
+
+// listTCPRoutes returns the TCPRoutes in namespace matching selector, sorted by name.
+func (r *RpcPlugin) listTCPRoutes(ctx context.Context, namespace string, selector labels.Selector) ([]v1alpha2.TCPRoute, error) {
+	if r.cache.hasNamespace(namespace) && r.cache.tcpRouteLister != nil {
+		tcpRouteList, err := r.cache.tcpRouteLister.TCPRoutes(namespace).List(selector)
+		if err != nil {
+			return nil, err
+		}
+		return copyCachedRoutes(tcpRouteList), nil
+	}
+	tcpRouteList, err := r.GatewayAPIClientset.GatewayV1alpha2().TCPRoutes(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+	return tcpRouteList.Items, nil
+}
+
+// listTLSRoutes returns the TLSRoutes in namespace matching selector, sorted by name.
+func (r *RpcPlugin) listTLSRoutes(ctx context.Context, namespace string, selector labels.Selector) ([]v1alpha2.TLSRoute, error) {
+	if r.cache.hasNamespace(namespace) && r.cache.tlsRouteLister != nil {
+		tlsRouteList, err := r.cache.tlsRouteLister.TLSRoutes(namespace).List(selector)
+		if err != nil {
+			return nil, err
+		}
+		return copyCachedRoutes(tlsRouteList), nil
+	}
+	tlsRouteList, err := r.GatewayAPIClientset.GatewayV1alpha2().TLSRoutes(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+	return tlsRouteList.Items, nil
+}
+
+// listUDPRoutes returns the UDPRoutes in namespace matching selector, sorted by name.
+func (r *RpcPlugin) listUDPRoutes(ctx context.Context, namespace string, selector labels.Selector) ([]v1alpha2.UDPRoute, error) {
+	if r.cache.hasNamespace(namespace) && r.cache.udpRouteLister != nil {
+		udpRouteList, err := r.cache.udpRouteLister.UDPRoutes(namespace).List(selector)
+		if err != nil {
+			return nil, err
+		}
+		return copyCachedRoutes(udpRouteList), nil
+	}
+	udpRouteList, err := r.GatewayAPIClientset.GatewayV1alpha2().UDPRoutes(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+	return udpRouteList.Items, nil
+}
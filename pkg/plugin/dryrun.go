@@ -0,0 +1,41 @@
+package plugin
+
+import (
+	"encoding/json"
+
+	"github.com/sirupsen/logrus"
+	jsonpatch "gopkg.in/evanphx/json-patch.v4"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// isDryRun reports whether route changes must only be logged, either because the plugin
+// was started with --dry-run or because the rollout enabled dryRun.
+func (r *RpcPlugin) isDryRun(config *GatewayAPITrafficRouting) bool {
+	return r.CommandLineOpts.DryRun || (config != nil && config.DryRun)
+}
+
+// logDryRun logs the change the plugin would have made to the spec of route as a JSON
+// merge patch instead of writing it.
+func (r *RpcPlugin) logDryRun(gvk schema.GroupVersionKind, route metav1.Object, originalSpec, spec any) error {
+	originalJSON, err := json.Marshal(originalSpec)
+	if err != nil {
+		return err
+	}
+	specJSON, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	patch, err := jsonpatch.CreateMergePatch(originalJSON, specJSON)
+	if err != nil {
+		return err
+	}
+	r.LogCtx.WithFields(logrus.Fields{
+		"dryRun":    true,
+		"kind":      gvk.Kind,
+		"namespace": route.GetNamespace(),
+		"name":      route.GetName(),
+		"specDiff":  string(patch),
+	}).Info("[DryRun] skipping route update")
+	return nil
+}
@@ -1,13 +1,50 @@
 package plugin
 
+import "fmt"
+
 const (
 	GatewayAPIUpdateError                    = "error updating Gateway API %q: %s"
-	GatewayAPIManifestError                  = "No routes configured. At least one of 'httpRoutes', 'grpcRoutes', 'tcpRoutes', 'tlsRoutes', 'httpRoute', 'grpcRoute', 'tcpRoute' or 'tlsRoute' must be set"
+	GatewayAPIManifestError                  = "No routes configured. At least one of 'httpRoutes', 'grpcRoutes', 'tcpRoutes', 'tlsRoutes', 'udpRoutes', 'httpRoute', 'grpcRoute', 'tcpRoute', 'tlsRoute' or 'udpRoute' must be set"
 	InvalidHeaderMatchTypeError              = "invalid header match type"
+	InvalidPathMatchTypeError                = "invalid path match type"
+	InvalidMethodMatchTypeError              = "invalid method match type, only exact method matches are supported"
+	CacheSyncError                           = "timed out waiting for the informer cache of %v to sync"
+	FieldManagerConflictError                = "conflict with another field manager while applying route %q: %s"
+	InvalidRouteSpecSnapshotError            = "invalid original spec snapshot on route %q: %s"
+	UnsupportedGRPCMirrorMatchError          = "method and path matches are not supported for grpcRoute mirror routes"
+	MirrorRouteMatchOutsideSourceRulesError  = "match %d of mirror route %q is not the same as or nested within the path and method of any rule in httpRoute %q"
+	ExperimentServicePortWasNotFoundError    = "experiment service %q has no port %s"
+	CanaryBackendRefPortWasNotFoundError     = "canary backendRef has no port and experiment service %q does not have exactly one port, set experimentPort to select one"
+	InvalidWeightError                       = "invalid weight %d of %s, weights must be between 0 and 100"
+	WeightOverAllocationError                = "canary weight %d and experiment weights %d add up to more than 100"
+	ReferenceGrantWasNotFoundError           = "no ReferenceGrant in namespace %q allows %s %q in namespace %q to reference %s %q"
+	RouteRuleNameWasNotFoundError            = "rule %q was not found in %s %q"
+	RouteRuleIndexWasNotFoundError           = "rule index %d was not found in %s %q"
 	BackendRefWasNotFoundInHTTPRouteError    = "backendRef was not found in httpRoute"
 	BackendRefWasNotFoundInGRPCRouteError    = "backendRef was not found in grpcRoute"
 	BackendRefWasNotFoundInTCPRouteError     = "backendRef was not found in tcpRoute"
 	BackendRefWasNotFoundInTLSRouteError     = "backendRef was not found in tlsRoute"
+	BackendRefWasNotFoundInUDPRouteError     = "backendRef was not found in udpRoute"
 	BackendRefListWasNotFoundInTCPRouteError = "backendRef list was not found in tcpRoute"
 	BackendRefListWasNotFoundInTLSRouteError = "backendRef list was not found in tlsRoute"
+	BackendRefListWasNotFoundInUDPRouteError = "backendRef list was not found in udpRoute"
 )
+
+// pluginError is an error built from one of the messages above. Its reason is the name of
+// the message constant, which the route error metric uses as its error label so that
+// formatted messages do not create a new label value each.
+type pluginError struct {
+	reason  string
+	message string
+}
+
+func newPluginError(reason, messageFmt string, args ...any) error {
+	return &pluginError{
+		reason:  reason,
+		message: fmt.Sprintf(messageFmt, args...),
+	}
+}
+
+func (e *pluginError) Error() string {
+	return e.message
+}
@@ -0,0 +1,70 @@
+package plugin
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+	gatewayScheme "sigs.k8s.io/gateway-api/pkg/client/clientset/versioned/scheme"
+)
+
+const EventComponent = "argo-rollouts-gatewayapi-plugin"
+
+const (
+	WeightUpdatedReason            = "WeightUpdated"
+	OriginalSpecRestoredReason     = "OriginalSpecRestored"
+	HeaderRouteAddedReason         = "HeaderRouteAdded"
+	MirrorRouteAddedReason         = "MirrorRouteAdded"
+	MirrorRouteRemovedReason       = "MirrorRouteRemoved"
+	ManagedRoutesRemovedReason     = "ManagedRoutesRemoved"
+	ExperimentBackendAddedReason   = "ExperimentBackendAdded"
+	ExperimentBackendRemovedReason = "ExperimentBackendRemoved"
+	UpdateFailedReason             = "UpdateFailed"
+)
+
+// newEventRecorder returns a recorder that writes Events through clientset. The scheme
+// only needs the Gateway API kinds, which are the only objects the plugin records Events on.
+func newEventRecorder(clientset kubernetes.Interface) record.EventRecorder {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(gatewayScheme.AddToScheme(scheme))
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientset.CoreV1().Events("")})
+	return broadcaster.NewRecorder(scheme, corev1.EventSource{Component: EventComponent})
+}
+
+func (r *RpcPlugin) recordEvent(route runtime.Object, eventType, reason, messageFmt string, args ...any) {
+	if r.recorder == nil {
+		return
+	}
+	r.recorder.Eventf(route, eventType, reason, messageFmt, args...)
+}
+
+// recordRouteFailure records a Warning Event once a change to a route finally failed. The
+// route may not have been read at that point, so the Event refers to it by name.
+func (r *RpcPlugin) recordRouteFailure(gvk schema.GroupVersionKind, namespace, name, operation string, err error) {
+	route := &corev1.ObjectReference{
+		APIVersion: gvk.GroupVersion().String(),
+		Kind:       gvk.Kind,
+		Namespace:  namespace,
+		Name:       name,
+	}
+	r.recordEvent(route, corev1.EventTypeWarning, UpdateFailedReason, "%s failed: %s", operation, err)
+}
+
+// recordExperimentBackendEvents records the backends HandleExperiment added to or removed
+// from route by comparing the backends before and after.
+func (r *RpcPlugin) recordExperimentBackendEvents(route runtime.Object, previousBackendRefs, backendRefs map[backendRefKey]bool) {
+	for key := range backendRefs {
+		if !previousBackendRefs[key] {
+			r.recordEvent(route, corev1.EventTypeNormal, ExperimentBackendAddedReason, "Added experiment backend %q", key.name)
+		}
+	}
+	for key := range previousBackendRefs {
+		if !backendRefs[key] {
+			r.recordEvent(route, corev1.EventTypeNormal, ExperimentBackendRemovedReason, "Removed experiment backend %q", key.name)
+		}
+	}
+}
@@ -6,49 +6,175 @@ import (
 
 	"github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
 	"github.com/sirupsen/logrus"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
+	corev1 "k8s.io/api/core/v1"
 	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	"sigs.k8s.io/gateway-api/apis/v1alpha2"
 	gatewayApiClientset "sigs.k8s.io/gateway-api/pkg/client/clientset/versioned"
 )
 
-func HandleExperiment(ctx context.Context, clientset *kubernetes.Clientset, gatewayClient gatewayApiClientset.Interface, logger *logrus.Entry, rollout *v1alpha1.Rollout, httpRoute *gatewayv1.HTTPRoute, additionalDestinations []v1alpha1.WeightDestination) error {
-	ruleIdx := -1
-	stableService := rollout.Spec.Strategy.Canary.StableService
-	canaryService := rollout.Spec.Strategy.Canary.CanaryService
+// ServiceGetter returns the named Service.
+type ServiceGetter func(ctx context.Context, namespace, name string) (*corev1.Service, error)
 
-	for i, rule := range httpRoute.Spec.Rules {
-		if ruleIdx != -1 {
-			break
+// HandleExperiment adds or removes the experiment services in every rule of httpRoute whose
+// weights are set by the plugin, which leaves out the managed header rules and the rules
+// that are not targeted by target.
+func HandleExperiment(ctx context.Context, getService ServiceGetter, gatewayClient gatewayApiClientset.Interface, logger *logrus.Entry, rollout *v1alpha1.Rollout, httpRoute *gatewayv1.HTTPRoute, additionalDestinations []v1alpha1.WeightDestination, experimentPort *ExperimentPort, target routeTarget, gatewayAPIConfig *GatewayAPITrafficRouting) error {
+	managedNames := managedRouteNamesSet(rollout)
+	canaryMatcher := gatewayAPIConfig.getCanaryBackendRefMatcher(rollout, target.namespace)
+	stableMatcher := gatewayAPIConfig.getStableBackendRefMatcher(rollout, target.namespace)
+	isRuleFound := false
+	for i := range httpRoute.Spec.Rules {
+		rule := &httpRoute.Spec.Rules[i]
+		if isHTTPHeaderRouteRule(*rule, canaryMatcher, managedNames) || !isHTTPRuleTargeted(i, *rule, managedNames, target) || !hasRolloutBackendRef[*HTTPBackendRef]((*HTTPRouteRule)(rule), canaryMatcher, stableMatcher) {
+			continue
 		}
-		for _, backendRef := range rule.BackendRefs {
-			if string(backendRef.Name) == stableService || string(backendRef.Name) == canaryService {
-				ruleIdx = i
-				break
-			}
+		isRuleFound = true
+		backendRefs, err := handleExperimentBackendRefs(ctx, getService, logger, rollout, "HTTPRoute", rule.BackendRefs, additionalDestinations, experimentPort, canaryMatcher, getHTTPExperimentBackendRef, func(backendRef gatewayv1.BackendRef) gatewayv1.HTTPBackendRef {
+			return gatewayv1.HTTPBackendRef{BackendRef: backendRef}
+		})
+		rule.BackendRefs = backendRefs
+		if err != nil {
+			return err
 		}
 	}
+	if !isRuleFound {
+		return fmt.Errorf("no matching rule found for rollout %s", rollout.Name)
+	}
+	return nil
+}
 
-	if ruleIdx == -1 {
+// HandleGRPCExperiment adds or removes the experiment services in every rule of grpcRoute
+// whose weights are set by the plugin, which leaves out the managed header rules and the
+// rules that are not targeted by target.
+func HandleGRPCExperiment(ctx context.Context, getService ServiceGetter, logger *logrus.Entry, rollout *v1alpha1.Rollout, grpcRoute *gatewayv1.GRPCRoute, additionalDestinations []v1alpha1.WeightDestination, experimentPort *ExperimentPort, target routeTarget, gatewayAPIConfig *GatewayAPITrafficRouting) error {
+	managedNames := managedRouteNamesSet(rollout)
+	canaryMatcher := gatewayAPIConfig.getCanaryBackendRefMatcher(rollout, target.namespace)
+	stableMatcher := gatewayAPIConfig.getStableBackendRefMatcher(rollout, target.namespace)
+	isRuleFound := false
+	for i := range grpcRoute.Spec.Rules {
+		rule := &grpcRoute.Spec.Rules[i]
+		if isGRPCHeaderRouteRule(*rule, canaryMatcher, managedNames) || !isGRPCRuleTargeted(i, *rule, managedNames, target) || !hasRolloutBackendRef[*GRPCBackendRef]((*GRPCRouteRule)(rule), canaryMatcher, stableMatcher) {
+			continue
+		}
+		isRuleFound = true
+		backendRefs, err := handleExperimentBackendRefs(ctx, getService, logger, rollout, "GRPCRoute", rule.BackendRefs, additionalDestinations, experimentPort, canaryMatcher, getGRPCExperimentBackendRef, func(backendRef gatewayv1.BackendRef) gatewayv1.GRPCBackendRef {
+			return gatewayv1.GRPCBackendRef{BackendRef: backendRef}
+		})
+		rule.BackendRefs = backendRefs
+		if err != nil {
+			return err
+		}
+	}
+	if !isRuleFound {
 		return fmt.Errorf("no matching rule found for rollout %s", rollout.Name)
 	}
+	return nil
+}
+
+// HandleTCPExperiment adds or removes the experiment services in every rule of tcpRoute
+// that references the stable or canary service.
+func HandleTCPExperiment(ctx context.Context, getService ServiceGetter, logger *logrus.Entry, rollout *v1alpha1.Rollout, tcpRoute *v1alpha2.TCPRoute, additionalDestinations []v1alpha1.WeightDestination, experimentPort *ExperimentPort, target routeTarget, gatewayAPIConfig *GatewayAPITrafficRouting) error {
+	canaryMatcher := gatewayAPIConfig.getCanaryBackendRefMatcher(rollout, target.namespace)
+	stableMatcher := gatewayAPIConfig.getStableBackendRefMatcher(rollout, target.namespace)
+	isRuleFound := false
+	var rule *TCPRouteRule
+	for nextRule, hasNext := TCPRouteRuleList(tcpRoute.Spec.Rules).Iterator(); hasNext; {
+		rule, hasNext = nextRule()
+		if !hasRolloutBackendRef[*TCPBackendRef](rule, canaryMatcher, stableMatcher) {
+			continue
+		}
+		isRuleFound = true
+		backendRefs, err := handleExperimentBackendRefs(ctx, getService, logger, rollout, "TCPRoute", rule.BackendRefs, additionalDestinations, experimentPort, canaryMatcher, getExperimentBackendRef, newExperimentBackendRef)
+		rule.BackendRefs = backendRefs
+		if err != nil {
+			return err
+		}
+	}
+	if !isRuleFound {
+		return fmt.Errorf("no matching rule found for rollout %s", rollout.Name)
+	}
+	return nil
+}
+
+// HandleTLSExperiment adds or removes the experiment services in every rule of tlsRoute
+// that references the stable or canary service.
+func HandleTLSExperiment(ctx context.Context, getService ServiceGetter, logger *logrus.Entry, rollout *v1alpha1.Rollout, tlsRoute *v1alpha2.TLSRoute, additionalDestinations []v1alpha1.WeightDestination, experimentPort *ExperimentPort, target routeTarget, gatewayAPIConfig *GatewayAPITrafficRouting) error {
+	canaryMatcher := gatewayAPIConfig.getCanaryBackendRefMatcher(rollout, target.namespace)
+	stableMatcher := gatewayAPIConfig.getStableBackendRefMatcher(rollout, target.namespace)
+	isRuleFound := false
+	var rule *TLSRouteRule
+	for nextRule, hasNext := TLSRouteRuleList(tlsRoute.Spec.Rules).Iterator(); hasNext; {
+		rule, hasNext = nextRule()
+		if !hasRolloutBackendRef[*TLSBackendRef](rule, canaryMatcher, stableMatcher) {
+			continue
+		}
+		isRuleFound = true
+		backendRefs, err := handleExperimentBackendRefs(ctx, getService, logger, rollout, "TLSRoute", rule.BackendRefs, additionalDestinations, experimentPort, canaryMatcher, getExperimentBackendRef, newExperimentBackendRef)
+		rule.BackendRefs = backendRefs
+		if err != nil {
+			return err
+		}
+	}
+	if !isRuleFound {
+		return fmt.Errorf("no matching rule found for rollout %s", rollout.Name)
+	}
+	return nil
+}
+
+// getRouteExperimentPort returns the experimentPort of a route, which overrides the one
+// set for all routes.
+func (c *GatewayAPITrafficRouting) getRouteExperimentPort(routeExperimentPort *ExperimentPort) *ExperimentPort {
+	if routeExperimentPort != nil {
+		return routeExperimentPort
+	}
+	return c.ExperimentPort
+}
+
+func getExperimentBackendRef(backendRef *gatewayv1.BackendRef) *gatewayv1.BackendRef {
+	return backendRef
+}
 
+func newExperimentBackendRef(backendRef gatewayv1.BackendRef) gatewayv1.BackendRef {
+	return backendRef
+}
+
+func getHTTPExperimentBackendRef(backendRef *gatewayv1.HTTPBackendRef) *gatewayv1.BackendRef {
+	return &backendRef.BackendRef
+}
+
+func getGRPCExperimentBackendRef(backendRef *gatewayv1.GRPCBackendRef) *gatewayv1.BackendRef {
+	return &backendRef.BackendRef
+}
+
+// hasRolloutBackendRef reports whether routeRule references a backend of backendRefMatchers.
+func hasRolloutBackendRef[BackendRef GatewayAPIBackendRef, RouteRule GatewayAPIRouteRule[BackendRef]](routeRule RouteRule, backendRefMatchers ...backendRefMatcher) bool {
+	var backendRef BackendRef
+	for nextRef, hasRef := routeRule.Iterator(); hasRef; {
+		backendRef, hasRef = nextRef()
+		if matchesAny(backendRefMatchers, backendRef.GetBackendObjectReference()) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleExperimentBackendRefs adds the experiment services to backendRefs while the
+// experiment of rollout is active and removes them once it is over. It works on the
+// backendRefs of any route kind through getBackendRef and newBackendRef, since the rule
+// iterators only read backendRefs and cannot set weights or add and remove backendRefs.
+func handleExperimentBackendRefs[T any](ctx context.Context, getService ServiceGetter, logger *logrus.Entry, rollout *v1alpha1.Rollout, routeKind string, backendRefs []T, additionalDestinations []v1alpha1.WeightDestination, experimentPort *ExperimentPort, canaryMatcher backendRefMatcher, getBackendRef func(*T) *gatewayv1.BackendRef, newBackendRef func(gatewayv1.BackendRef) T) ([]T, error) {
 	isExperimentActive := rollout.Spec.Strategy.Canary != nil && rollout.Status.Canary.CurrentExperiment != ""
 
-	// previousServices are the experiment services the controller told the plugin to add
+	// previousServices match the experiment services the controller told the plugin to add
 	// on the previous reconcile, recorded in the rollout status. These are the only
 	// backends the plugin owns and may remove; any other backend in the route is managed
-	// externally and must be left untouched (issue #203).
-	previousServices := make(map[string]bool)
-	if rollout.Status.Canary.Weights != nil {
-		for _, dest := range rollout.Status.Canary.Weights.Additional {
-			previousServices[dest.ServiceName] = true
-		}
-	}
+	// externally and must be left untouched (issue #203), even when it has the name of an
+	// experiment service but another kind or namespace.
+	previousServices := getPreviousExperimentBackendRefMatchers(rollout, canaryMatcher)
 
 	hasExperimentServices := false
-	for _, backendRef := range httpRoute.Spec.Rules[ruleIdx].BackendRefs {
-		if previousServices[string(backendRef.Name)] {
+	for i := range backendRefs {
+		if matchesAny(previousServices, getBackendRef(&backendRefs[i]).BackendObjectReference) {
 			hasExperimentServices = true
 			break
 		}
@@ -59,26 +185,16 @@ func HandleExperiment(ctx context.Context, clientset *kubernetes.Clientset, gate
 
 		if len(additionalDestinations) == 0 {
 			logger.Info("No experiment services found in additionalDestinations, skipping experiment service addition")
-			return nil
-		}
-
-		// Compute total experiment weight
-		var totalExperimentWeight int32
-		for _, dest := range additionalDestinations {
-			totalExperimentWeight += dest.Weight
-		}
-
-		// Sanity cap: don't allow overflow
-		if totalExperimentWeight > 100 {
-			logger.Warnf("Total experiment weight exceeds 100 (got %d), capping at 100", totalExperimentWeight)
-			totalExperimentWeight = 100
+			return backendRefs, nil
 		}
 
-		stableWeight := int32(100) - totalExperimentWeight
-
-		for i, backendRef := range httpRoute.Spec.Rules[ruleIdx].BackendRefs {
-			if string(backendRef.Name) == stableService {
-				httpRoute.Spec.Rules[ruleIdx].BackendRefs[i].Weight = &stableWeight
+		// The weights of the stable and canary services are set by allocateWeights, which
+		// already left room for the experiment services.
+		var canaryPort *gatewayv1.PortNumber
+		for i := range backendRefs {
+			backendRef := getBackendRef(&backendRefs[i])
+			if canaryMatcher.matches(backendRef.BackendObjectReference) {
+				canaryPort = backendRef.Port
 				break
 			}
 		}
@@ -86,80 +202,102 @@ func HandleExperiment(ctx context.Context, clientset *kubernetes.Clientset, gate
 		for _, additionalDest := range additionalDestinations {
 			serviceName := additionalDest.ServiceName
 			weight := additionalDest.Weight
+			experimentMatcher := newExperimentBackendRefMatcher(serviceName, canaryMatcher)
 
 			exists := false
-			for _, backendRef := range httpRoute.Spec.Rules[ruleIdx].BackendRefs {
-				if string(backendRef.Name) == serviceName {
+			for i := range backendRefs {
+				backendRef := getBackendRef(&backendRefs[i])
+				if experimentMatcher.matches(backendRef.BackendObjectReference) {
+					backendRef.Weight = &weight
 					exists = true
 					break
 				}
 			}
 
 			if !exists {
-				logger.Info(fmt.Sprintf("Adding experiment service to HTTPRoute: %s with weight %d", serviceName, weight))
+				logger.Info(fmt.Sprintf("Adding experiment service to %s: %s with weight %d", routeKind, serviceName, weight))
 
-				service, err := clientset.CoreV1().Services(rollout.Namespace).Get(ctx, serviceName, metav1.GetOptions{})
+				// A missing Service is retried on the next reconcile, while a Service without
+				// the selected port needs a change of configuration
+				service, err := getService(ctx, rollout.Namespace, serviceName)
 				if err != nil {
 					logger.Warn(fmt.Sprintf("Failed to get service %s: %v", serviceName, err))
 					continue
 				}
 
-				port := gatewayv1.PortNumber(8080)
-				portName := "http"
-				for _, servicePort := range service.Spec.Ports {
-					if servicePort.Name == portName {
-						port = servicePort.Port
-						break
-					}
+				port, err := getExperimentServicePort(service, experimentPort, canaryPort)
+				if err != nil {
+					return backendRefs, err
 				}
 
-				if len(service.Spec.Ports) > 0 && port == 8080 {
-					port = service.Spec.Ports[0].Port
+				namespace := gatewayv1.Namespace(experimentMatcher.namespace)
+				backendObjectReference := gatewayv1.BackendObjectReference{
+					Name:      gatewayv1.ObjectName(serviceName),
+					Namespace: &namespace,
+					Port:      port,
 				}
-
-				namespace := gatewayv1.Namespace(rollout.Namespace)
-				httpRoute.Spec.Rules[ruleIdx].BackendRefs = append(httpRoute.Spec.Rules[ruleIdx].BackendRefs, gatewayv1.HTTPBackendRef{
-					BackendRef: gatewayv1.BackendRef{
-						BackendObjectReference: gatewayv1.BackendObjectReference{
-							Name:      gatewayv1.ObjectName(serviceName),
-							Namespace: &namespace,
-							Port:      &port,
-						},
-						Weight: &weight,
-					},
-				})
+				// Experiment services exported to other clusters are served like a canary
+				// ServiceImport. Experiments create Services, so they stay Services next to
+				// a canary InferencePool.
+				if experimentMatcher.kind == serviceImportKind {
+					group := gatewayv1.Group(experimentMatcher.group)
+					kind := gatewayv1.Kind(experimentMatcher.kind)
+					backendObjectReference.Group = &group
+					backendObjectReference.Kind = &kind
+				}
+				backendRefs = append(backendRefs, newBackendRef(gatewayv1.BackendRef{
+					BackendObjectReference: backendObjectReference,
+					Weight:                 &weight,
+				}))
 			}
 		}
-		return nil
+		return backendRefs, nil
 	}
 
 	if !isExperimentActive && hasExperimentServices {
-		logger.Info("Experiment is no longer active, removing experiment services from HTTPRoute")
+		logger.Info(fmt.Sprintf("Experiment is no longer active, removing experiment services from %s", routeKind))
 
-		stableWeight := int32(100)
-		filteredBackendRefs := []gatewayv1.HTTPBackendRef{}
+		filteredBackendRefs := []T{}
 
-		for _, backendRef := range httpRoute.Spec.Rules[ruleIdx].BackendRefs {
-			serviceName := string(backendRef.Name)
-
-			if previousServices[serviceName] {
-				logger.Info(fmt.Sprintf("Removing experiment service from HTTPRoute: %s", serviceName))
+		for i := range backendRefs {
+			backendRef := getBackendRef(&backendRefs[i])
+			if matchesAny(previousServices, backendRef.BackendObjectReference) {
+				logger.Info(fmt.Sprintf("Removing experiment service from %s: %s", routeKind, backendRef.Name))
 				continue
 			}
-
-			switch serviceName {
-			case stableService:
-				backendRef.Weight = &stableWeight
-			case canaryService:
-				zeroWeight := int32(0)
-				backendRef.Weight = &zeroWeight
-			}
-			filteredBackendRefs = append(filteredBackendRefs, backendRef)
+			filteredBackendRefs = append(filteredBackendRefs, backendRefs[i])
 		}
 
-		httpRoute.Spec.Rules[ruleIdx].BackendRefs = filteredBackendRefs
-		logger.Info("Experiment services removed from HTTPRoute")
+		logger.Info(fmt.Sprintf("Experiment services removed from %s", routeKind))
+		return filteredBackendRefs, nil
 	}
 
-	return nil
+	return backendRefs, nil
+}
+
+// getExperimentServicePort returns the port of service selected by experimentPort, or
+// canaryPort, the port of the canary backendRef, when experimentPort is not set. Either
+// way service must have the port. Canary backendRefs without a port, like InferencePools,
+// fall back to the only port of service.
+func getExperimentServicePort(service *corev1.Service, experimentPort *ExperimentPort, canaryPort *gatewayv1.PortNumber) (*gatewayv1.PortNumber, error) {
+	if experimentPort == nil || (experimentPort.Name == "" && experimentPort.Number == 0) {
+		if canaryPort == nil {
+			if len(service.Spec.Ports) != 1 {
+				return nil, newPluginError("CanaryBackendRefPortWasNotFoundError", CanaryBackendRefPortWasNotFoundError, service.Name)
+			}
+			port := service.Spec.Ports[0].Port
+			return &port, nil
+		}
+		experimentPort = &ExperimentPort{Number: int32(*canaryPort)}
+	}
+	for _, servicePort := range service.Spec.Ports {
+		if (experimentPort.Name != "" && servicePort.Name == experimentPort.Name) || (experimentPort.Name == "" && servicePort.Port == experimentPort.Number) {
+			port := servicePort.Port
+			return &port, nil
+		}
+	}
+	if experimentPort.Name != "" {
+		return nil, newPluginError("ExperimentServicePortWasNotFoundError", ExperimentServicePortWasNotFoundError, service.Name, fmt.Sprintf("%q", experimentPort.Name))
+	}
+	return nil, newPluginError("ExperimentServicePortWasNotFoundError", ExperimentServicePortWasNotFoundError, service.Name, fmt.Sprint(experimentPort.Number))
 }
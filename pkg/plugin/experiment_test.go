@@ -1,10 +1,17 @@
 package plugin
 
 import (
+	"context"
+	"fmt"
 	"testing"
 
 	"github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
 )
@@ -223,3 +230,334 @@ func TestHandleExperiment_RemoveExperimentServices(t *testing.T) {
 	}
 	assert.False(t, hasExperimentServices, "HTTPRoute should not have experiment services after cleanup")
 }
+
+func TestHandleGRPCExperiment(t *testing.T) {
+	stableService := "stable-svc"
+	canaryService := "canary-svc"
+	externalService := "external-svc"
+	experimentService := "exp-svc"
+
+	rollout := &v1alpha1.Rollout{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "rollout-test",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.RolloutSpec{
+			Strategy: v1alpha1.RolloutStrategy{
+				Canary: &v1alpha1.CanaryStrategy{
+					StableService:  stableService,
+					CanaryService:  canaryService,
+					TrafficRouting: &v1alpha1.RolloutTrafficRouting{},
+				},
+			},
+		},
+		Status: v1alpha1.RolloutStatus{
+			Canary: v1alpha1.CanaryStatus{
+				CurrentExperiment: "active-experiment",
+			},
+		},
+	}
+
+	stableWeight := int32(100)
+	canaryWeight := int32(0)
+	externalWeight := int32(0)
+	grpcRoute := &gatewayv1.GRPCRoute{
+		Spec: gatewayv1.GRPCRouteSpec{
+			Rules: []gatewayv1.GRPCRouteRule{
+				{
+					BackendRefs: []gatewayv1.GRPCBackendRef{
+						{
+							BackendRef: gatewayv1.BackendRef{
+								BackendObjectReference: gatewayv1.BackendObjectReference{
+									Name: gatewayv1.ObjectName(stableService),
+								},
+								Weight: &stableWeight,
+							},
+						},
+						{
+							BackendRef: gatewayv1.BackendRef{
+								BackendObjectReference: gatewayv1.BackendObjectReference{
+									Name: gatewayv1.ObjectName(canaryService),
+								},
+								Weight: &canaryWeight,
+							},
+						},
+						{
+							BackendRef: gatewayv1.BackendRef{
+								BackendObjectReference: gatewayv1.BackendObjectReference{
+									Name: gatewayv1.ObjectName(externalService),
+								},
+								Weight: &externalWeight,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	getService := func(ctx context.Context, namespace, name string) (*corev1.Service, error) {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec: corev1.ServiceSpec{
+				Ports: []corev1.ServicePort{{Name: "grpc", Port: 9090}},
+			},
+		}, nil
+	}
+	additionalDestinations := []v1alpha1.WeightDestination{
+		{
+			ServiceName: experimentService,
+			Weight:      20,
+		},
+	}
+	logger := logrus.NewEntry(logrus.New())
+
+	err := HandleGRPCExperiment(context.Background(), getService, logger, rollout, grpcRoute, additionalDestinations, &ExperimentPort{Name: "grpc"}, routeTarget{namespace: rollout.Namespace}, &GatewayAPITrafficRouting{Namespace: rollout.Namespace})
+	require.NoError(t, err)
+	backendRefs := grpcRoute.Spec.Rules[0].BackendRefs
+	require.Len(t, backendRefs, 4)
+	// The stable weight is left to allocateWeights
+	assert.Equal(t, int32(100), *backendRefs[0].Weight)
+	assert.Equal(t, gatewayv1.ObjectName(experimentService), backendRefs[3].Name)
+	assert.Equal(t, int32(20), *backendRefs[3].Weight)
+	assert.Equal(t, gatewayv1.PortNumber(9090), *backendRefs[3].Port)
+
+	// Only the services recorded in the rollout status are removed once the experiment is over
+	rollout.Status.Canary.CurrentExperiment = ""
+	rollout.Status.Canary.Weights = &v1alpha1.TrafficWeights{
+		Additional: additionalDestinations,
+	}
+	err = HandleGRPCExperiment(context.Background(), getService, logger, rollout, grpcRoute, nil, nil, routeTarget{namespace: rollout.Namespace}, &GatewayAPITrafficRouting{Namespace: rollout.Namespace})
+	require.NoError(t, err)
+	backendRefs = grpcRoute.Spec.Rules[0].BackendRefs
+	require.Len(t, backendRefs, 3)
+	assert.Equal(t, gatewayv1.ObjectName(stableService), backendRefs[0].Name)
+	assert.Equal(t, gatewayv1.ObjectName(canaryService), backendRefs[1].Name)
+	assert.Equal(t, gatewayv1.ObjectName(externalService), backendRefs[2].Name)
+}
+
+func TestHandleExperimentServiceImport(t *testing.T) {
+	stableService := "stable-svc"
+	canaryService := "canary-svc"
+	experimentService := "exp-svc"
+
+	rollout := &v1alpha1.Rollout{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "rollout-test",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.RolloutSpec{
+			Strategy: v1alpha1.RolloutStrategy{
+				Canary: &v1alpha1.CanaryStrategy{
+					StableService:  stableService,
+					CanaryService:  canaryService,
+					TrafficRouting: &v1alpha1.RolloutTrafficRouting{},
+				},
+			},
+		},
+		Status: v1alpha1.RolloutStatus{
+			Canary: v1alpha1.CanaryStatus{
+				CurrentExperiment: "active-experiment",
+			},
+		},
+	}
+
+	serviceImportGroup := gatewayv1.Group("multicluster.x-k8s.io")
+	serviceImportKind := gatewayv1.Kind("ServiceImport")
+	port := gatewayv1.PortNumber(8080)
+	stableWeight := int32(100)
+	canaryWeight := int32(0)
+	httpRoute := &gatewayv1.HTTPRoute{
+		Spec: gatewayv1.HTTPRouteSpec{
+			Rules: []gatewayv1.HTTPRouteRule{
+				{
+					BackendRefs: []gatewayv1.HTTPBackendRef{
+						{
+							BackendRef: gatewayv1.BackendRef{
+								BackendObjectReference: gatewayv1.BackendObjectReference{
+									Group: &serviceImportGroup,
+									Kind:  &serviceImportKind,
+									Name:  gatewayv1.ObjectName(stableService),
+									Port:  &port,
+								},
+								Weight: &stableWeight,
+							},
+						},
+						{
+							BackendRef: gatewayv1.BackendRef{
+								BackendObjectReference: gatewayv1.BackendObjectReference{
+									Group: &serviceImportGroup,
+									Kind:  &serviceImportKind,
+									Name:  gatewayv1.ObjectName(canaryService),
+									Port:  &port,
+								},
+								Weight: &canaryWeight,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	getService := func(ctx context.Context, namespace, name string) (*corev1.Service, error) {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec: corev1.ServiceSpec{
+				Ports: []corev1.ServicePort{{Name: "http", Port: 8080}},
+			},
+		}, nil
+	}
+	gatewayAPIConfig := &GatewayAPITrafficRouting{
+		Namespace:        rollout.Namespace,
+		StableBackendRef: &BackendRefMatch{Kind: "ServiceImport"},
+		CanaryBackendRef: &BackendRefMatch{Kind: "ServiceImport"},
+	}
+	additionalDestinations := []v1alpha1.WeightDestination{
+		{
+			ServiceName: experimentService,
+			Weight:      20,
+		},
+	}
+
+	err := HandleExperiment(context.Background(), getService, nil, logrus.NewEntry(logrus.New()), rollout, httpRoute, additionalDestinations, nil, routeTarget{namespace: rollout.Namespace}, gatewayAPIConfig)
+	require.NoError(t, err)
+	backendRefs := httpRoute.Spec.Rules[0].BackendRefs
+	require.Len(t, backendRefs, 3)
+	assert.Equal(t, gatewayv1.ObjectName(experimentService), backendRefs[2].Name)
+	assert.Equal(t, serviceImportGroup, *backendRefs[2].Group)
+	assert.Equal(t, serviceImportKind, *backendRefs[2].Kind)
+	assert.Equal(t, port, *backendRefs[2].Port)
+}
+
+func TestHandleExperimentServiceNotFound(t *testing.T) {
+	rollout := newRollout("stable-svc", "canary-svc", &GatewayAPITrafficRouting{Namespace: "default"})
+	rollout.Status.Canary.CurrentExperiment = "active-experiment"
+	stableWeight := int32(100)
+	canaryWeight := int32(0)
+	backendRefs := []gatewayv1.BackendRef{
+		{
+			BackendObjectReference: gatewayv1.BackendObjectReference{Name: "stable-svc"},
+			Weight:                 &stableWeight,
+		},
+		{
+			BackendObjectReference: gatewayv1.BackendObjectReference{Name: "canary-svc"},
+			Weight:                 &canaryWeight,
+		},
+	}
+	getService := func(ctx context.Context, namespace, name string) (*corev1.Service, error) {
+		return nil, apierrors.NewNotFound(corev1.Resource("services"), name)
+	}
+	additionalDestinations := []v1alpha1.WeightDestination{
+		{
+			ServiceName: "exp-svc",
+			Weight:      20,
+		},
+	}
+	canaryMatcher := newBackendRefMatcher("canary-svc", nil, rollout.Namespace, rollout.Namespace)
+
+	// A missing experiment service is skipped with a warning and added on a later reconcile
+	logger, logHook := logtest.NewNullLogger()
+	updatedBackendRefs, err := handleExperimentBackendRefs(context.Background(), getService, logrus.NewEntry(logger), rollout, "TCPRoute", backendRefs, additionalDestinations, nil, canaryMatcher, getExperimentBackendRef, newExperimentBackendRef)
+	require.NoError(t, err)
+	assert.Len(t, updatedBackendRefs, 2)
+	require.NotNil(t, logHook.LastEntry())
+	assert.Equal(t, logrus.WarnLevel, logHook.LastEntry().Level)
+}
+
+func TestHandleExperimentForeignBackendWithExperimentName(t *testing.T) {
+	rollout := newRollout("stable-svc", "canary-svc", &GatewayAPITrafficRouting{Namespace: "default"})
+	rollout.Status.Canary.CurrentExperiment = "active-experiment"
+	stableWeight := int32(80)
+	canaryWeight := int32(0)
+	foreignWeight := int32(5)
+	otherNamespace := gatewayv1.Namespace("other")
+	port := gatewayv1.PortNumber(80)
+	backendRefs := []gatewayv1.BackendRef{
+		{
+			BackendObjectReference: gatewayv1.BackendObjectReference{Name: "stable-svc", Port: &port},
+			Weight:                 &stableWeight,
+		},
+		{
+			BackendObjectReference: gatewayv1.BackendObjectReference{Name: "canary-svc", Port: &port},
+			Weight:                 &canaryWeight,
+		},
+		{
+			BackendObjectReference: gatewayv1.BackendObjectReference{Name: "exp-svc", Namespace: &otherNamespace, Port: &port},
+			Weight:                 &foreignWeight,
+		},
+	}
+	getService := func(ctx context.Context, namespace, name string) (*corev1.Service, error) {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 80}}},
+		}, nil
+	}
+	additionalDestinations := []v1alpha1.WeightDestination{{ServiceName: "exp-svc", Weight: 20}}
+	canaryMatcher := newBackendRefMatcher("canary-svc", nil, rollout.Namespace, rollout.Namespace)
+	logger := logrus.NewEntry(logrus.New())
+
+	// The Service of the same name in another namespace keeps its weight and the experiment
+	// service is added next to it
+	backendRefs, err := handleExperimentBackendRefs(context.Background(), getService, logger, rollout, "TCPRoute", backendRefs, additionalDestinations, nil, canaryMatcher, getExperimentBackendRef, newExperimentBackendRef)
+	require.NoError(t, err)
+	require.Len(t, backendRefs, 4)
+	assert.Equal(t, int32(5), *backendRefs[2].Weight)
+	assert.Equal(t, gatewayv1.Namespace(rollout.Namespace), *backendRefs[3].Namespace)
+	assert.Equal(t, int32(20), *backendRefs[3].Weight)
+
+	// Once the experiment is over only the experiment service is removed
+	rollout.Status.Canary.CurrentExperiment = ""
+	rollout.Status.Canary.Weights = &v1alpha1.TrafficWeights{Additional: additionalDestinations}
+	backendRefs, err = handleExperimentBackendRefs(context.Background(), getService, logger, rollout, "TCPRoute", backendRefs, nil, nil, canaryMatcher, getExperimentBackendRef, newExperimentBackendRef)
+	require.NoError(t, err)
+	require.Len(t, backendRefs, 3)
+	assert.Equal(t, &otherNamespace, backendRefs[2].Namespace)
+}
+
+func TestGetExperimentServicePort(t *testing.T) {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "exp-svc"},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{{Name: "http", Port: 8080}, {Name: "metrics", Port: 9090}},
+		},
+	}
+	canaryPort := gatewayv1.PortNumber(8080)
+
+	t.Run("ByName", func(t *testing.T) {
+		port, err := getExperimentServicePort(service, &ExperimentPort{Name: "metrics"}, &canaryPort)
+		require.NoError(t, err)
+		assert.Equal(t, gatewayv1.PortNumber(9090), *port)
+	})
+	t.Run("ByNumber", func(t *testing.T) {
+		port, err := getExperimentServicePort(service, &ExperimentPort{Number: 9090}, &canaryPort)
+		require.NoError(t, err)
+		assert.Equal(t, gatewayv1.PortNumber(9090), *port)
+	})
+	t.Run("CopiesCanaryPort", func(t *testing.T) {
+		port, err := getExperimentServicePort(service, nil, &canaryPort)
+		require.NoError(t, err)
+		assert.Equal(t, canaryPort, *port)
+		// The port is a copy, so the canary backendRef is not changed through it
+		*port = 80
+		assert.Equal(t, gatewayv1.PortNumber(8080), canaryPort)
+	})
+	t.Run("MissingPort", func(t *testing.T) {
+		_, err := getExperimentServicePort(service, &ExperimentPort{Name: "grpc"}, &canaryPort)
+		assert.EqualError(t, err, fmt.Sprintf(ExperimentServicePortWasNotFoundError, "exp-svc", `"grpc"`))
+		_, err = getExperimentServicePort(service, &ExperimentPort{Number: 8443}, &canaryPort)
+		assert.EqualError(t, err, fmt.Sprintf(ExperimentServicePortWasNotFoundError, "exp-svc", "8443"))
+	})
+	t.Run("MissingCanaryPort", func(t *testing.T) {
+		otherCanaryPort := gatewayv1.PortNumber(80)
+		_, err := getExperimentServicePort(service, nil, &otherCanaryPort)
+		assert.EqualError(t, err, fmt.Sprintf(ExperimentServicePortWasNotFoundError, "exp-svc", "80"))
+		_, err = getExperimentServicePort(service, &ExperimentPort{}, nil)
+		assert.EqualError(t, err, fmt.Sprintf(CanaryBackendRefPortWasNotFoundError, "exp-svc"))
+	})
+	t.Run("OnlyServicePortWithoutCanaryPort", func(t *testing.T) {
+		singlePortService := service.DeepCopy()
+		singlePortService.Spec.Ports = singlePortService.Spec.Ports[1:]
+		port, err := getExperimentServicePort(singlePortService, nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, gatewayv1.PortNumber(9090), *port)
+	})
+}
@@ -2,197 +2,528 @@ package plugin
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"sort"
 
 	"github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
-	pluginTypes "github.com/argoproj/argo-rollouts/utils/plugin/types"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/util/retry"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+
+	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-gatewayapi/internal/defaults"
 )
 
-func (r *RpcPlugin) setGRPCRouteWeight(rollout *v1alpha1.Rollout, desiredWeight int32, gatewayAPIConfig *GatewayAPITrafficRouting) pluginTypes.RpcError {
+func (r *RpcPlugin) setGRPCRouteWeight(rollout *v1alpha1.Rollout, desiredWeight int32, additionalDestinations []v1alpha1.WeightDestination, experimentPort *ExperimentPort, target routeTarget, gatewayAPIConfig *GatewayAPITrafficRouting) error {
 	ctx := context.TODO()
-	grpcRouteClient := r.GatewayAPIClientset.GatewayV1().GRPCRoutes(gatewayAPIConfig.Namespace)
+	grpcRouteClient := r.GatewayAPIClientset.GatewayV1().GRPCRoutes(target.namespace)
 
-	canaryServiceName := rollout.Spec.Strategy.Canary.CanaryService
-	stableServiceName := rollout.Spec.Strategy.Canary.StableService
-	canaryServiceObjName := gatewayv1.ObjectName(canaryServiceName)
-	restWeight := 100 - desiredWeight
+	canaryMatcher := gatewayAPIConfig.getCanaryBackendRefMatcher(rollout, target.namespace)
+	stableMatcher := gatewayAPIConfig.getStableBackendRefMatcher(rollout, target.namespace)
+	canaryServiceName := canaryMatcher.name
+	stableServiceName := stableMatcher.name
 	managedNames := managedRouteNamesSet(rollout)
+	experimentDestinations := getExperimentDestinations(rollout, additionalDestinations)
+	ownedMatchers := gatewayAPIConfig.getOwnedBackendRefMatchers(rollout, target.namespace, experimentDestinations)
+
+	err := retryOnConflict(grpcRouteGVK, func(useCache bool) error {
+		grpcRoute, err := r.getGRPCRoute(ctx, target.namespace, gatewayAPIConfig.GRPCRoute, useCache)
+		if err != nil {
+			return err
+		}
+		originalSpec := grpcRoute.Spec.DeepCopy()
 
-	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-		grpcRoute, err := grpcRouteClient.Get(ctx, gatewayAPIConfig.GRPCRoute, metav1.GetOptions{})
+		isRestored, err := syncRouteSpecSnapshot(r, grpcRoute, &grpcRoute.Spec, rollout, desiredWeight, gatewayAPIConfig)
 		if err != nil {
 			return err
 		}
+		if isRestored {
+			r.LogCtx.Info(fmt.Sprintf("[SetWeight] restoring original spec of GRPCRoute %q", grpcRoute.Name))
+			ensureInProgressLabel(grpcRoute, desiredWeight, gatewayAPIConfig)
+			if r.isDryRun(gatewayAPIConfig) {
+				return r.logDryRun(grpcRouteGVK, grpcRoute, originalSpec, &grpcRoute.Spec)
+			}
+			if err = updateRoute(ctx, grpcRouteClient, grpcRoute, grpcRoute.Spec.Rules, grpcRouteGVK, gatewayAPIConfig); err != nil {
+				return err
+			}
+			r.recordEvent(grpcRoute, corev1.EventTypeNormal, OriginalSpecRestoredReason, "Restored the spec saved before the canary started")
+			return nil
+		}
+
+		if err = target.checkRules(grpcRouteGVK.Kind, grpcRoute.Name, getGRPCRouteRuleNames(grpcRoute.Spec.Rules)); err != nil {
+			return err
+		}
 
 		canaryFound, stableFound := false, false
+		var allocation weightAllocation
 		for i := range grpcRoute.Spec.Rules {
 			// Skip plugin-injected header-routing rules.
 			// Primary: rule carries a Name matching a known managed route.
 			// Fallback: structural check (single canary-only BackendRef) for rules injected
 			// by older plugin versions that did not set the Name field.
+			// Managed mirror rules keep the source rule's backends, so they follow the
+			// same weight split as the rule they were copied from.
 			rule := grpcRoute.Spec.Rules[i]
-			if (rule.Name != nil && isManagedRuleName(string(*rule.Name), managedNames)) || isGRPCManagedRule(rule, canaryServiceObjName, nil) {
+			if isGRPCHeaderRouteRule(rule, canaryMatcher, managedNames) {
+				continue
+			}
+			if !isGRPCRuleTargeted(i, grpcRoute.Spec.Rules[i], managedNames, target) || !hasRolloutBackendRef[*GRPCBackendRef]((*GRPCRouteRule)(&grpcRoute.Spec.Rules[i]), canaryMatcher, stableMatcher) {
 				continue
 			}
+			allocation, err = allocateWeights(desiredWeight, experimentDestinations, getUnmanagedWeight(grpcRoute.Spec.Rules[i].BackendRefs, getGRPCExperimentBackendRef, ownedMatchers))
+			if err != nil {
+				return err
+			}
+			r.warnUnmanagedWeight(grpcRouteGVK.Kind, grpcRoute.Name, i, allocation)
+			canaryWeight, stableWeight := allocation.canaryWeight, allocation.stableWeight
 			for j := range grpcRoute.Spec.Rules[i].BackendRefs {
-				switch string(grpcRoute.Spec.Rules[i].BackendRefs[j].Name) {
-				case canaryServiceName:
-					grpcRoute.Spec.Rules[i].BackendRefs[j].Weight = &desiredWeight
+				backendRef := grpcRoute.Spec.Rules[i].BackendRefs[j].BackendObjectReference
+				switch {
+				case canaryMatcher.matches(backendRef):
+					grpcRoute.Spec.Rules[i].BackendRefs[j].Weight = &canaryWeight
 					canaryFound = true
-				case stableServiceName:
-					grpcRoute.Spec.Rules[i].BackendRefs[j].Weight = &restWeight
+				case stableMatcher.matches(backendRef):
+					grpcRoute.Spec.Rules[i].BackendRefs[j].Weight = &stableWeight
 					stableFound = true
 				}
 			}
 		}
 		if !canaryFound || !stableFound {
-			return errors.New(BackendRefWasNotFoundInGRPCRouteError)
+			return newPluginError("BackendRefWasNotFoundInGRPCRouteError", BackendRefWasNotFoundInGRPCRouteError)
+		}
+
+		previousBackendRefs := getGRPCBackendRefKeys(grpcRoute.Spec.Rules, target.namespace)
+		if err = HandleGRPCExperiment(ctx, r.getService, r.LogCtx, rollout, grpcRoute, additionalDestinations, experimentPort, target, gatewayAPIConfig); err != nil {
+			return err
 		}
 
 		ensureInProgressLabel(grpcRoute, desiredWeight, gatewayAPIConfig)
 
-		_, err = grpcRouteClient.Update(ctx, grpcRoute, metav1.UpdateOptions{})
-		return err
+		if r.isDryRun(gatewayAPIConfig) {
+			return r.logDryRun(grpcRouteGVK, grpcRoute, originalSpec, &grpcRoute.Spec)
+		}
+		if err = updateRoute(ctx, grpcRouteClient, grpcRoute, grpcRoute.Spec.Rules, grpcRouteGVK, gatewayAPIConfig); err != nil {
+			return err
+		}
+		if !equality.Semantic.DeepEqual(originalSpec.Rules, grpcRoute.Spec.Rules) {
+			r.recordEvent(grpcRoute, corev1.EventTypeNormal, WeightUpdatedReason, "Set weight of canary service %q to %d and stable service %q to %d", canaryServiceName, allocation.canaryWeight, stableServiceName, allocation.stableWeight)
+		}
+		r.recordExperimentBackendEvents(grpcRoute, previousBackendRefs, getGRPCBackendRefKeys(grpcRoute.Spec.Rules, target.namespace))
+		return nil
 	})
 
 	if err != nil {
-		return pluginTypes.RpcError{
-			ErrorString: err.Error(),
+		r.recordRouteFailure(grpcRouteGVK, target.namespace, gatewayAPIConfig.GRPCRoute, "SetWeight", err)
+		return err
+	}
+	r.recordCanaryWeight(rollout, grpcRouteGVK.Kind, target.namespace, gatewayAPIConfig.GRPCRoute, desiredWeight, gatewayAPIConfig)
+	return nil
+}
+
+func (r *RpcPlugin) verifyGRPCRouteWeight(rollout *v1alpha1.Rollout, desiredWeight int32, additionalDestinations []v1alpha1.WeightDestination, target routeTarget, gatewayAPIConfig *GatewayAPITrafficRouting) (bool, error) {
+	ctx := context.TODO()
+	allocation, err := allocateWeights(desiredWeight, getExperimentDestinations(rollout, additionalDestinations), 0)
+	if err != nil {
+		return false, err
+	}
+
+	canaryMatcher := gatewayAPIConfig.getCanaryBackendRefMatcher(rollout, target.namespace)
+	stableMatcher := gatewayAPIConfig.getStableBackendRefMatcher(rollout, target.namespace)
+	managedNames := managedRouteNamesSet(rollout)
+
+	grpcRoute, err := r.getGRPCRoute(ctx, target.namespace, gatewayAPIConfig.GRPCRoute, true)
+	if err != nil {
+		return false, err
+	}
+
+	canaryFound, stableFound := false, false
+	for i, rule := range grpcRoute.Spec.Rules {
+		if isGRPCHeaderRouteRule(rule, canaryMatcher, managedNames) {
+			continue
+		}
+		if !isGRPCRuleTargeted(i, rule, managedNames, target) {
+			continue
+		}
+		for _, backendRef := range rule.BackendRefs {
+			switch {
+			case canaryMatcher.matches(backendRef.BackendObjectReference):
+				canaryFound = true
+				if !isWeightEqual(backendRef.Weight, desiredWeight) {
+					r.LogCtx.Info(fmt.Sprintf("[VerifyWeight] GRPCRoute %q canary weight is not %d yet", grpcRoute.Name, desiredWeight))
+					return false, nil
+				}
+			case stableMatcher.matches(backendRef.BackendObjectReference):
+				stableFound = true
+				if !isWeightEqual(backendRef.Weight, allocation.stableWeight) {
+					r.LogCtx.Info(fmt.Sprintf("[VerifyWeight] GRPCRoute %q stable weight is not %d yet", grpcRoute.Name, allocation.stableWeight))
+					return false, nil
+				}
+			}
 		}
 	}
-	return pluginTypes.RpcError{}
+	if !canaryFound || !stableFound {
+		return false, newPluginError("BackendRefWasNotFoundInGRPCRouteError", BackendRefWasNotFoundInGRPCRouteError)
+	}
+
+	if isAccepted, reason := isRouteAccepted(grpcRoute.Generation, grpcRoute.Status.RouteStatus); !isAccepted {
+		r.LogCtx.Info(fmt.Sprintf("[VerifyWeight] GRPCRoute %q is not accepted yet: %s", grpcRoute.Name, reason))
+		return false, nil
+	}
+	return true, nil
 }
 
-func (r *RpcPlugin) setGRPCHeaderRoute(rollout *v1alpha1.Rollout, headerRouting *v1alpha1.SetHeaderRoute, gatewayAPIConfig *GatewayAPITrafficRouting) pluginTypes.RpcError {
+func (r *RpcPlugin) setGRPCHeaderRoute(rollout *v1alpha1.Rollout, headerRouting *v1alpha1.SetHeaderRoute, target routeTarget, gatewayAPIConfig *GatewayAPITrafficRouting) error {
 	if headerRouting.Match == nil {
-		return r.removeGRPCManagedRoutes(rollout, gatewayAPIConfig)
+		return r.removeGRPCManagedRoutes(rollout, target, gatewayAPIConfig)
 	}
-	ctx := context.TODO()
-	grpcRouteClient := r.GatewayAPIClientset.GatewayV1().GRPCRoutes(gatewayAPIConfig.Namespace)
-	grpcHeaderRouteRuleList, rpcError := getGRPCHeaderRouteRuleList(headerRouting)
-	if rpcError.HasError() {
-		return rpcError
+	grpcHeaderRouteRuleList, err := getGRPCHeaderRouteRuleList(headerRouting)
+	if err != nil {
+		return err
+	}
+	canaryMatcher := gatewayAPIConfig.getCanaryBackendRefMatcher(rollout, target.namespace)
+
+	return r.setGRPCManagedRoute(rollout, headerRouting.Name, target, gatewayAPIConfig, grpcManagedRouteBuilder{
+		operation:   "SetHeaderRoute",
+		routeType:   "header",
+		eventReason: HeaderRouteAddedReason,
+		getBackendRefs: func(_ *GRPCRouteRule, canaryBackendRef gatewayv1.BackendObjectReference) []gatewayv1.GRPCBackendRef {
+			return []gatewayv1.GRPCBackendRef{
+				{
+					BackendRef: gatewayv1.BackendRef{
+						BackendObjectReference: canaryBackendRef,
+					},
+				},
+			}
+		},
+		// Copy matches from original route and merge headers
+		getMatches: func(sourceRule *GRPCRouteRule) []gatewayv1.GRPCRouteMatch {
+			return mergeGRPCRouteMatches(sourceRule.Matches, [][]gatewayv1.GRPCHeaderMatch{grpcHeaderRouteRuleList})
+		},
+		isUnnamedManagedRule: func(rule gatewayv1.GRPCRouteRule) bool {
+			return isGRPCManagedRule(rule, canaryMatcher, grpcHeaderRouteRuleList)
+		},
+	})
+}
+
+func (r *RpcPlugin) setGRPCMirrorRoute(rollout *v1alpha1.Rollout, setMirrorRoute *v1alpha1.SetMirrorRoute, target routeTarget, gatewayAPIConfig *GatewayAPITrafficRouting) error {
+	if setMirrorRoute.Match == nil {
+		return r.removeGRPCMirrorRoute(rollout, setMirrorRoute.Name, target, gatewayAPIConfig)
+	}
+	grpcMirrorRouteHeaderList, err := getGRPCMirrorRouteHeaderList(setMirrorRoute)
+	if err != nil {
+		return err
 	}
 
-	canaryServiceName := gatewayv1.ObjectName(rollout.Spec.Strategy.Canary.CanaryService)
-	stableServiceName := rollout.Spec.Strategy.Canary.StableService
-	managedName := gatewayv1.SectionName(headerRouting.Name)
+	// The mirror rule keeps the source rule's backends so that mirrored requests are still
+	// served by the current stable/canary split.
+	return r.setGRPCManagedRoute(rollout, setMirrorRoute.Name, target, gatewayAPIConfig, grpcManagedRouteBuilder{
+		operation:   "SetMirrorRoute",
+		routeType:   "mirror",
+		eventReason: MirrorRouteAddedReason,
+		getBackendRefs: func(sourceRule *GRPCRouteRule, _ gatewayv1.BackendObjectReference) []gatewayv1.GRPCBackendRef {
+			backendRefs := make([]gatewayv1.GRPCBackendRef, 0, len(sourceRule.BackendRefs))
+			for i := 0; i < len(sourceRule.BackendRefs); i++ {
+				backendRefs = append(backendRefs, *sourceRule.BackendRefs[i].DeepCopy())
+			}
+			return backendRefs
+		},
+		getFilters: func(canaryBackendRef gatewayv1.BackendObjectReference) []gatewayv1.GRPCRouteFilter {
+			return []gatewayv1.GRPCRouteFilter{
+				{
+					Type: gatewayv1.GRPCRouteFilterRequestMirror,
+					RequestMirror: &gatewayv1.HTTPRequestMirrorFilter{
+						BackendRef: canaryBackendRef,
+						Percent:    setMirrorRoute.Percentage,
+					},
+				},
+			}
+		},
+		// Copy matches from original route and merge the mirror headers
+		getMatches: func(sourceRule *GRPCRouteRule) []gatewayv1.GRPCRouteMatch {
+			return mergeGRPCRouteMatches(sourceRule.Matches, grpcMirrorRouteHeaderList)
+		},
+	})
+}
+
+// grpcManagedRouteBuilder is httpManagedRouteBuilder for GRPCRoutes.
+type grpcManagedRouteBuilder struct {
+	operation            string
+	routeType            string
+	eventReason          string
+	getBackendRefs       func(sourceRule *GRPCRouteRule, canaryBackendRef gatewayv1.BackendObjectReference) []gatewayv1.GRPCBackendRef
+	getFilters           func(canaryBackendRef gatewayv1.BackendObjectReference) []gatewayv1.GRPCRouteFilter
+	getMatches           func(sourceRule *GRPCRouteRule) []gatewayv1.GRPCRouteMatch
+	isUnnamedManagedRule func(rule gatewayv1.GRPCRouteRule) bool
+}
 
-	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-		grpcRoute, err := grpcRouteClient.Get(ctx, gatewayAPIConfig.GRPCRoute, metav1.GetOptions{})
+// setGRPCManagedRoute replaces the rules of the managed route managedName with the rules
+// builder builds from the current rules of the GRPCRoute.
+func (r *RpcPlugin) setGRPCManagedRoute(rollout *v1alpha1.Rollout, managedName string, target routeTarget, gatewayAPIConfig *GatewayAPITrafficRouting, builder grpcManagedRouteBuilder) error {
+	ctx := context.TODO()
+	grpcRouteClient := r.GatewayAPIClientset.GatewayV1().GRPCRoutes(target.namespace)
+	canaryMatcher := gatewayAPIConfig.getCanaryBackendRefMatcher(rollout, target.namespace)
+	stableMatcher := gatewayAPIConfig.getStableBackendRefMatcher(rollout, target.namespace)
+	managedRuleName := gatewayv1.SectionName(managedName)
+	managedNames := managedRouteNamesSet(rollout)
+
+	err := retryOnConflictOrStale(grpcRouteGVK, func(useCache bool) error {
+		grpcRoute, err := r.getGRPCRoute(ctx, target.namespace, gatewayAPIConfig.GRPCRoute, useCache)
 		if err != nil {
 			return err
 		}
+		originalSpec := grpcRoute.Spec.DeepCopy()
+		originalSnapshots := grpcRoute.Annotations[defaults.OriginalSpecAnnotationKey]
+		if err = ensureRouteSpecSnapshot(r, grpcRoute, &grpcRoute.Spec, rollout, gatewayAPIConfig); err != nil {
+			return err
+		}
 
-		canaryServiceKind := gatewayv1.Kind("Service")
-		canaryServiceGroup := gatewayv1.Group("")
 		grpcRouteRuleList := GRPCRouteRuleList(grpcRoute.Spec.Rules)
-		backendRefNameList := []string{string(canaryServiceName), stableServiceName}
-		sourceRules, err := getAllRouteRules(grpcRouteRuleList, backendRefNameList...)
+		if err = target.checkRules(grpcRouteGVK.Kind, grpcRoute.Name, getGRPCRouteRuleNames(grpcRoute.Spec.Rules)); err != nil {
+			return err
+		}
+		sourceRules, err := getAllRouteRules(getUnmanagedGRPCRouteRules(target.getTargetedGRPCRouteRules(grpcRouteRuleList), managedNames), canaryMatcher, stableMatcher)
 		if err != nil {
 			return err
 		}
 
-		// Build one managed header rule per source rule so that the canary header
-		// applies to every rule on a multi-rule GRPCRoute (issue #207).
-		// Each rule needs a unique name within the route (Gateway API constraint).
-		// Index 0 keeps the bare managedName for backward compatibility with single-rule routes;
-		// subsequent rules are named managedName-1, managedName-2, etc.
+		// Build one managed rule per source rule, named like the rules of HTTPRoutes
 		newManagedRules := make([]gatewayv1.GRPCRouteRule, 0, len(sourceRules))
-		for idx, grpcRouteRule := range sourceRules {
-			var canaryBackendRef *GRPCBackendRef
-			for i := 0; i < len(grpcRouteRule.BackendRefs); i++ {
-				backendRef := grpcRouteRule.BackendRefs[i]
-				if canaryServiceName == backendRef.Name {
-					canaryBackendRef = (*GRPCBackendRef)(&backendRef)
-					break
-				}
+		for idx, sourceRule := range sourceRules {
+			matches := builder.getMatches(sourceRule)
+			if len(matches) == 0 {
+				continue
 			}
-			ruleName := managedName
+			canaryBackendRef := getCanaryBackendObjectReference[*GRPCBackendRef](sourceRule, canaryMatcher)
+			ruleName := managedRuleName
 			if idx > 0 {
-				ruleName = gatewayv1.SectionName(fmt.Sprintf("%s-%d", managedName, idx))
+				ruleName = gatewayv1.SectionName(fmt.Sprintf("%s-%d", managedRuleName, idx))
 			}
-			grpcHeaderRouteRule := gatewayv1.GRPCRouteRule{
-				Name:    &ruleName,
-				Matches: []gatewayv1.GRPCRouteMatch{},
-				Filters: []gatewayv1.GRPCRouteFilter{},
-				BackendRefs: []gatewayv1.GRPCBackendRef{
-					{
-						BackendRef: gatewayv1.BackendRef{
-							BackendObjectReference: gatewayv1.BackendObjectReference{
-								Group: &canaryServiceGroup,
-								Kind:  &canaryServiceKind,
-								Name:  canaryServiceName,
-								Port:  canaryBackendRef.Port,
-							},
-						},
-					},
-				},
+			managedRule := gatewayv1.GRPCRouteRule{
+				Name:        &ruleName,
+				Matches:     matches,
+				Filters:     make([]gatewayv1.GRPCRouteFilter, 0, len(sourceRule.Filters)),
+				BackendRefs: builder.getBackendRefs(sourceRule, canaryBackendRef),
 			}
-
 			// Copy filters from original route
-			if grpcRouteRule.Filters != nil {
-				for i := 0; i < len(grpcRouteRule.Filters); i++ {
-					grpcHeaderRouteRule.Filters = append(grpcHeaderRouteRule.Filters, *grpcRouteRule.Filters[i].DeepCopy())
-				}
+			for i := 0; i < len(sourceRule.Filters); i++ {
+				managedRule.Filters = append(managedRule.Filters, *sourceRule.Filters[i].DeepCopy())
 			}
-
-			// Copy matches from original route and merge headers
-			if len(grpcRouteRule.Matches) == 0 {
-				grpcHeaderRouteRule.Matches = []gatewayv1.GRPCRouteMatch{
-					{Headers: grpcHeaderRouteRuleList},
-				}
-			} else {
-				for i := range len(grpcRouteRule.Matches) {
-					mergedHeaders := make([]gatewayv1.GRPCHeaderMatch, 0)
-					if grpcRouteRule.Matches[i].Headers != nil {
-						mergedHeaders = append(mergedHeaders, grpcRouteRule.Matches[i].Headers...)
-					}
-					mergedHeaders = append(mergedHeaders, grpcHeaderRouteRuleList...)
-					grpcHeaderRouteRule.Matches = append(grpcHeaderRouteRule.Matches, gatewayv1.GRPCRouteMatch{
-						Method:  grpcRouteRule.Matches[i].Method,
-						Headers: mergedHeaders,
-					})
-				}
+			if builder.getFilters != nil {
+				managedRule.Filters = append(managedRule.Filters, builder.getFilters(canaryBackendRef)...)
 			}
-
-			newManagedRules = append(newManagedRules, grpcHeaderRouteRule)
+			newManagedRules = append(newManagedRules, managedRule)
 		}
 
 		// Upsert: remove all existing managed rules for this name, then append the new set.
 		// Primary: match by rule Name. Fallback: structural check for unnamed legacy rules.
 		cleanedRules := make(GRPCRouteRuleList, 0, len(grpcRouteRuleList))
+		var currentManagedRules []gatewayv1.GRPCRouteRule
 		for _, rule := range grpcRouteRuleList {
-			if (rule.Name != nil && isManagedRuleName(string(*rule.Name), map[string]bool{string(managedName): true})) || isGRPCManagedRule(rule, canaryServiceName, grpcHeaderRouteRuleList) {
+			if (rule.Name != nil && isManagedRuleName(string(*rule.Name), map[string]bool{managedName: true})) || (builder.isUnnamedManagedRule != nil && builder.isUnnamedManagedRule(rule)) {
+				currentManagedRules = append(currentManagedRules, rule)
 				continue
 			}
 			cleanedRules = append(cleanedRules, rule)
 		}
-		grpcRoute.Spec.Rules = append(cleanedRules, newManagedRules...)
+		// Rules that are already up to date keep their position
+		isRulesChanged := !equality.Semantic.DeepEqual(currentManagedRules, newManagedRules)
+		if isRulesChanged {
+			grpcRoute.Spec.Rules = append(cleanedRules, newManagedRules...)
+		}
+		if !isRulesChanged && grpcRoute.Annotations[defaults.OriginalSpecAnnotationKey] == originalSnapshots {
+			return errRouteUnchanged
+		}
 
-		_, err = grpcRouteClient.Update(ctx, grpcRoute, metav1.UpdateOptions{})
+		if r.isDryRun(gatewayAPIConfig) {
+			return r.logDryRun(grpcRouteGVK, grpcRoute, originalSpec, &grpcRoute.Spec)
+		}
+		if err = updateRoute(ctx, grpcRouteClient, grpcRoute, grpcRoute.Spec.Rules, grpcRouteGVK, gatewayAPIConfig); err != nil {
+			return err
+		}
+		if isRulesChanged {
+			r.recordEvent(grpcRoute, corev1.EventTypeNormal, builder.eventReason, "Added %d rules for %s route %q", len(newManagedRules), builder.routeType, managedName)
+		}
+		return nil
+	})
+
+	if err != nil {
+		r.recordRouteFailure(grpcRouteGVK, target.namespace, gatewayAPIConfig.GRPCRoute, builder.operation, err)
 		return err
+	}
+	return nil
+}
+
+// mergeGRPCRouteMatches returns one match for every pair of a source match and a header
+// list, with the headers appended to those of the source match. Without source matches,
+// every header list becomes a match of its own.
+func mergeGRPCRouteMatches(sourceMatches []gatewayv1.GRPCRouteMatch, headerLists [][]gatewayv1.GRPCHeaderMatch) []gatewayv1.GRPCRouteMatch {
+	matches := make([]gatewayv1.GRPCRouteMatch, 0, max(len(sourceMatches), 1)*len(headerLists))
+	if len(sourceMatches) == 0 {
+		for _, headers := range headerLists {
+			matches = append(matches, gatewayv1.GRPCRouteMatch{
+				Headers: headers,
+			})
+		}
+		return matches
+	}
+	for i := range len(sourceMatches) {
+		for _, headers := range headerLists {
+			mergedHeaders := make([]gatewayv1.GRPCHeaderMatch, 0)
+			if sourceMatches[i].Headers != nil {
+				mergedHeaders = append(mergedHeaders, sourceMatches[i].Headers...)
+			}
+			mergedHeaders = append(mergedHeaders, headers...)
+			matches = append(matches, gatewayv1.GRPCRouteMatch{
+				Method:  sourceMatches[i].Method,
+				Headers: mergedHeaders,
+			})
+		}
+	}
+	return matches
+}
+
+func (r *RpcPlugin) removeGRPCMirrorRoute(rollout *v1alpha1.Rollout, mirrorRouteName string, target routeTarget, gatewayAPIConfig *GatewayAPITrafficRouting) error {
+	ctx := context.TODO()
+	grpcRouteClient := r.GatewayAPIClientset.GatewayV1().GRPCRoutes(target.namespace)
+	managedNames := map[string]bool{mirrorRouteName: true}
+
+	err := retryOnConflictOrStale(grpcRouteGVK, func(useCache bool) error {
+		grpcRoute, err := r.getGRPCRoute(ctx, target.namespace, gatewayAPIConfig.GRPCRoute, useCache)
+		if err != nil {
+			return err
+		}
+		originalSpec := grpcRoute.Spec.DeepCopy()
+
+		newRules := make([]gatewayv1.GRPCRouteRule, 0, len(grpcRoute.Spec.Rules))
+		changed := false
+		for _, rule := range grpcRoute.Spec.Rules {
+			if rule.Name != nil && isManagedRuleName(string(*rule.Name), managedNames) {
+				changed = true
+				continue
+			}
+			newRules = append(newRules, rule)
+		}
+		if !changed {
+			return errRouteUnchanged
+		}
+		removedCount := len(grpcRoute.Spec.Rules) - len(newRules)
+		grpcRoute.Spec.Rules = newRules
+
+		if r.isDryRun(gatewayAPIConfig) {
+			return r.logDryRun(grpcRouteGVK, grpcRoute, originalSpec, &grpcRoute.Spec)
+		}
+		if err = updateRoute(ctx, grpcRouteClient, grpcRoute, grpcRoute.Spec.Rules, grpcRouteGVK, gatewayAPIConfig); err != nil {
+			return err
+		}
+		r.recordEvent(grpcRoute, corev1.EventTypeNormal, MirrorRouteRemovedReason, "Removed %d rules for mirror route %q", removedCount, mirrorRouteName)
+		return nil
 	})
 
 	if err != nil {
-		return pluginTypes.RpcError{
-			ErrorString: err.Error(),
+		r.recordRouteFailure(grpcRouteGVK, target.namespace, gatewayAPIConfig.GRPCRoute, "SetMirrorRoute", err)
+		return err
+	}
+	return nil
+}
+
+func getGRPCBackendRefKeys(rules []gatewayv1.GRPCRouteRule, routeNamespace string) map[backendRefKey]bool {
+	keys := make(map[backendRefKey]bool)
+	for _, rule := range rules {
+		for _, backendRef := range rule.BackendRefs {
+			keys[newBackendRefKey(backendRef.BackendObjectReference, routeNamespace)] = true
 		}
 	}
-	return pluginTypes.RpcError{}
+	return keys
+}
+
+// getTargetedGRPCRouteRules returns the rules of the route that the plugin may change.
+func (t routeTarget) getTargetedGRPCRouteRules(rules GRPCRouteRuleList) GRPCRouteRuleList {
+	targetedRules := make(GRPCRouteRuleList, 0, len(rules))
+	for i, rule := range rules {
+		if t.isRuleTargeted(i, rule.Name) {
+			targetedRules = append(targetedRules, rule)
+		}
+	}
+	return targetedRules
+}
+
+// isGRPCRuleTargeted reports whether the plugin may change the weights of the rule at index.
+// Managed rules were generated from targeted rules, so they are always targeted.
+func isGRPCRuleTargeted(index int, rule gatewayv1.GRPCRouteRule, managedNames map[string]bool, target routeTarget) bool {
+	return (rule.Name != nil && isManagedRuleName(string(*rule.Name), managedNames)) || target.isRuleTargeted(index, rule.Name)
+}
+
+func getGRPCRouteRuleNames(rules []gatewayv1.GRPCRouteRule) []*gatewayv1.SectionName {
+	ruleNames := make([]*gatewayv1.SectionName, 0, len(rules))
+	for _, rule := range rules {
+		ruleNames = append(ruleNames, rule.Name)
+	}
+	return ruleNames
+}
+
+// getUnmanagedGRPCRouteRules returns the rules that were not injected by this plugin, so
+// that managed header and mirror rules are never used as the source of new managed rules.
+func getUnmanagedGRPCRouteRules(rules GRPCRouteRuleList, managedNames map[string]bool) GRPCRouteRuleList {
+	unmanagedRules := make(GRPCRouteRuleList, 0, len(rules))
+	for _, rule := range rules {
+		if rule.Name != nil && isManagedRuleName(string(*rule.Name), managedNames) {
+			continue
+		}
+		unmanagedRules = append(unmanagedRules, rule)
+	}
+	return unmanagedRules
+}
+
+// isGRPCHeaderRouteRule reports whether rule is a managed header rule, whose weights are
+// not set by the plugin. Managed mirror rules keep the backends of their source rule and
+// are not header rules.
+func isGRPCHeaderRouteRule(rule gatewayv1.GRPCRouteRule, canaryMatcher backendRefMatcher, managedNames map[string]bool) bool {
+	return (rule.Name != nil && isManagedRuleName(string(*rule.Name), managedNames) && !isGRPCMirrorRule(rule, canaryMatcher)) || isGRPCManagedRule(rule, canaryMatcher, nil)
+}
+
+// isGRPCMirrorRule reports whether the given rule mirrors requests to the canary service.
+func isGRPCMirrorRule(rule gatewayv1.GRPCRouteRule, canaryMatcher backendRefMatcher) bool {
+	for _, filter := range rule.Filters {
+		if filter.Type == gatewayv1.GRPCRouteFilterRequestMirror && filter.RequestMirror != nil && canaryMatcher.matches(filter.RequestMirror.BackendRef) {
+			return true
+		}
+	}
+	return false
+}
+
+// getGRPCMirrorRouteHeaderList returns one header list per mirror match. GRPCRoute has no
+// path or HTTP method matches, so only header matches can be mirrored.
+func getGRPCMirrorRouteHeaderList(setMirrorRoute *v1alpha1.SetMirrorRoute) ([][]gatewayv1.GRPCHeaderMatch, error) {
+	grpcMirrorRouteHeaderList := [][]gatewayv1.GRPCHeaderMatch{}
+	for _, routeMatch := range setMirrorRoute.Match {
+		if routeMatch.Method != nil || routeMatch.Path != nil {
+			return nil, newPluginError("UnsupportedGRPCMirrorMatchError", UnsupportedGRPCMirrorMatchError)
+		}
+		headerNames := make([]string, 0, len(routeMatch.Headers))
+		for headerName := range routeMatch.Headers {
+			headerNames = append(headerNames, headerName)
+		}
+		sort.Strings(headerNames)
+		grpcHeaderMatchList := []gatewayv1.GRPCHeaderMatch{}
+		for _, headerName := range headerNames {
+			headerValue := routeMatch.Headers[headerName]
+			grpcHeaderMatch, ok := getGRPCHeaderMatch(headerName, &headerValue)
+			if !ok {
+				return nil, newPluginError("InvalidHeaderMatchTypeError", InvalidHeaderMatchTypeError)
+			}
+			grpcHeaderMatchList = append(grpcHeaderMatchList, grpcHeaderMatch)
+		}
+		grpcMirrorRouteHeaderList = append(grpcMirrorRouteHeaderList, grpcHeaderMatchList)
+	}
+	return grpcMirrorRouteHeaderList, nil
 }
 
 // isGRPCManagedRule reports whether the given rule was injected by this plugin.
-// A plugin-injected rule always has exactly one BackendRef pointing to the canary service.
+// A plugin-injected rule always has exactly one BackendRef pointing to the canary backend.
 // If canaryHeaders is non-nil, the rule must also have at least one match whose header list
 // contains all of the specified canary header names — this distinguishes between multiple
 // managed routes that each inject rules with different header sets.
-func isGRPCManagedRule(rule gatewayv1.GRPCRouteRule, canaryService gatewayv1.ObjectName, canaryHeaders []gatewayv1.GRPCHeaderMatch) bool {
-	if len(rule.BackendRefs) != 1 || rule.BackendRefs[0].Name != canaryService {
+func isGRPCManagedRule(rule gatewayv1.GRPCRouteRule, canaryMatcher backendRefMatcher, canaryHeaders []gatewayv1.GRPCHeaderMatch) bool {
+	if len(rule.BackendRefs) != 1 || !canaryMatcher.matches(rule.BackendRefs[0].BackendObjectReference) {
 		return false
 	}
 	if canaryHeaders == nil {
@@ -224,73 +555,89 @@ func grpcMatchContainsAllCanaryHeaders(matchHeaders []gatewayv1.GRPCHeaderMatch,
 	return true
 }
 
-func getGRPCHeaderRouteRuleList(headerRouting *v1alpha1.SetHeaderRoute) ([]gatewayv1.GRPCHeaderMatch, pluginTypes.RpcError) {
+func getGRPCHeaderRouteRuleList(headerRouting *v1alpha1.SetHeaderRoute) ([]gatewayv1.GRPCHeaderMatch, error) {
 	grpcHeaderRouteRuleList := []gatewayv1.GRPCHeaderMatch{}
 	for _, headerRule := range headerRouting.Match {
-		grpcHeaderRouteRule := gatewayv1.GRPCHeaderMatch{
-			Name: gatewayv1.GRPCHeaderName(headerRule.HeaderName),
-		}
-		switch {
-		case headerRule.HeaderValue.Exact != "":
-			headerMatchType := gatewayv1.GRPCHeaderMatchExact
-			grpcHeaderRouteRule.Type = &headerMatchType
-			grpcHeaderRouteRule.Value = headerRule.HeaderValue.Exact
-		case headerRule.HeaderValue.Prefix != "":
-			headerMatchType := gatewayv1.GRPCHeaderMatchRegularExpression
-			grpcHeaderRouteRule.Type = &headerMatchType
-			grpcHeaderRouteRule.Value = headerRule.HeaderValue.Prefix + ".*"
-		case headerRule.HeaderValue.Regex != "":
-			headerMatchType := gatewayv1.GRPCHeaderMatchRegularExpression
-			grpcHeaderRouteRule.Type = &headerMatchType
-			grpcHeaderRouteRule.Value = headerRule.HeaderValue.Regex
-		default:
-			return nil, pluginTypes.RpcError{
-				ErrorString: InvalidHeaderMatchTypeError,
-			}
+		grpcHeaderRouteRule, ok := getGRPCHeaderMatch(headerRule.HeaderName, headerRule.HeaderValue)
+		if !ok {
+			return nil, newPluginError("InvalidHeaderMatchTypeError", InvalidHeaderMatchTypeError)
 		}
 		grpcHeaderRouteRuleList = append(grpcHeaderRouteRuleList, grpcHeaderRouteRule)
 	}
-	return grpcHeaderRouteRuleList, pluginTypes.RpcError{}
+	return grpcHeaderRouteRuleList, nil
 }
 
-func (r *RpcPlugin) removeGRPCManagedRoutes(rollout *v1alpha1.Rollout, gatewayAPIConfig *GatewayAPITrafficRouting) pluginTypes.RpcError {
+func getGRPCHeaderMatch(headerName string, headerValue *v1alpha1.StringMatch) (gatewayv1.GRPCHeaderMatch, bool) {
+	grpcHeaderMatch := gatewayv1.GRPCHeaderMatch{
+		Name: gatewayv1.GRPCHeaderName(headerName),
+	}
+	if headerValue == nil {
+		return grpcHeaderMatch, false
+	}
+	switch {
+	case headerValue.Exact != "":
+		headerMatchType := gatewayv1.GRPCHeaderMatchExact
+		grpcHeaderMatch.Type = &headerMatchType
+		grpcHeaderMatch.Value = headerValue.Exact
+	case headerValue.Prefix != "":
+		headerMatchType := gatewayv1.GRPCHeaderMatchRegularExpression
+		grpcHeaderMatch.Type = &headerMatchType
+		grpcHeaderMatch.Value = headerValue.Prefix + ".*"
+	case headerValue.Regex != "":
+		headerMatchType := gatewayv1.GRPCHeaderMatchRegularExpression
+		grpcHeaderMatch.Type = &headerMatchType
+		grpcHeaderMatch.Value = headerValue.Regex
+	default:
+		return grpcHeaderMatch, false
+	}
+	return grpcHeaderMatch, true
+}
+
+func (r *RpcPlugin) removeGRPCManagedRoutes(rollout *v1alpha1.Rollout, target routeTarget, gatewayAPIConfig *GatewayAPITrafficRouting) error {
 	ctx := context.TODO()
-	grpcRouteClient := r.GatewayAPIClientset.GatewayV1().GRPCRoutes(gatewayAPIConfig.Namespace)
+	grpcRouteClient := r.GatewayAPIClientset.GatewayV1().GRPCRoutes(target.namespace)
 
-	canaryServiceName := gatewayv1.ObjectName(rollout.Spec.Strategy.Canary.CanaryService)
+	canaryMatcher := gatewayAPIConfig.getCanaryBackendRefMatcher(rollout, target.namespace)
 	managedNames := managedRouteNamesSet(rollout)
 
-	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-		grpcRoute, err := grpcRouteClient.Get(ctx, gatewayAPIConfig.GRPCRoute, metav1.GetOptions{})
+	err := retryOnConflictOrStale(grpcRouteGVK, func(useCache bool) error {
+		grpcRoute, err := r.getGRPCRoute(ctx, target.namespace, gatewayAPIConfig.GRPCRoute, useCache)
 		if err != nil {
 			return err
 		}
+		originalSpec := grpcRoute.Spec.DeepCopy()
 
 		newRules := make([]gatewayv1.GRPCRouteRule, 0, len(grpcRoute.Spec.Rules))
 		changed := false
 		for _, rule := range grpcRoute.Spec.Rules {
 			// Primary: remove by Name. Fallback: structural check for unnamed legacy rules.
-			if (rule.Name != nil && isManagedRuleName(string(*rule.Name), managedNames)) || isGRPCManagedRule(rule, canaryServiceName, nil) {
+			if (rule.Name != nil && isManagedRuleName(string(*rule.Name), managedNames)) || isGRPCManagedRule(rule, canaryMatcher, nil) {
 				changed = true
 				continue
 			}
 			newRules = append(newRules, rule)
 		}
 		if !changed {
-			return nil
+			return errRouteUnchanged
 		}
+		removedCount := len(grpcRoute.Spec.Rules) - len(newRules)
 		grpcRoute.Spec.Rules = newRules
 
-		_, err = grpcRouteClient.Update(ctx, grpcRoute, metav1.UpdateOptions{})
-		return err
+		if r.isDryRun(gatewayAPIConfig) {
+			return r.logDryRun(grpcRouteGVK, grpcRoute, originalSpec, &grpcRoute.Spec)
+		}
+		if err = updateRoute(ctx, grpcRouteClient, grpcRoute, grpcRoute.Spec.Rules, grpcRouteGVK, gatewayAPIConfig); err != nil {
+			return err
+		}
+		r.recordEvent(grpcRoute, corev1.EventTypeNormal, ManagedRoutesRemovedReason, "Removed %d managed rules", removedCount)
+		return nil
 	})
 
 	if err != nil {
-		return pluginTypes.RpcError{
-			ErrorString: err.Error(),
-		}
+		r.recordRouteFailure(grpcRouteGVK, target.namespace, gatewayAPIConfig.GRPCRoute, "RemoveManagedRoutes", err)
+		return err
 	}
-	return pluginTypes.RpcError{}
+	return nil
 }
 
 func (r *GRPCRouteRule) Iterator() (GatewayAPIRouteRuleIterator[*GRPCBackendRef], bool) {
@@ -322,13 +669,21 @@ func (r GRPCRouteRuleList) Iterator() (GatewayAPIRouteRuleListIterator[*GRPCBack
 }
 
 func (r GRPCRouteRuleList) Error() error {
-	return errors.New(BackendRefWasNotFoundInGRPCRouteError)
+	return newPluginError("BackendRefWasNotFoundInGRPCRouteError", BackendRefWasNotFoundInGRPCRouteError)
 }
 
 func (r *GRPCBackendRef) GetName() string {
 	return string(r.Name)
 }
 
+func (r *GRPCBackendRef) GetBackendObjectReference() gatewayv1.BackendObjectReference {
+	return r.BackendObjectReference
+}
+
 func (r GRPCRoute) GetName() string {
 	return r.Name
 }
+
+func (r GRPCRoute) GetNamespace() string {
+	return r.Namespace
+}
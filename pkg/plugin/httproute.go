@@ -2,204 +2,675 @@ package plugin
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
-	pluginTypes "github.com/argoproj/argo-rollouts/utils/plugin/types"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/util/retry"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+
+	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-gatewayapi/internal/defaults"
 )
 
-func (r *RpcPlugin) setHTTPRouteWeight(rollout *v1alpha1.Rollout, desiredWeight int32, additionalDestinations []v1alpha1.WeightDestination, gatewayAPIConfig *GatewayAPITrafficRouting) pluginTypes.RpcError {
+func (r *RpcPlugin) setHTTPRouteWeight(rollout *v1alpha1.Rollout, desiredWeight int32, additionalDestinations []v1alpha1.WeightDestination, experimentPort *ExperimentPort, target routeTarget, gatewayAPIConfig *GatewayAPITrafficRouting) error {
 	ctx := context.TODO()
-	httpRouteClient := r.GatewayAPIClientset.GatewayV1().HTTPRoutes(gatewayAPIConfig.Namespace)
+	httpRouteClient := r.GatewayAPIClientset.GatewayV1().HTTPRoutes(target.namespace)
 
-	canaryServiceName := rollout.Spec.Strategy.Canary.CanaryService
-	stableServiceName := rollout.Spec.Strategy.Canary.StableService
-	canaryServiceObjName := gatewayv1.ObjectName(canaryServiceName)
-	restWeight := 100 - desiredWeight
+	canaryMatcher := gatewayAPIConfig.getCanaryBackendRefMatcher(rollout, target.namespace)
+	stableMatcher := gatewayAPIConfig.getStableBackendRefMatcher(rollout, target.namespace)
+	canaryServiceName := canaryMatcher.name
+	stableServiceName := stableMatcher.name
 	managedNames := managedRouteNamesSet(rollout)
+	experimentDestinations := getExperimentDestinations(rollout, additionalDestinations)
+	ownedMatchers := gatewayAPIConfig.getOwnedBackendRefMatchers(rollout, target.namespace, experimentDestinations)
 
-	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-		httpRoute, err := httpRouteClient.Get(ctx, gatewayAPIConfig.HTTPRoute, metav1.GetOptions{})
+	err := retryOnConflict(httpRouteGVK, func(useCache bool) error {
+		httpRoute, err := r.getHTTPRoute(ctx, target.namespace, gatewayAPIConfig.HTTPRoute, useCache)
 		if err != nil {
 			return err
 		}
+		originalSpec := httpRoute.Spec.DeepCopy()
+
+		isRestored, err := syncRouteSpecSnapshot(r, httpRoute, &httpRoute.Spec, rollout, desiredWeight, gatewayAPIConfig)
+		if err != nil {
+			return err
+		}
+		if isRestored {
+			r.LogCtx.Info(fmt.Sprintf("[SetWeight] restoring original spec of HTTPRoute %q", httpRoute.Name))
+			ensureInProgressLabel(httpRoute, desiredWeight, gatewayAPIConfig)
+			if r.isDryRun(gatewayAPIConfig) {
+				return r.logDryRun(httpRouteGVK, httpRoute, originalSpec, &httpRoute.Spec)
+			}
+			if err = updateRoute(ctx, httpRouteClient, httpRoute, httpRoute.Spec.Rules, httpRouteGVK, gatewayAPIConfig); err != nil {
+				return err
+			}
+			r.recordEvent(httpRoute, corev1.EventTypeNormal, OriginalSpecRestoredReason, "Restored the spec saved before the canary started")
+			return nil
+		}
+
+		if err = target.checkRules(httpRouteGVK.Kind, httpRoute.Name, getHTTPRouteRuleNames(httpRoute.Spec.Rules)); err != nil {
+			return err
+		}
 
 		canaryFound, stableFound := false, false
+		var allocation weightAllocation
 		for i := range httpRoute.Spec.Rules {
 			// Skip plugin-injected header-routing rules.
 			// Primary: rule carries a Name matching a known managed route.
 			// Fallback: structural check (single canary-only BackendRef) for rules injected
 			// by older plugin versions that did not set the Name field.
-			rule := httpRoute.Spec.Rules[i]
-			if (rule.Name != nil && isManagedRuleName(string(*rule.Name), managedNames)) || isHTTPManagedRule(rule, canaryServiceObjName, nil) {
+			// Managed mirror rules keep the source rule's backends, so they follow the
+			// same weight split as the rule they were copied from.
+			if isHTTPHeaderRouteRule(httpRoute.Spec.Rules[i], canaryMatcher, managedNames) {
+				continue
+			}
+			if !isHTTPRuleTargeted(i, httpRoute.Spec.Rules[i], managedNames, target) || !hasRolloutBackendRef[*HTTPBackendRef]((*HTTPRouteRule)(&httpRoute.Spec.Rules[i]), canaryMatcher, stableMatcher) {
 				continue
 			}
+			allocation, err = allocateWeights(desiredWeight, experimentDestinations, getUnmanagedWeight(httpRoute.Spec.Rules[i].BackendRefs, getHTTPExperimentBackendRef, ownedMatchers))
+			if err != nil {
+				return err
+			}
+			r.warnUnmanagedWeight(httpRouteGVK.Kind, httpRoute.Name, i, allocation)
+			canaryWeight, stableWeight := allocation.canaryWeight, allocation.stableWeight
 			for j := range httpRoute.Spec.Rules[i].BackendRefs {
-				switch string(httpRoute.Spec.Rules[i].BackendRefs[j].Name) {
-				case canaryServiceName:
-					httpRoute.Spec.Rules[i].BackendRefs[j].Weight = &desiredWeight
+				backendRef := httpRoute.Spec.Rules[i].BackendRefs[j].BackendObjectReference
+				switch {
+				case canaryMatcher.matches(backendRef):
+					httpRoute.Spec.Rules[i].BackendRefs[j].Weight = &canaryWeight
 					canaryFound = true
-				case stableServiceName:
-					httpRoute.Spec.Rules[i].BackendRefs[j].Weight = &restWeight
+				case stableMatcher.matches(backendRef):
+					httpRoute.Spec.Rules[i].BackendRefs[j].Weight = &stableWeight
 					stableFound = true
 				}
 			}
 		}
 		if !canaryFound || !stableFound {
-			return errors.New(BackendRefWasNotFoundInHTTPRouteError)
+			return newPluginError("BackendRefWasNotFoundInHTTPRouteError", BackendRefWasNotFoundInHTTPRouteError)
 		}
 
-		err = HandleExperiment(ctx, r.Clientset, r.GatewayAPIClientset, r.LogCtx, rollout, httpRoute, additionalDestinations)
-		if err != nil {
-			r.LogCtx.Error(err, "Failed to handle experiment services")
+		previousBackendRefs := getHTTPBackendRefKeys(httpRoute.Spec.Rules, target.namespace)
+		if err = HandleExperiment(ctx, r.getService, r.GatewayAPIClientset, r.LogCtx, rollout, httpRoute, additionalDestinations, experimentPort, target, gatewayAPIConfig); err != nil {
+			return err
 		}
 
 		ensureInProgressLabel(httpRoute, desiredWeight, gatewayAPIConfig)
 
-		_, err = httpRouteClient.Update(ctx, httpRoute, metav1.UpdateOptions{})
-		return err
+		if r.isDryRun(gatewayAPIConfig) {
+			return r.logDryRun(httpRouteGVK, httpRoute, originalSpec, &httpRoute.Spec)
+		}
+		if err = updateRoute(ctx, httpRouteClient, httpRoute, httpRoute.Spec.Rules, httpRouteGVK, gatewayAPIConfig); err != nil {
+			return err
+		}
+		if !equality.Semantic.DeepEqual(originalSpec.Rules, httpRoute.Spec.Rules) {
+			r.recordEvent(httpRoute, corev1.EventTypeNormal, WeightUpdatedReason, "Set weight of canary service %q to %d and stable service %q to %d", canaryServiceName, allocation.canaryWeight, stableServiceName, allocation.stableWeight)
+		}
+		r.recordExperimentBackendEvents(httpRoute, previousBackendRefs, getHTTPBackendRefKeys(httpRoute.Spec.Rules, target.namespace))
+		return nil
 	})
 
 	if err != nil {
-		return pluginTypes.RpcError{
-			ErrorString: err.Error(),
+		r.recordRouteFailure(httpRouteGVK, target.namespace, gatewayAPIConfig.HTTPRoute, "SetWeight", err)
+		return err
+	}
+	r.recordCanaryWeight(rollout, httpRouteGVK.Kind, target.namespace, gatewayAPIConfig.HTTPRoute, desiredWeight, gatewayAPIConfig)
+	return nil
+}
+
+func (r *RpcPlugin) verifyHTTPRouteWeight(rollout *v1alpha1.Rollout, desiredWeight int32, additionalDestinations []v1alpha1.WeightDestination, target routeTarget, gatewayAPIConfig *GatewayAPITrafficRouting) (bool, error) {
+	ctx := context.TODO()
+	allocation, err := allocateWeights(desiredWeight, getExperimentDestinations(rollout, additionalDestinations), 0)
+	if err != nil {
+		return false, err
+	}
+
+	canaryMatcher := gatewayAPIConfig.getCanaryBackendRefMatcher(rollout, target.namespace)
+	stableMatcher := gatewayAPIConfig.getStableBackendRefMatcher(rollout, target.namespace)
+	managedNames := managedRouteNamesSet(rollout)
+
+	httpRoute, err := r.getHTTPRoute(ctx, target.namespace, gatewayAPIConfig.HTTPRoute, true)
+	if err != nil {
+		return false, err
+	}
+
+	canaryFound, stableFound := false, false
+	for i, rule := range httpRoute.Spec.Rules {
+		if isHTTPHeaderRouteRule(rule, canaryMatcher, managedNames) || !isHTTPRuleTargeted(i, rule, managedNames, target) {
+			continue
 		}
+		for _, backendRef := range rule.BackendRefs {
+			switch {
+			case canaryMatcher.matches(backendRef.BackendObjectReference):
+				canaryFound = true
+				if !isWeightEqual(backendRef.Weight, desiredWeight) {
+					r.LogCtx.Info(fmt.Sprintf("[VerifyWeight] HTTPRoute %q canary weight is not %d yet", httpRoute.Name, desiredWeight))
+					return false, nil
+				}
+			case stableMatcher.matches(backendRef.BackendObjectReference):
+				stableFound = true
+				if !isWeightEqual(backendRef.Weight, allocation.stableWeight) {
+					r.LogCtx.Info(fmt.Sprintf("[VerifyWeight] HTTPRoute %q stable weight is not %d yet", httpRoute.Name, allocation.stableWeight))
+					return false, nil
+				}
+			}
+		}
+	}
+	if !canaryFound || !stableFound {
+		return false, newPluginError("BackendRefWasNotFoundInHTTPRouteError", BackendRefWasNotFoundInHTTPRouteError)
 	}
-	return pluginTypes.RpcError{}
+
+	if isAccepted, reason := isRouteAccepted(httpRoute.Generation, httpRoute.Status.RouteStatus); !isAccepted {
+		r.LogCtx.Info(fmt.Sprintf("[VerifyWeight] HTTPRoute %q is not accepted yet: %s", httpRoute.Name, reason))
+		return false, nil
+	}
+	return true, nil
 }
 
-func (r *RpcPlugin) setHTTPHeaderRoute(rollout *v1alpha1.Rollout, headerRouting *v1alpha1.SetHeaderRoute, gatewayAPIConfig *GatewayAPITrafficRouting) pluginTypes.RpcError {
+func (r *RpcPlugin) setHTTPHeaderRoute(rollout *v1alpha1.Rollout, headerRouting *v1alpha1.SetHeaderRoute, target routeTarget, gatewayAPIConfig *GatewayAPITrafficRouting) error {
 	if headerRouting.Match == nil {
-		return r.removeHTTPManagedRoutes(rollout, gatewayAPIConfig)
+		return r.removeHTTPManagedRoutes(rollout, target, gatewayAPIConfig)
 	}
-	ctx := context.TODO()
-	httpRouteClient := r.GatewayAPIClientset.GatewayV1().HTTPRoutes(gatewayAPIConfig.Namespace)
-	httpHeaderRouteRuleList, rpcError := getHTTPHeaderRouteRuleList(headerRouting)
-	if rpcError.HasError() {
-		return rpcError
+	httpHeaderRouteRuleList, err := getHTTPHeaderRouteRuleList(headerRouting)
+	if err != nil {
+		return err
+	}
+	headerRouteMatch := getHTTPHeaderRouteMatch(httpHeaderRouteRuleList, gatewayAPIConfig.getHeaderRouteMatch(headerRouting.Name))
+	canaryMatcher := gatewayAPIConfig.getCanaryBackendRefMatcher(rollout, target.namespace)
+
+	return r.setHTTPManagedRoute(rollout, headerRouting.Name, target, gatewayAPIConfig, httpManagedRouteBuilder{
+		operation:   "SetHeaderRoute",
+		routeType:   "header",
+		eventReason: HeaderRouteAddedReason,
+		getBackendRefs: func(_ *HTTPRouteRule, canaryBackendRef gatewayv1.BackendObjectReference) []gatewayv1.HTTPBackendRef {
+			return []gatewayv1.HTTPBackendRef{
+				{
+					BackendRef: gatewayv1.BackendRef{
+						BackendObjectReference: canaryBackendRef,
+					},
+				},
+			}
+		},
+		// Copy matches from original route and merge headers and extra match criteria
+		getMatches: func(sourceRule *HTTPRouteRule) []gatewayv1.HTTPRouteMatch {
+			if len(sourceRule.Matches) == 0 {
+				return []gatewayv1.HTTPRouteMatch{headerRouteMatch}
+			}
+			matches := make([]gatewayv1.HTTPRouteMatch, 0, len(sourceRule.Matches))
+			for i := 0; i < len(sourceRule.Matches); i++ {
+				matches = append(matches, mergeHTTPRouteMatch(sourceRule.Matches[i], headerRouteMatch))
+			}
+			return matches
+		},
+		isUnnamedManagedRule: func(rule gatewayv1.HTTPRouteRule) bool {
+			return isHTTPManagedRule(rule, canaryMatcher, httpHeaderRouteRuleList)
+		},
+	})
+}
+
+func (r *RpcPlugin) setHTTPMirrorRoute(rollout *v1alpha1.Rollout, setMirrorRoute *v1alpha1.SetMirrorRoute, target routeTarget, gatewayAPIConfig *GatewayAPITrafficRouting) error {
+	if setMirrorRoute.Match == nil {
+		return r.removeHTTPMirrorRoute(rollout, setMirrorRoute.Name, target, gatewayAPIConfig)
+	}
+	httpMirrorRouteMatchList, err := getHTTPMirrorRouteMatchList(setMirrorRoute)
+	if err != nil {
+		return err
 	}
 
-	canaryServiceName := gatewayv1.ObjectName(rollout.Spec.Strategy.Canary.CanaryService)
-	stableServiceName := rollout.Spec.Strategy.Canary.StableService
-	managedName := gatewayv1.SectionName(headerRouting.Name)
+	// The mirror rule keeps the source rule's backends so that mirrored requests are still
+	// served by the current stable/canary split.
+	return r.setHTTPManagedRoute(rollout, setMirrorRoute.Name, target, gatewayAPIConfig, httpManagedRouteBuilder{
+		operation:   "SetMirrorRoute",
+		routeType:   "mirror",
+		eventReason: MirrorRouteAddedReason,
+		getBackendRefs: func(sourceRule *HTTPRouteRule, _ gatewayv1.BackendObjectReference) []gatewayv1.HTTPBackendRef {
+			backendRefs := make([]gatewayv1.HTTPBackendRef, 0, len(sourceRule.BackendRefs))
+			for i := 0; i < len(sourceRule.BackendRefs); i++ {
+				backendRefs = append(backendRefs, *sourceRule.BackendRefs[i].DeepCopy())
+			}
+			return backendRefs
+		},
+		getFilters: func(canaryBackendRef gatewayv1.BackendObjectReference) []gatewayv1.HTTPRouteFilter {
+			return []gatewayv1.HTTPRouteFilter{
+				{
+					Type: gatewayv1.HTTPRouteFilterRequestMirror,
+					RequestMirror: &gatewayv1.HTTPRequestMirrorFilter{
+						BackendRef: canaryBackendRef,
+						Percent:    setMirrorRoute.Percentage,
+					},
+				},
+			}
+		},
+		// Copy matches from original route and merge the mirror matches that fall within
+		// them, so that the mirror rule never takes requests the source rule does not serve
+		getMatches: func(sourceRule *HTTPRouteRule) []gatewayv1.HTTPRouteMatch {
+			if len(sourceRule.Matches) == 0 {
+				return append([]gatewayv1.HTTPRouteMatch{}, httpMirrorRouteMatchList...)
+			}
+			matches := []gatewayv1.HTTPRouteMatch{}
+			for i := 0; i < len(sourceRule.Matches); i++ {
+				for _, mirrorMatch := range httpMirrorRouteMatchList {
+					if mergedMatch, ok := mergeHTTPMirrorRouteMatch(sourceRule.Matches[i], mirrorMatch); ok {
+						matches = append(matches, mergedMatch)
+					}
+				}
+			}
+			return matches
+		},
+		checkSourceRules: func(httpRoute *gatewayv1.HTTPRoute, sourceRules []*HTTPRouteRule) error {
+			for i, mirrorMatch := range httpMirrorRouteMatchList {
+				if !isHTTPMirrorRouteMatchWithin(mirrorMatch, sourceRules) {
+					return newPluginError("MirrorRouteMatchOutsideSourceRulesError", MirrorRouteMatchOutsideSourceRulesError, i, setMirrorRoute.Name, httpRoute.Name)
+				}
+			}
+			return nil
+		},
+	})
+}
+
+// httpManagedRouteBuilder builds the rules of a header or mirror route from the rules of the
+// HTTPRoute that send traffic to the canary and stable services. canaryBackendRef refers to
+// the canary service with the group, kind, namespace and port of the source rule's backend.
+type httpManagedRouteBuilder struct {
+	operation   string
+	routeType   string
+	eventReason string
+	// getBackendRefs returns the backends of the managed rule built from sourceRule
+	getBackendRefs func(sourceRule *HTTPRouteRule, canaryBackendRef gatewayv1.BackendObjectReference) []gatewayv1.HTTPBackendRef
+	// getFilters returns the filters added after the filters of the source rule, if set
+	getFilters func(canaryBackendRef gatewayv1.BackendObjectReference) []gatewayv1.HTTPRouteFilter
+	// getMatches returns the matches of the managed rule built from sourceRule. No rule is
+	// built when there are none, since a rule without matches would match every request.
+	getMatches func(sourceRule *HTTPRouteRule) []gatewayv1.HTTPRouteMatch
+	// isUnnamedManagedRule finds the managed rules of older versions of the plugin, if set
+	isUnnamedManagedRule func(rule gatewayv1.HTTPRouteRule) bool
+	// checkSourceRules rejects source rules the managed route cannot be built from, if set
+	checkSourceRules func(httpRoute *gatewayv1.HTTPRoute, sourceRules []*HTTPRouteRule) error
+}
+
+// setHTTPManagedRoute replaces the rules of the managed route managedName with the rules
+// builder builds from the current rules of the HTTPRoute.
+func (r *RpcPlugin) setHTTPManagedRoute(rollout *v1alpha1.Rollout, managedName string, target routeTarget, gatewayAPIConfig *GatewayAPITrafficRouting, builder httpManagedRouteBuilder) error {
+	ctx := context.TODO()
+	httpRouteClient := r.GatewayAPIClientset.GatewayV1().HTTPRoutes(target.namespace)
+	canaryMatcher := gatewayAPIConfig.getCanaryBackendRefMatcher(rollout, target.namespace)
+	stableMatcher := gatewayAPIConfig.getStableBackendRefMatcher(rollout, target.namespace)
+	managedRuleName := gatewayv1.SectionName(managedName)
+	managedNames := managedRouteNamesSet(rollout)
 
-	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-		httpRoute, err := httpRouteClient.Get(ctx, gatewayAPIConfig.HTTPRoute, metav1.GetOptions{})
+	err := retryOnConflictOrStale(httpRouteGVK, func(useCache bool) error {
+		httpRoute, err := r.getHTTPRoute(ctx, target.namespace, gatewayAPIConfig.HTTPRoute, useCache)
 		if err != nil {
 			return err
 		}
+		originalSpec := httpRoute.Spec.DeepCopy()
+		originalSnapshots := httpRoute.Annotations[defaults.OriginalSpecAnnotationKey]
+		if err = ensureRouteSpecSnapshot(r, httpRoute, &httpRoute.Spec, rollout, gatewayAPIConfig); err != nil {
+			return err
+		}
 
-		canaryServiceKind := gatewayv1.Kind("Service")
-		canaryServiceGroup := gatewayv1.Group("")
 		httpRouteRuleList := HTTPRouteRuleList(httpRoute.Spec.Rules)
-		backendRefNameList := []string{string(canaryServiceName), stableServiceName}
-		sourceRules, err := getAllRouteRules(httpRouteRuleList, backendRefNameList...)
+		if err = target.checkRules(httpRouteGVK.Kind, httpRoute.Name, getHTTPRouteRuleNames(httpRoute.Spec.Rules)); err != nil {
+			return err
+		}
+		sourceRules, err := getAllRouteRules(getUnmanagedHTTPRouteRules(target.getTargetedHTTPRouteRules(httpRouteRuleList), managedNames), canaryMatcher, stableMatcher)
 		if err != nil {
 			return err
 		}
+		if builder.checkSourceRules != nil {
+			if err = builder.checkSourceRules(httpRoute, sourceRules); err != nil {
+				return err
+			}
+		}
 
-		// Build one managed header rule per source rule so that the canary header
+		// Build one managed rule per source rule so that the header or mirror route
 		// applies to every rule on a multi-rule HTTPRoute (issue #207).
 		// Each rule needs a unique name within the route (Gateway API constraint).
 		// Index 0 keeps the bare managedName for backward compatibility with single-rule routes;
 		// subsequent rules are named managedName-1, managedName-2, etc.
 		newManagedRules := make([]gatewayv1.HTTPRouteRule, 0, len(sourceRules))
-		for idx, httpRouteRule := range sourceRules {
-			var canaryBackendRef *HTTPBackendRef
-			for i := 0; i < len(httpRouteRule.BackendRefs); i++ {
-				backendRef := httpRouteRule.BackendRefs[i]
-				if canaryServiceName == backendRef.Name {
-					canaryBackendRef = (*HTTPBackendRef)(&backendRef)
-					break
-				}
+		for idx, sourceRule := range sourceRules {
+			matches := builder.getMatches(sourceRule)
+			if len(matches) == 0 {
+				continue
 			}
-			ruleName := managedName
+			canaryBackendRef := getCanaryBackendObjectReference[*HTTPBackendRef](sourceRule, canaryMatcher)
+			ruleName := managedRuleName
 			if idx > 0 {
-				ruleName = gatewayv1.SectionName(fmt.Sprintf("%s-%d", managedName, idx))
+				ruleName = gatewayv1.SectionName(fmt.Sprintf("%s-%d", managedRuleName, idx))
 			}
-			httpHeaderRouteRule := gatewayv1.HTTPRouteRule{
-				Name:    &ruleName,
-				Matches: []gatewayv1.HTTPRouteMatch{},
-				Filters: []gatewayv1.HTTPRouteFilter{},
-				BackendRefs: []gatewayv1.HTTPBackendRef{
-					{
-						BackendRef: gatewayv1.BackendRef{
-							BackendObjectReference: gatewayv1.BackendObjectReference{
-								Group: &canaryServiceGroup,
-								Kind:  &canaryServiceKind,
-								Name:  canaryServiceName,
-								Port:  canaryBackendRef.Port,
-							},
-						},
-					},
-				},
+			managedRule := gatewayv1.HTTPRouteRule{
+				Name:        &ruleName,
+				Matches:     matches,
+				Filters:     make([]gatewayv1.HTTPRouteFilter, 0, len(sourceRule.Filters)),
+				BackendRefs: builder.getBackendRefs(sourceRule, canaryBackendRef),
 			}
-
 			// Copy filters from original route
-			if httpRouteRule.Filters != nil {
-				for i := 0; i < len(httpRouteRule.Filters); i++ {
-					httpHeaderRouteRule.Filters = append(httpHeaderRouteRule.Filters, *httpRouteRule.Filters[i].DeepCopy())
-				}
+			for i := 0; i < len(sourceRule.Filters); i++ {
+				managedRule.Filters = append(managedRule.Filters, *sourceRule.Filters[i].DeepCopy())
 			}
-
-			// Copy matches from original route and merge headers
-			if len(httpRouteRule.Matches) == 0 {
-				httpHeaderRouteRule.Matches = []gatewayv1.HTTPRouteMatch{
-					{Headers: httpHeaderRouteRuleList},
-				}
-			} else {
-				for i := 0; i < len(httpRouteRule.Matches); i++ {
-					mergedHeaders := make([]gatewayv1.HTTPHeaderMatch, 0)
-					if httpRouteRule.Matches[i].Headers != nil {
-						mergedHeaders = append(mergedHeaders, httpRouteRule.Matches[i].Headers...)
-					}
-					mergedHeaders = append(mergedHeaders, httpHeaderRouteRuleList...)
-					httpHeaderRouteRule.Matches = append(httpHeaderRouteRule.Matches, gatewayv1.HTTPRouteMatch{
-						Path:        httpRouteRule.Matches[i].Path,
-						Headers:     mergedHeaders,
-						QueryParams: httpRouteRule.Matches[i].QueryParams,
-						Method:      httpRouteRule.Matches[i].Method,
-					})
-				}
+			if builder.getFilters != nil {
+				managedRule.Filters = append(managedRule.Filters, builder.getFilters(canaryBackendRef)...)
 			}
-
-			newManagedRules = append(newManagedRules, httpHeaderRouteRule)
+			newManagedRules = append(newManagedRules, managedRule)
 		}
 
 		// Upsert: remove all existing managed rules for this name, then append the new set.
 		// Primary: match by rule Name. Fallback: structural check for unnamed legacy rules.
 		cleanedRules := make(HTTPRouteRuleList, 0, len(httpRouteRuleList))
+		var currentManagedRules []gatewayv1.HTTPRouteRule
 		for _, rule := range httpRouteRuleList {
-			if (rule.Name != nil && isManagedRuleName(string(*rule.Name), map[string]bool{string(managedName): true})) || isHTTPManagedRule(rule, canaryServiceName, httpHeaderRouteRuleList) {
+			if (rule.Name != nil && isManagedRuleName(string(*rule.Name), map[string]bool{managedName: true})) || (builder.isUnnamedManagedRule != nil && builder.isUnnamedManagedRule(rule)) {
+				currentManagedRules = append(currentManagedRules, rule)
 				continue
 			}
 			cleanedRules = append(cleanedRules, rule)
 		}
-		httpRoute.Spec.Rules = append(cleanedRules, newManagedRules...)
+		// Rules that are already up to date keep their position
+		isRulesChanged := !equality.Semantic.DeepEqual(currentManagedRules, newManagedRules)
+		if isRulesChanged {
+			httpRoute.Spec.Rules = append(cleanedRules, newManagedRules...)
+		}
+		if !isRulesChanged && httpRoute.Annotations[defaults.OriginalSpecAnnotationKey] == originalSnapshots {
+			return errRouteUnchanged
+		}
 
-		_, err = httpRouteClient.Update(ctx, httpRoute, metav1.UpdateOptions{})
+		if r.isDryRun(gatewayAPIConfig) {
+			return r.logDryRun(httpRouteGVK, httpRoute, originalSpec, &httpRoute.Spec)
+		}
+		if err = updateRoute(ctx, httpRouteClient, httpRoute, httpRoute.Spec.Rules, httpRouteGVK, gatewayAPIConfig); err != nil {
+			return err
+		}
+		if isRulesChanged {
+			r.recordEvent(httpRoute, corev1.EventTypeNormal, builder.eventReason, "Added %d rules for %s route %q", len(newManagedRules), builder.routeType, managedName)
+		}
+		return nil
+	})
+
+	if err != nil {
+		r.recordRouteFailure(httpRouteGVK, target.namespace, gatewayAPIConfig.HTTPRoute, builder.operation, err)
 		return err
+	}
+	return nil
+}
+
+func (r *RpcPlugin) removeHTTPMirrorRoute(rollout *v1alpha1.Rollout, mirrorRouteName string, target routeTarget, gatewayAPIConfig *GatewayAPITrafficRouting) error {
+	ctx := context.TODO()
+	httpRouteClient := r.GatewayAPIClientset.GatewayV1().HTTPRoutes(target.namespace)
+	managedNames := map[string]bool{mirrorRouteName: true}
+
+	err := retryOnConflictOrStale(httpRouteGVK, func(useCache bool) error {
+		httpRoute, err := r.getHTTPRoute(ctx, target.namespace, gatewayAPIConfig.HTTPRoute, useCache)
+		if err != nil {
+			return err
+		}
+		originalSpec := httpRoute.Spec.DeepCopy()
+
+		newRules := make([]gatewayv1.HTTPRouteRule, 0, len(httpRoute.Spec.Rules))
+		changed := false
+		for _, rule := range httpRoute.Spec.Rules {
+			if rule.Name != nil && isManagedRuleName(string(*rule.Name), managedNames) {
+				changed = true
+				continue
+			}
+			newRules = append(newRules, rule)
+		}
+		if !changed {
+			return errRouteUnchanged
+		}
+		removedCount := len(httpRoute.Spec.Rules) - len(newRules)
+		httpRoute.Spec.Rules = newRules
+
+		if r.isDryRun(gatewayAPIConfig) {
+			return r.logDryRun(httpRouteGVK, httpRoute, originalSpec, &httpRoute.Spec)
+		}
+		if err = updateRoute(ctx, httpRouteClient, httpRoute, httpRoute.Spec.Rules, httpRouteGVK, gatewayAPIConfig); err != nil {
+			return err
+		}
+		r.recordEvent(httpRoute, corev1.EventTypeNormal, MirrorRouteRemovedReason, "Removed %d rules for mirror route %q", removedCount, mirrorRouteName)
+		return nil
 	})
 
 	if err != nil {
-		return pluginTypes.RpcError{
-			ErrorString: err.Error(),
+		r.recordRouteFailure(httpRouteGVK, target.namespace, gatewayAPIConfig.HTTPRoute, "SetMirrorRoute", err)
+		return err
+	}
+	return nil
+}
+
+// getHTTPBackendRefKeys returns the keys of every backend referenced by rules of a route in
+// routeNamespace.
+func getHTTPBackendRefKeys(rules []gatewayv1.HTTPRouteRule, routeNamespace string) map[backendRefKey]bool {
+	keys := make(map[backendRefKey]bool)
+	for _, rule := range rules {
+		for _, backendRef := range rule.BackendRefs {
+			keys[newBackendRefKey(backendRef.BackendObjectReference, routeNamespace)] = true
+		}
+	}
+	return keys
+}
+
+// getTargetedHTTPRouteRules returns the rules of the route that the plugin may change.
+func (t routeTarget) getTargetedHTTPRouteRules(rules HTTPRouteRuleList) HTTPRouteRuleList {
+	targetedRules := make(HTTPRouteRuleList, 0, len(rules))
+	for i, rule := range rules {
+		if t.isRuleTargeted(i, rule.Name) {
+			targetedRules = append(targetedRules, rule)
+		}
+	}
+	return targetedRules
+}
+
+// isHTTPRuleTargeted reports whether the plugin may change the weights of the rule at index.
+// Managed rules were generated from targeted rules, so they are always targeted.
+func isHTTPRuleTargeted(index int, rule gatewayv1.HTTPRouteRule, managedNames map[string]bool, target routeTarget) bool {
+	return (rule.Name != nil && isManagedRuleName(string(*rule.Name), managedNames)) || target.isRuleTargeted(index, rule.Name)
+}
+
+func getHTTPRouteRuleNames(rules []gatewayv1.HTTPRouteRule) []*gatewayv1.SectionName {
+	ruleNames := make([]*gatewayv1.SectionName, 0, len(rules))
+	for _, rule := range rules {
+		ruleNames = append(ruleNames, rule.Name)
+	}
+	return ruleNames
+}
+
+// getUnmanagedHTTPRouteRules returns the rules that were not injected by this plugin, so
+// that managed header and mirror rules are never used as the source of new managed rules.
+func getUnmanagedHTTPRouteRules(rules HTTPRouteRuleList, managedNames map[string]bool) HTTPRouteRuleList {
+	unmanagedRules := make(HTTPRouteRuleList, 0, len(rules))
+	for _, rule := range rules {
+		if rule.Name != nil && isManagedRuleName(string(*rule.Name), managedNames) {
+			continue
 		}
+		unmanagedRules = append(unmanagedRules, rule)
 	}
-	return pluginTypes.RpcError{}
+	return unmanagedRules
+}
+
+// isHTTPHeaderRouteRule reports whether rule is a managed header rule, whose weights are
+// not set by the plugin. Managed mirror rules keep the backends of their source rule and
+// are not header rules.
+func isHTTPHeaderRouteRule(rule gatewayv1.HTTPRouteRule, canaryMatcher backendRefMatcher, managedNames map[string]bool) bool {
+	return (rule.Name != nil && isManagedRuleName(string(*rule.Name), managedNames) && !isHTTPMirrorRule(rule, canaryMatcher)) || isHTTPManagedRule(rule, canaryMatcher, nil)
+}
+
+// isHTTPMirrorRule reports whether the given rule mirrors requests to the canary service.
+func isHTTPMirrorRule(rule gatewayv1.HTTPRouteRule, canaryMatcher backendRefMatcher) bool {
+	for _, filter := range rule.Filters {
+		if filter.Type == gatewayv1.HTTPRouteFilterRequestMirror && filter.RequestMirror != nil && canaryMatcher.matches(filter.RequestMirror.BackendRef) {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeHTTPRouteMatch combines the source match with a managed match: headers and query
+// parameters are appended, while path and method are replaced when the managed match
+// sets them.
+func mergeHTTPRouteMatch(sourceMatch gatewayv1.HTTPRouteMatch, managedMatch gatewayv1.HTTPRouteMatch) gatewayv1.HTTPRouteMatch {
+	mergedMatch := *sourceMatch.DeepCopy()
+	if managedMatch.Path != nil {
+		mergedMatch.Path = managedMatch.Path.DeepCopy()
+	}
+	if managedMatch.Method != nil {
+		method := *managedMatch.Method
+		mergedMatch.Method = &method
+	}
+	mergedMatch.Headers = append(mergedMatch.Headers, managedMatch.Headers...)
+	mergedMatch.QueryParams = append(mergedMatch.QueryParams, managedMatch.QueryParams...)
+	return mergedMatch
+}
+
+// mergeHTTPMirrorRouteMatch narrows the source match with a mirror match. Mirror rules keep
+// the backends of their source rule, so the merge is only valid when the path and method
+// of the mirror match are the same as or nested within those of the source match.
+func mergeHTTPMirrorRouteMatch(sourceMatch gatewayv1.HTTPRouteMatch, mirrorMatch gatewayv1.HTTPRouteMatch) (gatewayv1.HTTPRouteMatch, bool) {
+	if mirrorMatch.Path != nil && !isHTTPPathWithin(*mirrorMatch.Path, sourceMatch.Path) {
+		return gatewayv1.HTTPRouteMatch{}, false
+	}
+	if mirrorMatch.Method != nil && sourceMatch.Method != nil && *mirrorMatch.Method != *sourceMatch.Method {
+		return gatewayv1.HTTPRouteMatch{}, false
+	}
+	return mergeHTTPRouteMatch(sourceMatch, mirrorMatch), true
+}
+
+// isHTTPMirrorRouteMatchWithin reports whether mirrorMatch can be merged into a match of
+// one of sourceRules. A source rule without matches takes every request.
+func isHTTPMirrorRouteMatchWithin(mirrorMatch gatewayv1.HTTPRouteMatch, sourceRules []*HTTPRouteRule) bool {
+	for _, sourceRule := range sourceRules {
+		if len(sourceRule.Matches) == 0 {
+			return true
+		}
+		for _, sourceMatch := range sourceRule.Matches {
+			if _, ok := mergeHTTPMirrorRouteMatch(sourceMatch, mirrorMatch); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isHTTPPathWithin reports whether every request path matched by path is also matched by
+// sourcePath. A missing source path matches every request.
+func isHTTPPathWithin(path gatewayv1.HTTPPathMatch, sourcePath *gatewayv1.HTTPPathMatch) bool {
+	if sourcePath == nil {
+		return true
+	}
+	pathType, pathValue := getHTTPPathMatchTypeValue(path)
+	sourceType, sourceValue := getHTTPPathMatchTypeValue(*sourcePath)
+	if pathType == sourceType && pathValue == sourceValue {
+		return true
+	}
+	if sourceType != gatewayv1.PathMatchPathPrefix || pathType == gatewayv1.PathMatchRegularExpression {
+		return false
+	}
+	// Prefixes match whole path elements and ignore a trailing slash
+	prefix := strings.TrimSuffix(sourceValue, "/")
+	return pathValue == prefix || strings.HasPrefix(pathValue, prefix+"/")
+}
+
+// getHTTPPathMatchTypeValue returns the type and value of a path match with the Gateway API
+// defaults applied.
+func getHTTPPathMatchTypeValue(path gatewayv1.HTTPPathMatch) (gatewayv1.PathMatchType, string) {
+	pathType := gatewayv1.PathMatchPathPrefix
+	if path.Type != nil {
+		pathType = *path.Type
+	}
+	pathValue := "/"
+	if path.Value != nil {
+		pathValue = *path.Value
+	}
+	return pathType, pathValue
+}
+
+// getHTTPHeaderRouteMatch builds the match added to the rules of a header route from
+// the headers of setHeaderRoute and the extra criteria configured for it, if any.
+func getHTTPHeaderRouteMatch(headers []gatewayv1.HTTPHeaderMatch, extraMatch *HeaderRouteMatch) gatewayv1.HTTPRouteMatch {
+	httpRouteMatch := gatewayv1.HTTPRouteMatch{
+		Headers: headers,
+	}
+	if extraMatch == nil {
+		return httpRouteMatch
+	}
+	httpRouteMatch.QueryParams = extraMatch.QueryParams
+	if extraMatch.Path != nil {
+		httpRouteMatch.Path = extraMatch.Path.DeepCopy()
+	}
+	if extraMatch.Method != nil {
+		method := *extraMatch.Method
+		httpRouteMatch.Method = &method
+	}
+	return httpRouteMatch
+}
+
+func (c *GatewayAPITrafficRouting) getHeaderRouteMatch(managedRouteName string) *HeaderRouteMatch {
+	for i := range c.HeaderRouteMatches {
+		if c.HeaderRouteMatches[i].Name == managedRouteName {
+			return &c.HeaderRouteMatches[i]
+		}
+	}
+	return nil
+}
+
+func getHTTPMirrorRouteMatchList(setMirrorRoute *v1alpha1.SetMirrorRoute) ([]gatewayv1.HTTPRouteMatch, error) {
+	httpRouteMatchList := []gatewayv1.HTTPRouteMatch{}
+	for _, routeMatch := range setMirrorRoute.Match {
+		httpRouteMatch := gatewayv1.HTTPRouteMatch{}
+		if routeMatch.Path != nil {
+			httpPathMatch := gatewayv1.HTTPPathMatch{}
+			switch {
+			case routeMatch.Path.Exact != "":
+				pathMatchType := gatewayv1.PathMatchExact
+				httpPathMatch.Type = &pathMatchType
+				httpPathMatch.Value = &routeMatch.Path.Exact
+			case routeMatch.Path.Prefix != "":
+				pathMatchType := gatewayv1.PathMatchPathPrefix
+				httpPathMatch.Type = &pathMatchType
+				httpPathMatch.Value = &routeMatch.Path.Prefix
+			case routeMatch.Path.Regex != "":
+				pathMatchType := gatewayv1.PathMatchRegularExpression
+				httpPathMatch.Type = &pathMatchType
+				httpPathMatch.Value = &routeMatch.Path.Regex
+			default:
+				return nil, newPluginError("InvalidPathMatchTypeError", InvalidPathMatchTypeError)
+			}
+			httpRouteMatch.Path = &httpPathMatch
+		}
+		if routeMatch.Method != nil {
+			// Gateway API only supports exact HTTP method matches
+			if routeMatch.Method.Exact == "" {
+				return nil, newPluginError("InvalidMethodMatchTypeError", InvalidMethodMatchTypeError)
+			}
+			method := gatewayv1.HTTPMethod(strings.ToUpper(routeMatch.Method.Exact))
+			httpRouteMatch.Method = &method
+		}
+		headerNames := make([]string, 0, len(routeMatch.Headers))
+		for headerName := range routeMatch.Headers {
+			headerNames = append(headerNames, headerName)
+		}
+		sort.Strings(headerNames)
+		for _, headerName := range headerNames {
+			headerValue := routeMatch.Headers[headerName]
+			httpHeaderMatch, ok := getHTTPHeaderMatch(headerName, &headerValue)
+			if !ok {
+				return nil, newPluginError("InvalidHeaderMatchTypeError", InvalidHeaderMatchTypeError)
+			}
+			httpRouteMatch.Headers = append(httpRouteMatch.Headers, httpHeaderMatch)
+		}
+		httpRouteMatchList = append(httpRouteMatchList, httpRouteMatch)
+	}
+	return httpRouteMatchList, nil
 }
 
 // isHTTPManagedRule reports whether the given rule was injected by this plugin.
-// A plugin-injected rule always has exactly one BackendRef pointing to the canary service.
+// A plugin-injected rule always has exactly one BackendRef pointing to the canary backend.
 // If canaryHeaders is non-nil, the rule must also have at least one match whose header list
 // contains all of the specified canary header names — this distinguishes between multiple
 // managed routes that each inject rules with different header sets.
-func isHTTPManagedRule(rule gatewayv1.HTTPRouteRule, canaryService gatewayv1.ObjectName, canaryHeaders []gatewayv1.HTTPHeaderMatch) bool {
-	if len(rule.BackendRefs) != 1 || rule.BackendRefs[0].Name != canaryService {
+func isHTTPManagedRule(rule gatewayv1.HTTPRouteRule, canaryMatcher backendRefMatcher, canaryHeaders []gatewayv1.HTTPHeaderMatch) bool {
+	if len(rule.BackendRefs) != 1 || !canaryMatcher.matches(rule.BackendRefs[0].BackendObjectReference) {
 		return false
 	}
 	if canaryHeaders == nil {
@@ -231,73 +702,89 @@ func httpMatchContainsAllCanaryHeaders(matchHeaders []gatewayv1.HTTPHeaderMatch,
 	return true
 }
 
-func getHTTPHeaderRouteRuleList(headerRouting *v1alpha1.SetHeaderRoute) ([]gatewayv1.HTTPHeaderMatch, pluginTypes.RpcError) {
+func getHTTPHeaderRouteRuleList(headerRouting *v1alpha1.SetHeaderRoute) ([]gatewayv1.HTTPHeaderMatch, error) {
 	httpHeaderRouteRuleList := []gatewayv1.HTTPHeaderMatch{}
 	for _, headerRule := range headerRouting.Match {
-		httpHeaderRouteRule := gatewayv1.HTTPHeaderMatch{
-			Name: gatewayv1.HTTPHeaderName(headerRule.HeaderName),
-		}
-		switch {
-		case headerRule.HeaderValue.Exact != "":
-			headerMatchType := gatewayv1.HeaderMatchExact
-			httpHeaderRouteRule.Type = &headerMatchType
-			httpHeaderRouteRule.Value = headerRule.HeaderValue.Exact
-		case headerRule.HeaderValue.Prefix != "":
-			headerMatchType := gatewayv1.HeaderMatchRegularExpression
-			httpHeaderRouteRule.Type = &headerMatchType
-			httpHeaderRouteRule.Value = headerRule.HeaderValue.Prefix + ".*"
-		case headerRule.HeaderValue.Regex != "":
-			headerMatchType := gatewayv1.HeaderMatchRegularExpression
-			httpHeaderRouteRule.Type = &headerMatchType
-			httpHeaderRouteRule.Value = headerRule.HeaderValue.Regex
-		default:
-			return nil, pluginTypes.RpcError{
-				ErrorString: InvalidHeaderMatchTypeError,
-			}
+		httpHeaderRouteRule, ok := getHTTPHeaderMatch(headerRule.HeaderName, headerRule.HeaderValue)
+		if !ok {
+			return nil, newPluginError("InvalidHeaderMatchTypeError", InvalidHeaderMatchTypeError)
 		}
 		httpHeaderRouteRuleList = append(httpHeaderRouteRuleList, httpHeaderRouteRule)
 	}
-	return httpHeaderRouteRuleList, pluginTypes.RpcError{}
+	return httpHeaderRouteRuleList, nil
+}
+
+func getHTTPHeaderMatch(headerName string, headerValue *v1alpha1.StringMatch) (gatewayv1.HTTPHeaderMatch, bool) {
+	httpHeaderMatch := gatewayv1.HTTPHeaderMatch{
+		Name: gatewayv1.HTTPHeaderName(headerName),
+	}
+	if headerValue == nil {
+		return httpHeaderMatch, false
+	}
+	switch {
+	case headerValue.Exact != "":
+		headerMatchType := gatewayv1.HeaderMatchExact
+		httpHeaderMatch.Type = &headerMatchType
+		httpHeaderMatch.Value = headerValue.Exact
+	case headerValue.Prefix != "":
+		headerMatchType := gatewayv1.HeaderMatchRegularExpression
+		httpHeaderMatch.Type = &headerMatchType
+		httpHeaderMatch.Value = headerValue.Prefix + ".*"
+	case headerValue.Regex != "":
+		headerMatchType := gatewayv1.HeaderMatchRegularExpression
+		httpHeaderMatch.Type = &headerMatchType
+		httpHeaderMatch.Value = headerValue.Regex
+	default:
+		return httpHeaderMatch, false
+	}
+	return httpHeaderMatch, true
 }
 
-func (r *RpcPlugin) removeHTTPManagedRoutes(rollout *v1alpha1.Rollout, gatewayAPIConfig *GatewayAPITrafficRouting) pluginTypes.RpcError {
+func (r *RpcPlugin) removeHTTPManagedRoutes(rollout *v1alpha1.Rollout, target routeTarget, gatewayAPIConfig *GatewayAPITrafficRouting) error {
 	ctx := context.TODO()
-	httpRouteClient := r.GatewayAPIClientset.GatewayV1().HTTPRoutes(gatewayAPIConfig.Namespace)
+	httpRouteClient := r.GatewayAPIClientset.GatewayV1().HTTPRoutes(target.namespace)
 
-	canaryServiceName := gatewayv1.ObjectName(rollout.Spec.Strategy.Canary.CanaryService)
+	canaryMatcher := gatewayAPIConfig.getCanaryBackendRefMatcher(rollout, target.namespace)
 	managedNames := managedRouteNamesSet(rollout)
 
-	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-		httpRoute, err := httpRouteClient.Get(ctx, gatewayAPIConfig.HTTPRoute, metav1.GetOptions{})
+	err := retryOnConflictOrStale(httpRouteGVK, func(useCache bool) error {
+		httpRoute, err := r.getHTTPRoute(ctx, target.namespace, gatewayAPIConfig.HTTPRoute, useCache)
 		if err != nil {
 			return err
 		}
+		originalSpec := httpRoute.Spec.DeepCopy()
 
 		newRules := make([]gatewayv1.HTTPRouteRule, 0, len(httpRoute.Spec.Rules))
 		changed := false
 		for _, rule := range httpRoute.Spec.Rules {
 			// Primary: remove by Name. Fallback: structural check for unnamed legacy rules.
-			if (rule.Name != nil && isManagedRuleName(string(*rule.Name), managedNames)) || isHTTPManagedRule(rule, canaryServiceName, nil) {
+			if (rule.Name != nil && isManagedRuleName(string(*rule.Name), managedNames)) || isHTTPManagedRule(rule, canaryMatcher, nil) {
 				changed = true
 				continue
 			}
 			newRules = append(newRules, rule)
 		}
 		if !changed {
-			return nil
+			return errRouteUnchanged
 		}
+		removedCount := len(httpRoute.Spec.Rules) - len(newRules)
 		httpRoute.Spec.Rules = newRules
 
-		_, err = httpRouteClient.Update(ctx, httpRoute, metav1.UpdateOptions{})
-		return err
+		if r.isDryRun(gatewayAPIConfig) {
+			return r.logDryRun(httpRouteGVK, httpRoute, originalSpec, &httpRoute.Spec)
+		}
+		if err = updateRoute(ctx, httpRouteClient, httpRoute, httpRoute.Spec.Rules, httpRouteGVK, gatewayAPIConfig); err != nil {
+			return err
+		}
+		r.recordEvent(httpRoute, corev1.EventTypeNormal, ManagedRoutesRemovedReason, "Removed %d managed rules", removedCount)
+		return nil
 	})
 
 	if err != nil {
-		return pluginTypes.RpcError{
-			ErrorString: err.Error(),
-		}
+		r.recordRouteFailure(httpRouteGVK, target.namespace, gatewayAPIConfig.HTTPRoute, "RemoveManagedRoutes", err)
+		return err
 	}
-	return pluginTypes.RpcError{}
+	return nil
 }
 
 func (r *HTTPRouteRule) Iterator() (GatewayAPIRouteRuleIterator[*HTTPBackendRef], bool) {
@@ -329,13 +816,21 @@ func (r HTTPRouteRuleList) Iterator() (GatewayAPIRouteRuleListIterator[*HTTPBack
 }
 
 func (r HTTPRouteRuleList) Error() error {
-	return errors.New(BackendRefWasNotFoundInHTTPRouteError)
+	return newPluginError("BackendRefWasNotFoundInHTTPRouteError", BackendRefWasNotFoundInHTTPRouteError)
 }
 
 func (r *HTTPBackendRef) GetName() string {
 	return string(r.Name)
 }
 
+func (r *HTTPBackendRef) GetBackendObjectReference() gatewayv1.BackendObjectReference {
+	return r.BackendObjectReference
+}
+
 func (r HTTPRoute) GetName() string {
 	return r.Name
 }
+
+func (r HTTPRoute) GetNamespace() string {
+	return r.Namespace
+}
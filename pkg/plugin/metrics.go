@@ -0,0 +1,173 @@
+package plugin
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
+	pluginTypes "github.com/argoproj/argo-rollouts/utils/plugin/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const metricsNamespace = "gatewayapi_plugin"
+
+// MetricsRegistry holds every metric exposed by the plugin process.
+var MetricsRegistry = prometheus.NewRegistry()
+
+var (
+	rpcCallsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "rpc_calls_total",
+		Help:      "Number of RPC calls received from the Argo Rollouts controller.",
+	}, []string{"method", "result"})
+	rpcCallDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "rpc_call_duration_seconds",
+		Help:      "Duration of RPC calls received from the Argo Rollouts controller.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+	routeUpdateConflictsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "route_update_conflicts_total",
+		Help:      "Number of route writes rejected with a conflict.",
+	}, []string{"kind"})
+	routeUpdateRetriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "route_update_retries_total",
+		Help:      "Number of route writes retried after a conflict.",
+	}, []string{"kind"})
+	routeErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "route_errors_total",
+		Help:      "Number of errors returned while handling a route.",
+	}, []string{"kind", "error"})
+	canaryWeight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "canary_weight",
+		Help:      "Canary weight last set on a route.",
+	}, []string{"namespace", "rollout", "kind", "route_namespace", "route"})
+)
+
+func init() {
+	MetricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		rpcCallsTotal,
+		rpcCallDurationSeconds,
+		routeUpdateConflictsTotal,
+		routeUpdateRetriesTotal,
+		routeErrorsTotal,
+		canaryWeight,
+	)
+}
+
+// ServeMetrics serves the plugin metrics in the Prometheus format on addr until the
+// listener fails.
+func ServeMetrics(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(MetricsRegistry, promhttp.HandlerOpts{}))
+	return http.ListenAndServe(addr, mux)
+}
+
+// InstrumentedRpcPlugin records call counts and latencies of every RPC method before
+// handing the call to RpcPlugin.
+type InstrumentedRpcPlugin struct {
+	*RpcPlugin
+}
+
+func (r *InstrumentedRpcPlugin) InitPlugin() pluginTypes.RpcError {
+	defer observeRpcCall("InitPlugin", time.Now())
+	return recordRpcResult("InitPlugin", r.RpcPlugin.InitPlugin())
+}
+
+func (r *InstrumentedRpcPlugin) UpdateHash(rollout *v1alpha1.Rollout, canaryHash, stableHash string, additionalDestinations []v1alpha1.WeightDestination) pluginTypes.RpcError {
+	defer observeRpcCall("UpdateHash", time.Now())
+	return recordRpcResult("UpdateHash", r.RpcPlugin.UpdateHash(rollout, canaryHash, stableHash, additionalDestinations))
+}
+
+func (r *InstrumentedRpcPlugin) SetWeight(rollout *v1alpha1.Rollout, desiredWeight int32, additionalDestinations []v1alpha1.WeightDestination) pluginTypes.RpcError {
+	defer observeRpcCall("SetWeight", time.Now())
+	return recordRpcResult("SetWeight", r.RpcPlugin.SetWeight(rollout, desiredWeight, additionalDestinations))
+}
+
+func (r *InstrumentedRpcPlugin) SetHeaderRoute(rollout *v1alpha1.Rollout, headerRouting *v1alpha1.SetHeaderRoute) pluginTypes.RpcError {
+	defer observeRpcCall("SetHeaderRoute", time.Now())
+	return recordRpcResult("SetHeaderRoute", r.RpcPlugin.SetHeaderRoute(rollout, headerRouting))
+}
+
+func (r *InstrumentedRpcPlugin) SetMirrorRoute(rollout *v1alpha1.Rollout, setMirrorRoute *v1alpha1.SetMirrorRoute) pluginTypes.RpcError {
+	defer observeRpcCall("SetMirrorRoute", time.Now())
+	return recordRpcResult("SetMirrorRoute", r.RpcPlugin.SetMirrorRoute(rollout, setMirrorRoute))
+}
+
+func (r *InstrumentedRpcPlugin) VerifyWeight(rollout *v1alpha1.Rollout, desiredWeight int32, additionalDestinations []v1alpha1.WeightDestination) (pluginTypes.RpcVerified, pluginTypes.RpcError) {
+	defer observeRpcCall("VerifyWeight", time.Now())
+	verified, rpcError := r.RpcPlugin.VerifyWeight(rollout, desiredWeight, additionalDestinations)
+	return verified, recordRpcResult("VerifyWeight", rpcError)
+}
+
+func (r *InstrumentedRpcPlugin) RemoveManagedRoutes(rollout *v1alpha1.Rollout) pluginTypes.RpcError {
+	defer observeRpcCall("RemoveManagedRoutes", time.Now())
+	return recordRpcResult("RemoveManagedRoutes", r.RpcPlugin.RemoveManagedRoutes(rollout))
+}
+
+func observeRpcCall(method string, start time.Time) {
+	rpcCallDurationSeconds.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+func recordRpcResult(method string, rpcError pluginTypes.RpcError) pluginTypes.RpcError {
+	result := "success"
+	if rpcError.HasError() {
+		result = "error"
+	}
+	rpcCallsTotal.WithLabelValues(method, result).Inc()
+	return rpcError
+}
+
+func recordRouteError(kind string, err error) {
+	routeErrorsTotal.WithLabelValues(kind, getErrorReason(err)).Inc()
+}
+
+// recordCanaryWeight records desiredWeight as the canary weight of a route. Dry runs leave
+// the route unchanged, so they record nothing.
+func (r *RpcPlugin) recordCanaryWeight(rollout *v1alpha1.Rollout, kind, routeNamespace, routeName string, desiredWeight int32, gatewayAPIConfig *GatewayAPITrafficRouting) {
+	if r.isDryRun(gatewayAPIConfig) {
+		return
+	}
+	canaryWeight.WithLabelValues(rollout.Namespace, rollout.Name, kind, routeNamespace, routeName).Set(float64(desiredWeight))
+}
+
+// deleteCanaryWeights deletes the canary weight of every route of rollout, including routes
+// that were renamed or removed from its configuration since their weight was recorded.
+func deleteCanaryWeights(rollout *v1alpha1.Rollout) {
+	canaryWeight.DeletePartialMatch(prometheus.Labels{"namespace": rollout.Namespace, "rollout": rollout.Name})
+}
+
+// getErrorReason returns the reason of the plugin error err was built from, or Other for
+// errors raised outside the plugin.
+func getErrorReason(err error) string {
+	var pluginErr *pluginError
+	if errors.As(err, &pluginErr) {
+		return pluginErr.reason
+	}
+	return "Other"
+}
+
+func getGatewayAPIRouteKind[T1 GatewayAPIRoute](route T1) string {
+	switch any(route).(type) {
+	case HTTPRoute:
+		return httpRouteGVK.Kind
+	case GRPCRoute:
+		return grpcRouteGVK.Kind
+	case TCPRoute:
+		return tcpRouteGVK.Kind
+	case TLSRoute:
+		return tlsRouteGVK.Kind
+	case UDPRoute:
+		return udpRouteGVK.Kind
+	}
+	return ""
+}
@@ -4,14 +4,22 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
+	rolloutsClientset "github.com/argoproj/argo-rollouts/pkg/client/clientset/versioned"
 	pluginTypes "github.com/argoproj/argo-rollouts/utils/plugin/types"
 	"github.com/go-playground/validator/v10"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	"sigs.k8s.io/gateway-api/apis/v1alpha2"
 	gatewayApiClientset "sigs.k8s.io/gateway-api/pkg/client/clientset/versioned"
 
 	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-gatewayapi/internal/utils"
@@ -54,8 +62,27 @@ func (r *RpcPlugin) InitPlugin() pluginTypes.RpcError {
 			ErrorString: err.Error(),
 		}
 	}
+	rolloutClientset, err := rolloutsClientset.NewForConfig(kubeConfig)
+	if err != nil {
+		return pluginTypes.RpcError{
+			ErrorString: err.Error(),
+		}
+	}
 	r.GatewayAPIClientset = gatewayAPIClientset
 	r.Clientset = clientset
+	r.RolloutClientset = rolloutClientset
+	r.recorder = newEventRecorder(clientset)
+
+	if r.CommandLineOpts.EnableInformerCache {
+		log.Infof("Starting informer cache for namespace %q", r.CommandLineOpts.InformerNamespace)
+		cache, err := newInformerCache(context.Background(), clientset, gatewayAPIClientset, r.CommandLineOpts.InformerNamespace, log)
+		if err != nil {
+			return pluginTypes.RpcError{
+				ErrorString: err.Error(),
+			}
+		}
+		r.cache = cache
+	}
 	return pluginTypes.RpcError{}
 }
 
@@ -75,12 +102,18 @@ func (r *RpcPlugin) SetWeight(rollout *v1alpha1.Rollout, desiredWeight int32, ad
 			ErrorString: GatewayAPIManifestError,
 		}
 	}
+	if err := r.checkReferenceGrants(context.TODO(), rollout, additionalDestinations, gatewayAPIConfig); err != nil {
+		return pluginTypes.RpcError{
+			ErrorString: err.Error(),
+		}
+	}
 	var rpcError pluginTypes.RpcError
 	if gatewayAPIConfig.HTTPRoutes != nil {
 		r.LogCtx.Info(fmt.Sprintf("[SetWeight] plugin %q controls HTTPRoutes: %v", PluginName, getGatewayAPIRouteNameList(gatewayAPIConfig.HTTPRoutes)))
-		rpcError = forEachGatewayAPIRoute(gatewayAPIConfig.HTTPRoutes, func(route HTTPRoute) pluginTypes.RpcError {
+		rpcError = forEachGatewayAPIRoute(gatewayAPIConfig.HTTPRoutes, func(route HTTPRoute) error {
 			gatewayAPIConfig.HTTPRoute = route.Name
-			return r.setHTTPRouteWeight(rollout, desiredWeight, additionalDestinations, gatewayAPIConfig)
+			target := gatewayAPIConfig.newRouteTarget(route.Namespace, route.RuleNames, route.RuleIndexes)
+			return r.setHTTPRouteWeight(rollout, desiredWeight, additionalDestinations, gatewayAPIConfig.getRouteExperimentPort(route.ExperimentPort), target, gatewayAPIConfig)
 		})
 		if rpcError.HasError() {
 			return rpcError
@@ -88,9 +121,10 @@ func (r *RpcPlugin) SetWeight(rollout *v1alpha1.Rollout, desiredWeight int32, ad
 	}
 	if gatewayAPIConfig.GRPCRoutes != nil {
 		r.LogCtx.Info(fmt.Sprintf("[SetWeight] plugin %q controls GRPCRoutes: %v", PluginName, getGatewayAPIRouteNameList(gatewayAPIConfig.GRPCRoutes)))
-		rpcError = forEachGatewayAPIRoute(gatewayAPIConfig.GRPCRoutes, func(route GRPCRoute) pluginTypes.RpcError {
+		rpcError = forEachGatewayAPIRoute(gatewayAPIConfig.GRPCRoutes, func(route GRPCRoute) error {
 			gatewayAPIConfig.GRPCRoute = route.Name
-			return r.setGRPCRouteWeight(rollout, desiredWeight, gatewayAPIConfig)
+			target := gatewayAPIConfig.newRouteTarget(route.Namespace, route.RuleNames, route.RuleIndexes)
+			return r.setGRPCRouteWeight(rollout, desiredWeight, additionalDestinations, gatewayAPIConfig.getRouteExperimentPort(route.ExperimentPort), target, gatewayAPIConfig)
 		})
 		if rpcError.HasError() {
 			return rpcError
@@ -98,9 +132,10 @@ func (r *RpcPlugin) SetWeight(rollout *v1alpha1.Rollout, desiredWeight int32, ad
 	}
 	if gatewayAPIConfig.TCPRoutes != nil {
 		r.LogCtx.Info(fmt.Sprintf("[SetWeight] plugin %q controls TCPRoutes: %v", PluginName, getGatewayAPIRouteNameList(gatewayAPIConfig.TCPRoutes)))
-		rpcError = forEachGatewayAPIRoute(gatewayAPIConfig.TCPRoutes, func(route TCPRoute) pluginTypes.RpcError {
+		rpcError = forEachGatewayAPIRoute(gatewayAPIConfig.TCPRoutes, func(route TCPRoute) error {
 			gatewayAPIConfig.TCPRoute = route.Name
-			return r.setTCPRouteWeight(rollout, desiredWeight, gatewayAPIConfig)
+			target := gatewayAPIConfig.newRouteTarget(route.Namespace, nil, nil)
+			return r.setTCPRouteWeight(rollout, desiredWeight, additionalDestinations, gatewayAPIConfig.getRouteExperimentPort(route.ExperimentPort), target, gatewayAPIConfig)
 		})
 		if rpcError.HasError() {
 			return rpcError
@@ -108,9 +143,21 @@ func (r *RpcPlugin) SetWeight(rollout *v1alpha1.Rollout, desiredWeight int32, ad
 	}
 	if gatewayAPIConfig.TLSRoutes != nil {
 		r.LogCtx.Info(fmt.Sprintf("[SetWeight] plugin %q controls TLSRoutes: %v", PluginName, getGatewayAPIRouteNameList(gatewayAPIConfig.TLSRoutes)))
-		rpcError = forEachGatewayAPIRoute(gatewayAPIConfig.TLSRoutes, func(route TLSRoute) pluginTypes.RpcError {
+		rpcError = forEachGatewayAPIRoute(gatewayAPIConfig.TLSRoutes, func(route TLSRoute) error {
 			gatewayAPIConfig.TLSRoute = route.Name
-			return r.setTLSRouteWeight(rollout, desiredWeight, gatewayAPIConfig)
+			target := gatewayAPIConfig.newRouteTarget(route.Namespace, nil, nil)
+			return r.setTLSRouteWeight(rollout, desiredWeight, additionalDestinations, gatewayAPIConfig.getRouteExperimentPort(route.ExperimentPort), target, gatewayAPIConfig)
+		})
+		if rpcError.HasError() {
+			return rpcError
+		}
+	}
+	if gatewayAPIConfig.UDPRoutes != nil {
+		r.LogCtx.Info(fmt.Sprintf("[SetWeight] plugin %q controls UDPRoutes: %v", PluginName, getGatewayAPIRouteNameList(gatewayAPIConfig.UDPRoutes)))
+		rpcError = forEachGatewayAPIRoute(gatewayAPIConfig.UDPRoutes, func(route UDPRoute) error {
+			gatewayAPIConfig.UDPRoute = route.Name
+			target := gatewayAPIConfig.newRouteTarget(route.Namespace, nil, nil)
+			return r.setUDPRouteWeight(rollout, desiredWeight, target, gatewayAPIConfig)
 		})
 	}
 	return rpcError
@@ -123,14 +170,20 @@ func (r *RpcPlugin) SetHeaderRoute(rollout *v1alpha1.Rollout, headerRouting *v1a
 			ErrorString: err.Error(),
 		}
 	}
+	if err := r.checkReferenceGrants(context.TODO(), rollout, nil, gatewayAPIConfig); err != nil {
+		return pluginTypes.RpcError{
+			ErrorString: err.Error(),
+		}
+	}
 	if gatewayAPIConfig.HTTPRoutes != nil {
 		r.LogCtx.Info(fmt.Sprintf("[SetHeaderRoute] plugin %q controls HTTPRoutes: %v", PluginName, getGatewayAPIRouteNameList(gatewayAPIConfig.HTTPRoutes)))
-		rpcError := forEachGatewayAPIRoute(gatewayAPIConfig.HTTPRoutes, func(route HTTPRoute) pluginTypes.RpcError {
+		rpcError := forEachGatewayAPIRoute(gatewayAPIConfig.HTTPRoutes, func(route HTTPRoute) error {
 			if !route.UseHeaderRoutes {
-				return pluginTypes.RpcError{}
+				return nil
 			}
 			gatewayAPIConfig.HTTPRoute = route.Name
-			return r.setHTTPHeaderRoute(rollout, headerRouting, gatewayAPIConfig)
+			target := gatewayAPIConfig.newRouteTarget(route.Namespace, route.RuleNames, route.RuleIndexes)
+			return r.setHTTPHeaderRoute(rollout, headerRouting, target, gatewayAPIConfig)
 		})
 		if rpcError.HasError() {
 			return rpcError
@@ -138,12 +191,13 @@ func (r *RpcPlugin) SetHeaderRoute(rollout *v1alpha1.Rollout, headerRouting *v1a
 	}
 	if gatewayAPIConfig.GRPCRoutes != nil {
 		r.LogCtx.Info(fmt.Sprintf("[SetHeaderRoute] plugin %q controls GRPCRoutes: %v", PluginName, getGatewayAPIRouteNameList(gatewayAPIConfig.GRPCRoutes)))
-		rpcError := forEachGatewayAPIRoute(gatewayAPIConfig.GRPCRoutes, func(route GRPCRoute) pluginTypes.RpcError {
+		rpcError := forEachGatewayAPIRoute(gatewayAPIConfig.GRPCRoutes, func(route GRPCRoute) error {
 			if !route.UseHeaderRoutes {
-				return pluginTypes.RpcError{}
+				return nil
 			}
 			gatewayAPIConfig.GRPCRoute = route.Name
-			return r.setGRPCHeaderRoute(rollout, headerRouting, gatewayAPIConfig)
+			target := gatewayAPIConfig.newRouteTarget(route.Namespace, route.RuleNames, route.RuleIndexes)
+			return r.setGRPCHeaderRoute(rollout, headerRouting, target, gatewayAPIConfig)
 		})
 		if rpcError.HasError() {
 			return rpcError
@@ -152,11 +206,136 @@ func (r *RpcPlugin) SetHeaderRoute(rollout *v1alpha1.Rollout, headerRouting *v1a
 	return pluginTypes.RpcError{}
 }
 
+// SetMirrorRoute adds a managed rule that mirrors matching requests to the canary service.
+// Mirror rules are managed routes, so they are only added to routes that opt in with
+// useHeaderRoutes and are cleaned up by RemoveManagedRoutes.
 func (r *RpcPlugin) SetMirrorRoute(rollout *v1alpha1.Rollout, setMirrorRoute *v1alpha1.SetMirrorRoute) pluginTypes.RpcError {
+	gatewayAPIConfig, err := r.getGatewayAPIConfigWithDiscovery(rollout)
+	if err != nil {
+		return pluginTypes.RpcError{
+			ErrorString: err.Error(),
+		}
+	}
+	if err := r.checkReferenceGrants(context.TODO(), rollout, nil, gatewayAPIConfig); err != nil {
+		return pluginTypes.RpcError{
+			ErrorString: err.Error(),
+		}
+	}
+	if gatewayAPIConfig.HTTPRoutes != nil {
+		r.LogCtx.Info(fmt.Sprintf("[SetMirrorRoute] plugin %q controls HTTPRoutes: %v", PluginName, getGatewayAPIRouteNameList(gatewayAPIConfig.HTTPRoutes)))
+		rpcError := forEachGatewayAPIRoute(gatewayAPIConfig.HTTPRoutes, func(route HTTPRoute) error {
+			if !route.UseHeaderRoutes {
+				return nil
+			}
+			gatewayAPIConfig.HTTPRoute = route.Name
+			target := gatewayAPIConfig.newRouteTarget(route.Namespace, route.RuleNames, route.RuleIndexes)
+			return r.setHTTPMirrorRoute(rollout, setMirrorRoute, target, gatewayAPIConfig)
+		})
+		if rpcError.HasError() {
+			return rpcError
+		}
+	}
+	if gatewayAPIConfig.GRPCRoutes != nil {
+		r.LogCtx.Info(fmt.Sprintf("[SetMirrorRoute] plugin %q controls GRPCRoutes: %v", PluginName, getGatewayAPIRouteNameList(gatewayAPIConfig.GRPCRoutes)))
+		rpcError := forEachGatewayAPIRoute(gatewayAPIConfig.GRPCRoutes, func(route GRPCRoute) error {
+			if !route.UseHeaderRoutes {
+				return nil
+			}
+			gatewayAPIConfig.GRPCRoute = route.Name
+			target := gatewayAPIConfig.newRouteTarget(route.Namespace, route.RuleNames, route.RuleIndexes)
+			return r.setGRPCMirrorRoute(rollout, setMirrorRoute, target, gatewayAPIConfig)
+		})
+		if rpcError.HasError() {
+			return rpcError
+		}
+	}
 	return pluginTypes.RpcError{}
 }
 
+// VerifyWeight re-reads every configured route and reports it as verified only when the
+// canary and stable backendRefs carry the requested weights and every parent of the route
+// has accepted its latest generation.
 func (r *RpcPlugin) VerifyWeight(rollout *v1alpha1.Rollout, desiredWeight int32, additionalDestinations []v1alpha1.WeightDestination) (pluginTypes.RpcVerified, pluginTypes.RpcError) {
+	gatewayAPIConfig, err := r.getGatewayAPIConfigWithDiscovery(rollout)
+	if err != nil {
+		return pluginTypes.NotVerified, pluginTypes.RpcError{
+			ErrorString: err.Error(),
+		}
+	}
+	if !isConfigHasRoutes(gatewayAPIConfig) {
+		return pluginTypes.NotVerified, pluginTypes.RpcError{
+			ErrorString: GatewayAPIManifestError,
+		}
+	}
+	if r.isDryRun(gatewayAPIConfig) {
+		// Routes are never written in dry run, so verifying them would block the rollout
+		r.LogCtx.Info("[DryRun] skipping weight verification")
+		return pluginTypes.Verified, pluginTypes.RpcError{}
+	}
+	isVerified := true
+	if gatewayAPIConfig.HTTPRoutes != nil {
+		rpcError := forEachGatewayAPIRoute(gatewayAPIConfig.HTTPRoutes, func(route HTTPRoute) error {
+			gatewayAPIConfig.HTTPRoute = route.Name
+			target := gatewayAPIConfig.newRouteTarget(route.Namespace, route.RuleNames, route.RuleIndexes)
+			isRouteVerified, err := r.verifyHTTPRouteWeight(rollout, desiredWeight, additionalDestinations, target, gatewayAPIConfig)
+			isVerified = isVerified && isRouteVerified
+			return err
+		})
+		if rpcError.HasError() {
+			return pluginTypes.NotVerified, rpcError
+		}
+	}
+	if gatewayAPIConfig.GRPCRoutes != nil {
+		rpcError := forEachGatewayAPIRoute(gatewayAPIConfig.GRPCRoutes, func(route GRPCRoute) error {
+			gatewayAPIConfig.GRPCRoute = route.Name
+			target := gatewayAPIConfig.newRouteTarget(route.Namespace, route.RuleNames, route.RuleIndexes)
+			isRouteVerified, err := r.verifyGRPCRouteWeight(rollout, desiredWeight, additionalDestinations, target, gatewayAPIConfig)
+			isVerified = isVerified && isRouteVerified
+			return err
+		})
+		if rpcError.HasError() {
+			return pluginTypes.NotVerified, rpcError
+		}
+	}
+	if gatewayAPIConfig.TCPRoutes != nil {
+		rpcError := forEachGatewayAPIRoute(gatewayAPIConfig.TCPRoutes, func(route TCPRoute) error {
+			gatewayAPIConfig.TCPRoute = route.Name
+			target := gatewayAPIConfig.newRouteTarget(route.Namespace, nil, nil)
+			isRouteVerified, err := r.verifyTCPRouteWeight(rollout, desiredWeight, additionalDestinations, target, gatewayAPIConfig)
+			isVerified = isVerified && isRouteVerified
+			return err
+		})
+		if rpcError.HasError() {
+			return pluginTypes.NotVerified, rpcError
+		}
+	}
+	if gatewayAPIConfig.TLSRoutes != nil {
+		rpcError := forEachGatewayAPIRoute(gatewayAPIConfig.TLSRoutes, func(route TLSRoute) error {
+			gatewayAPIConfig.TLSRoute = route.Name
+			target := gatewayAPIConfig.newRouteTarget(route.Namespace, nil, nil)
+			isRouteVerified, err := r.verifyTLSRouteWeight(rollout, desiredWeight, additionalDestinations, target, gatewayAPIConfig)
+			isVerified = isVerified && isRouteVerified
+			return err
+		})
+		if rpcError.HasError() {
+			return pluginTypes.NotVerified, rpcError
+		}
+	}
+	if gatewayAPIConfig.UDPRoutes != nil {
+		rpcError := forEachGatewayAPIRoute(gatewayAPIConfig.UDPRoutes, func(route UDPRoute) error {
+			gatewayAPIConfig.UDPRoute = route.Name
+			target := gatewayAPIConfig.newRouteTarget(route.Namespace, nil, nil)
+			isRouteVerified, err := r.verifyUDPRouteWeight(rollout, desiredWeight, target, gatewayAPIConfig)
+			isVerified = isVerified && isRouteVerified
+			return err
+		})
+		if rpcError.HasError() {
+			return pluginTypes.NotVerified, rpcError
+		}
+	}
+	if !isVerified {
+		return pluginTypes.NotVerified, pluginTypes.RpcError{}
+	}
 	return pluginTypes.Verified, pluginTypes.RpcError{}
 }
 
@@ -167,14 +346,20 @@ func (r *RpcPlugin) RemoveManagedRoutes(rollout *v1alpha1.Rollout) pluginTypes.R
 			ErrorString: err.Error(),
 		}
 	}
+	// The rollout is not running a canary anymore, so its routes stop reporting a weight
+	deleteCanaryWeights(rollout)
+	if rpcError := r.restoreRouteSpecs(rollout, gatewayAPIConfig); rpcError.HasError() {
+		return rpcError
+	}
 	if gatewayAPIConfig.HTTPRoutes != nil {
 		r.LogCtx.Info(fmt.Sprintf("[RemoveManagedRoutes] plugin %q controls HTTPRoutes: %v", PluginName, getGatewayAPIRouteNameList(gatewayAPIConfig.HTTPRoutes)))
-		rpcError := forEachGatewayAPIRoute(gatewayAPIConfig.HTTPRoutes, func(route HTTPRoute) pluginTypes.RpcError {
+		rpcError := forEachGatewayAPIRoute(gatewayAPIConfig.HTTPRoutes, func(route HTTPRoute) error {
 			if !route.UseHeaderRoutes {
-				return pluginTypes.RpcError{}
+				return nil
 			}
 			gatewayAPIConfig.HTTPRoute = route.Name
-			return r.removeHTTPManagedRoutes(rollout, gatewayAPIConfig)
+			target := gatewayAPIConfig.newRouteTarget(route.Namespace, route.RuleNames, route.RuleIndexes)
+			return r.removeHTTPManagedRoutes(rollout, target, gatewayAPIConfig)
 		})
 		if rpcError.HasError() {
 			return rpcError
@@ -182,12 +367,13 @@ func (r *RpcPlugin) RemoveManagedRoutes(rollout *v1alpha1.Rollout) pluginTypes.R
 	}
 	if gatewayAPIConfig.GRPCRoutes != nil {
 		r.LogCtx.Info(fmt.Sprintf("[RemoveManagedRoutes] plugin %q controls GRPCRoutes: %v", PluginName, getGatewayAPIRouteNameList(gatewayAPIConfig.GRPCRoutes)))
-		rpcError := forEachGatewayAPIRoute(gatewayAPIConfig.GRPCRoutes, func(route GRPCRoute) pluginTypes.RpcError {
+		rpcError := forEachGatewayAPIRoute(gatewayAPIConfig.GRPCRoutes, func(route GRPCRoute) error {
 			if !route.UseHeaderRoutes {
-				return pluginTypes.RpcError{}
+				return nil
 			}
 			gatewayAPIConfig.GRPCRoute = route.Name
-			return r.removeGRPCManagedRoutes(rollout, gatewayAPIConfig)
+			target := gatewayAPIConfig.newRouteTarget(route.Namespace, route.RuleNames, route.RuleIndexes)
+			return r.removeGRPCManagedRoutes(rollout, target, gatewayAPIConfig)
 		})
 		if rpcError.HasError() {
 			return rpcError
@@ -206,13 +392,25 @@ func (r *RpcPlugin) getGatewayAPIConfigWithDiscovery(rollout *v1alpha1.Rollout)
 		return nil, err
 	}
 
-	if gatewayAPIConfig.HTTPRouteSelector != nil ||
+	isSelectorSet := gatewayAPIConfig.HTTPRouteSelector != nil ||
 		gatewayAPIConfig.GRPCRouteSelector != nil ||
 		gatewayAPIConfig.TCPRouteSelector != nil ||
-		gatewayAPIConfig.TLSRouteSelector != nil {
-		if err := r.discoverRoutesBySelector(rollout, gatewayAPIConfig); err != nil {
-			return nil, err
-		}
+		gatewayAPIConfig.TLSRouteSelector != nil ||
+		gatewayAPIConfig.UDPRouteSelector != nil
+	// A gatewayRef or rootServiceRef without selectors discovers the routes of the parent by
+	// their backendRefs
+	isParentRefSet := gatewayAPIConfig.GatewayRef != nil || gatewayAPIConfig.RootServiceRef != nil
+	isDiscoverByBackendRefs := gatewayAPIConfig.DiscoverByBackendRefs || (isParentRefSet && !isSelectorSet)
+	if !isSelectorSet && !isDiscoverByBackendRefs {
+		return gatewayAPIConfig, nil
+	}
+
+	namespaces, err := r.getDiscoveryNamespaces(context.TODO(), gatewayAPIConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.discoverRoutes(rollout, namespaces, isDiscoverByBackendRefs, gatewayAPIConfig); err != nil {
+		return nil, err
 	}
 
 	return gatewayAPIConfig, nil
@@ -237,113 +435,431 @@ func getGatewayAPITrafficRoutingConfig(rollout *v1alpha1.Rollout) (*GatewayAPITr
 	return gatewayAPIConfig, err
 }
 
-func (r *RpcPlugin) discoverRoutesBySelector(rollout *v1alpha1.Rollout, gatewayAPIConfig *GatewayAPITrafficRouting) error {
-	namespace := gatewayAPIConfig.Namespace
+// discoverRoutes adds the routes of every kind discovered in namespaces to gatewayAPIConfig,
+// first the ones matching the selector of their kind and then, when isDiscoverByBackendRefs
+// is set, the ones found by their backendRefs.
+func (r *RpcPlugin) discoverRoutes(rollout *v1alpha1.Rollout, namespaces []string, isDiscoverByBackendRefs bool, gatewayAPIConfig *GatewayAPITrafficRouting) error {
+	ctx := context.TODO()
+	err := discoverGatewayAPIRoutes(ctx, r, rollout, namespaces, isDiscoverByBackendRefs, gatewayAPIConfig, routeDiscovery[HTTPRoute, gatewayv1.HTTPRoute]{
+		selector:        gatewayAPIConfig.HTTPRouteSelector,
+		useHeaderRoutes: gatewayAPIConfig.HTTPRouteSelectorUseHeaderRoutes,
+		routes:          &gatewayAPIConfig.HTTPRoutes,
+		listRoutes:      r.listHTTPRoutes,
+		getDiscoveredRoute: func(route *gatewayv1.HTTPRoute) discoveredRoute {
+			return discoveredRoute{
+				name:        route.Name,
+				parentRefs:  route.Spec.ParentRefs,
+				routeStatus: route.Status.RouteStatus,
+				hasRolloutRule: func(canaryMatcher, stableMatcher backendRefMatcher) bool {
+					_, err := getRouteRule(HTTPRouteRuleList(route.Spec.Rules), canaryMatcher, stableMatcher)
+					return err == nil
+				},
+			}
+		},
+		newRoute: func(name, namespace string, useHeaderRoutes bool) HTTPRoute {
+			return HTTPRoute{Name: name, Namespace: namespace, UseHeaderRoutes: useHeaderRoutes}
+		},
+	})
+	if err != nil {
+		return err
+	}
+	err = discoverGatewayAPIRoutes(ctx, r, rollout, namespaces, isDiscoverByBackendRefs, gatewayAPIConfig, routeDiscovery[GRPCRoute, gatewayv1.GRPCRoute]{
+		selector:        gatewayAPIConfig.GRPCRouteSelector,
+		useHeaderRoutes: gatewayAPIConfig.GRPCRouteSelectorUseHeaderRoutes,
+		routes:          &gatewayAPIConfig.GRPCRoutes,
+		listRoutes:      r.listGRPCRoutes,
+		getDiscoveredRoute: func(route *gatewayv1.GRPCRoute) discoveredRoute {
+			return discoveredRoute{
+				name:        route.Name,
+				parentRefs:  route.Spec.ParentRefs,
+				routeStatus: route.Status.RouteStatus,
+				hasRolloutRule: func(canaryMatcher, stableMatcher backendRefMatcher) bool {
+					_, err := getRouteRule(GRPCRouteRuleList(route.Spec.Rules), canaryMatcher, stableMatcher)
+					return err == nil
+				},
+			}
+		},
+		newRoute: func(name, namespace string, useHeaderRoutes bool) GRPCRoute {
+			return GRPCRoute{Name: name, Namespace: namespace, UseHeaderRoutes: useHeaderRoutes}
+		},
+	})
+	if err != nil {
+		return err
+	}
+	err = discoverGatewayAPIRoutes(ctx, r, rollout, namespaces, isDiscoverByBackendRefs, gatewayAPIConfig, routeDiscovery[TCPRoute, v1alpha2.TCPRoute]{
+		selector:   gatewayAPIConfig.TCPRouteSelector,
+		routes:     &gatewayAPIConfig.TCPRoutes,
+		listRoutes: r.listTCPRoutes,
+		getDiscoveredRoute: func(route *v1alpha2.TCPRoute) discoveredRoute {
+			return discoveredRoute{
+				name:        route.Name,
+				parentRefs:  route.Spec.ParentRefs,
+				routeStatus: route.Status.RouteStatus,
+				hasRolloutRule: func(canaryMatcher, stableMatcher backendRefMatcher) bool {
+					_, err := getRouteRule(TCPRouteRuleList(route.Spec.Rules), canaryMatcher, stableMatcher)
+					return err == nil
+				},
+			}
+		},
+		newRoute: func(name, namespace string, useHeaderRoutes bool) TCPRoute {
+			return TCPRoute{Name: name, Namespace: namespace, UseHeaderRoutes: useHeaderRoutes}
+		},
+	})
+	if err != nil {
+		return err
+	}
+	err = discoverGatewayAPIRoutes(ctx, r, rollout, namespaces, isDiscoverByBackendRefs, gatewayAPIConfig, routeDiscovery[TLSRoute, v1alpha2.TLSRoute]{
+		selector:   gatewayAPIConfig.TLSRouteSelector,
+		routes:     &gatewayAPIConfig.TLSRoutes,
+		listRoutes: r.listTLSRoutes,
+		getDiscoveredRoute: func(route *v1alpha2.TLSRoute) discoveredRoute {
+			return discoveredRoute{
+				name:        route.Name,
+				parentRefs:  route.Spec.ParentRefs,
+				routeStatus: route.Status.RouteStatus,
+				hasRolloutRule: func(canaryMatcher, stableMatcher backendRefMatcher) bool {
+					_, err := getRouteRule(TLSRouteRuleList(route.Spec.Rules), canaryMatcher, stableMatcher)
+					return err == nil
+				},
+			}
+		},
+		newRoute: func(name, namespace string, useHeaderRoutes bool) TLSRoute {
+			return TLSRoute{Name: name, Namespace: namespace, UseHeaderRoutes: useHeaderRoutes}
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return discoverGatewayAPIRoutes(ctx, r, rollout, namespaces, isDiscoverByBackendRefs, gatewayAPIConfig, routeDiscovery[UDPRoute, v1alpha2.UDPRoute]{
+		selector:   gatewayAPIConfig.UDPRouteSelector,
+		routes:     &gatewayAPIConfig.UDPRoutes,
+		listRoutes: r.listUDPRoutes,
+		getDiscoveredRoute: func(route *v1alpha2.UDPRoute) discoveredRoute {
+			return discoveredRoute{
+				name:        route.Name,
+				parentRefs:  route.Spec.ParentRefs,
+				routeStatus: route.Status.RouteStatus,
+				hasRolloutRule: func(canaryMatcher, stableMatcher backendRefMatcher) bool {
+					_, err := getRouteRule(UDPRouteRuleList(route.Spec.Rules), canaryMatcher, stableMatcher)
+					return err == nil
+				},
+			}
+		},
+		newRoute: func(name, namespace string, useHeaderRoutes bool) UDPRoute {
+			return UDPRoute{Name: name, Namespace: namespace, UseHeaderRoutes: useHeaderRoutes}
+		},
+	})
+}
 
-	if gatewayAPIConfig.HTTPRouteSelector != nil {
-		selector, err := metav1.LabelSelectorAsSelector(gatewayAPIConfig.HTTPRouteSelector)
-		if err != nil {
-			return err
-		}
+// routeDiscovery holds what discoverGatewayAPIRoutes needs to discover the Gateway API
+// routes of type APIRoute and add them to the configured routes of type Route.
+type routeDiscovery[Route GatewayAPIRoute, APIRoute any] struct {
+	selector *metav1.LabelSelector
+	// useHeaderRoutes is set on the routes discovered by selector
+	useHeaderRoutes    bool
+	routes             *[]Route
+	listRoutes         func(ctx context.Context, namespace string, selector labels.Selector) ([]APIRoute, error)
+	getDiscoveredRoute func(route *APIRoute) discoveredRoute
+	newRoute           func(name, namespace string, useHeaderRoutes bool) Route
+}
+
+// discoveredRoute is the part of a listed route that discovery looks at.
+type discoveredRoute struct {
+	name        string
+	parentRefs  []gatewayv1.ParentReference
+	routeStatus gatewayv1.RouteStatus
+	// hasRolloutRule reports whether a rule of the route references both the canary and the
+	// stable backend
+	hasRolloutRule func(canaryMatcher, stableMatcher backendRefMatcher) bool
+}
+
+// discoverGatewayAPIRoutes adds the routes of one kind in namespaces that are attached to the
+// parent and either match the selector of the kind or, when isDiscoverByBackendRefs is set,
+// have a rule that references both the stable and canary services. Routes that are already
+// configured or were discovered by the selector are not added again.
+func discoverGatewayAPIRoutes[Route GatewayAPIRoute, APIRoute any](ctx context.Context, r *RpcPlugin, rollout *v1alpha1.Rollout, namespaces []string, isDiscoverByBackendRefs bool, gatewayAPIConfig *GatewayAPITrafficRouting, discovery routeDiscovery[Route, APIRoute]) error {
+	var route Route
+	kind := getGatewayAPIRouteKind(route)
 
-		httpRouteList, err := r.GatewayAPIClientset.GatewayV1().HTTPRoutes(namespace).List(
-			context.TODO(),
-			metav1.ListOptions{LabelSelector: selector.String()},
-		)
+	if discovery.selector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(discovery.selector)
 		if err != nil {
 			return err
 		}
 
-		for _, route := range httpRouteList.Items {
-			gatewayAPIConfig.HTTPRoutes = append(gatewayAPIConfig.HTTPRoutes, HTTPRoute{
-				Name:            route.Name,
-				UseHeaderRoutes: false,
-			})
-		}
+		for _, namespace := range namespaces {
+			apiRoutes, err := discovery.listRoutes(ctx, namespace, selector)
+			if err != nil {
+				return err
+			}
 
-		if len(httpRouteList.Items) > 0 {
-			r.LogCtx.Info(fmt.Sprintf("[discoverRoutesBySelector] discovered %d HTTPRoutes via selector", len(httpRouteList.Items)))
+			discoveredCount := 0
+			for i := range apiRoutes {
+				discovered := discovery.getDiscoveredRoute(&apiRoutes[i])
+				if !gatewayAPIConfig.isAttachedToParent(namespace, discovered.parentRefs, discovered.routeStatus) {
+					continue
+				}
+				*discovery.routes = append(*discovery.routes, discovery.newRoute(discovered.name, namespace, discovery.useHeaderRoutes))
+				discoveredCount++
+			}
+
+			if discoveredCount > 0 {
+				r.LogCtx.Info(fmt.Sprintf("[discoverRoutes] discovered %d %ss in namespace %q via selector", discoveredCount, kind, namespace))
+			}
 		}
 	}
 
-	if gatewayAPIConfig.GRPCRouteSelector != nil {
-		selector, err := metav1.LabelSelectorAsSelector(gatewayAPIConfig.GRPCRouteSelector)
-		if err != nil {
-			return err
-		}
+	if !isDiscoverByBackendRefs {
+		return nil
+	}
+	stableService := rollout.Spec.Strategy.Canary.StableService
+	canaryService := rollout.Spec.Strategy.Canary.CanaryService
+	for _, namespace := range namespaces {
+		canaryMatcher := newBackendRefMatcher(canaryService, gatewayAPIConfig.CanaryBackendRef, rollout.Namespace, namespace)
+		stableMatcher := newBackendRefMatcher(stableService, gatewayAPIConfig.StableBackendRef, rollout.Namespace, namespace)
 
-		grpcRouteList, err := r.GatewayAPIClientset.GatewayV1().GRPCRoutes(namespace).List(
-			context.TODO(),
-			metav1.ListOptions{LabelSelector: selector.String()},
-		)
+		apiRoutes, err := listDiscoverableRoutes(ctx, r, namespace, kind, discovery.listRoutes)
 		if err != nil {
 			return err
 		}
-
-		for _, route := range grpcRouteList.Items {
-			gatewayAPIConfig.GRPCRoutes = append(gatewayAPIConfig.GRPCRoutes, GRPCRoute{
-				Name:            route.Name,
-				UseHeaderRoutes: false,
-			})
+		for i := range apiRoutes {
+			discovered := discovery.getDiscoveredRoute(&apiRoutes[i])
+			if !discovered.hasRolloutRule(canaryMatcher, stableMatcher) {
+				continue
+			}
+			if hasGatewayAPIRoute(*discovery.routes, namespace, discovered.name, gatewayAPIConfig.Namespace) {
+				continue
+			}
+			if !gatewayAPIConfig.isAttachedToParent(namespace, discovered.parentRefs, discovered.routeStatus) {
+				continue
+			}
+			*discovery.routes = append(*discovery.routes, discovery.newRoute(discovered.name, namespace, false))
+			r.logDiscoveredRoute(kind, namespace, discovered.name, stableMatcher, canaryMatcher)
 		}
+	}
+	return nil
+}
 
-		if len(grpcRouteList.Items) > 0 {
-			r.LogCtx.Info(fmt.Sprintf("[discoverRoutesBySelector] discovered %d GRPCRoutes via selector", len(grpcRouteList.Items)))
-		}
+// listDiscoverableRoutes returns the routes of kind in namespace for
+// discovery by backendRefs, or none when they cannot be discovered.
+func listDiscoverableRoutes[Route any](ctx context.Context, r *RpcPlugin, namespace, kind string, listRoutes func(ctx context.Context, namespace string, selector labels.Selector) ([]Route, error)) ([]Route, error) {
+	if r.cache.isKindUnserved(namespace, kind) {
+		return nil, nil
 	}
+	routes, err := listRoutes(ctx, namespace, labels.Everything())
+	if err != nil && r.isDiscoveryListSkipped(kind, err) {
+		return nil, nil
+	}
+	return routes, err
+}
 
-	if gatewayAPIConfig.TCPRouteSelector != nil {
-		selector, err := metav1.LabelSelectorAsSelector(gatewayAPIConfig.TCPRouteSelector)
-		if err != nil {
-			return err
+// isAttachedToParent reports whether a route in namespace has GatewayRef or RootServiceRef
+// among its parentRefs and was accepted by that parent. Every route is attached when neither
+// is set.
+func (c *GatewayAPITrafficRouting) isAttachedToParent(namespace string, parentRefs []gatewayv1.ParentReference, routeStatus gatewayv1.RouteStatus) bool {
+	if c.GatewayRef == nil && c.RootServiceRef == nil {
+		return true
+	}
+	isParentRef := false
+	for _, parentRef := range parentRefs {
+		if c.isParentRef(namespace, parentRef) {
+			isParentRef = true
+			break
 		}
-
-		tcpRouteList, err := r.GatewayAPIClientset.GatewayV1alpha2().TCPRoutes(namespace).List(
-			context.TODO(),
-			metav1.ListOptions{LabelSelector: selector.String()},
-		)
-		if err != nil {
-			return err
+	}
+	if !isParentRef {
+		return false
+	}
+	for _, parent := range routeStatus.Parents {
+		if !c.isParentRef(namespace, parent.ParentRef) {
+			continue
 		}
-
-		for _, route := range tcpRouteList.Items {
-			gatewayAPIConfig.TCPRoutes = append(gatewayAPIConfig.TCPRoutes, TCPRoute{
-				Name:            route.Name,
-				UseHeaderRoutes: false,
-			})
+		if meta.IsStatusConditionTrue(parent.Conditions, string(gatewayv1.RouteConditionAccepted)) {
+			return true
 		}
+	}
+	return false
+}
+
+// isParentRef reports whether parentRef of a route in namespace refers to GatewayRef or
+// RootServiceRef.
+func (c *GatewayAPITrafficRouting) isParentRef(namespace string, parentRef gatewayv1.ParentReference) bool {
+	return (c.GatewayRef != nil && c.GatewayRef.isParentRef(namespace, c.Namespace, parentRef)) ||
+		(c.RootServiceRef != nil && c.RootServiceRef.isParentRef(namespace, c.Namespace, parentRef))
+}
+
+// isParentRef reports whether parentRef of a route in routeNamespace refers to the Gateway.
+// The Gateway is in defaultNamespace when its namespace is not set, and a GatewayRef
+// without a sectionName matches every listener.
+func (g *GatewayRef) isParentRef(routeNamespace, defaultNamespace string, parentRef gatewayv1.ParentReference) bool {
+	if parentRef.Group != nil && *parentRef.Group != gatewayv1.GroupName {
+		return false
+	}
+	if parentRef.Kind != nil && *parentRef.Kind != "Gateway" {
+		return false
+	}
+	gatewayNamespace := g.Namespace
+	if gatewayNamespace == "" {
+		gatewayNamespace = defaultNamespace
+	}
+	parentNamespace := routeNamespace
+	if parentRef.Namespace != nil {
+		parentNamespace = string(*parentRef.Namespace)
+	}
+	if string(parentRef.Name) != g.Name || parentNamespace != gatewayNamespace {
+		return false
+	}
+	return g.SectionName == "" || (parentRef.SectionName != nil && string(*parentRef.SectionName) == g.SectionName)
+}
+
+// isParentRef reports whether parentRef of a route in routeNamespace refers to the Service.
+// The Service is in defaultNamespace when its namespace is not set, and a ServiceRef
+// without a port matches every port.
+func (s *ServiceRef) isParentRef(routeNamespace, defaultNamespace string, parentRef gatewayv1.ParentReference) bool {
+	if !isServiceParentRef(parentRef) {
+		return false
+	}
+	serviceNamespace := s.Namespace
+	if serviceNamespace == "" {
+		serviceNamespace = defaultNamespace
+	}
+	parentNamespace := routeNamespace
+	if parentRef.Namespace != nil {
+		parentNamespace = string(*parentRef.Namespace)
+	}
+	if string(parentRef.Name) != s.Name || parentNamespace != serviceNamespace {
+		return false
+	}
+	return s.Port == nil || (parentRef.Port != nil && int32(*parentRef.Port) == *s.Port)
+}
+
+// isServiceParentRef reports whether parentRef refers to a Service, as the routes of a
+// service mesh do in GAMMA. Some meshes, such as Linkerd, accept "core" as the group of
+// Services.
+func isServiceParentRef(parentRef gatewayv1.ParentReference) bool {
+	if parentRef.Kind == nil || *parentRef.Kind != serviceKind {
+		return false
+	}
+	return parentRef.Group == nil || *parentRef.Group == "" || *parentRef.Group == coreGroup
+}
+
+// isNotServedError reports whether a list failed because the cluster does not serve the
+// route kind, which is common for the experimental TCPRoute, TLSRoute and UDPRoute kinds.
+func isNotServedError(err error) bool {
+	return apierrors.IsNotFound(err) || meta.IsNoMatchError(err)
+}
+
+// isDiscoveryListSkipped reports whether discovery by backendRefs goes on without the routes
+// of kind after listing them failed with err. Kinds that the cluster does not serve are skipped,
+// and so are the TCPRoute, TLSRoute and UDPRoute kinds of the experimental channel when the
+// plugin is not allowed to list them.
+func (r *RpcPlugin) isDiscoveryListSkipped(kind string, err error) bool {
+	if isNotServedError(err) {
+		return true
+	}
+	if apierrors.IsForbidden(err) && kind != httpRouteGVK.Kind && kind != grpcRouteGVK.Kind {
+		r.LogCtx.Warn(fmt.Sprintf("[discoverRoutes] skipping %ss: %s", kind, err))
+		return true
+	}
+	return false
+}
+
+func (r *RpcPlugin) logDiscoveredRoute(kind, namespace, name string, stableMatcher, canaryMatcher backendRefMatcher) {
+	r.LogCtx.Info(fmt.Sprintf("[discoverRoutes] discovered %s %q in namespace %q: a rule references stable %s %q and canary %s %q", kind, name, namespace, stableMatcher.kind, stableMatcher.name, canaryMatcher.kind, canaryMatcher.name))
+}
 
-		if len(tcpRouteList.Items) > 0 {
-			r.LogCtx.Info(fmt.Sprintf("[discoverRoutesBySelector] discovered %d TCPRoutes via selector", len(tcpRouteList.Items)))
+// hasGatewayAPIRoute reports whether routeList has the route name in namespace. Routes
+// without a namespace are in defaultNamespace.
+func hasGatewayAPIRoute[T1 GatewayAPIRoute](routeList []T1, namespace, name, defaultNamespace string) bool {
+	for _, route := range routeList {
+		routeNamespace := route.GetNamespace()
+		if routeNamespace == "" {
+			routeNamespace = defaultNamespace
+		}
+		if routeNamespace == namespace && route.GetName() == name {
+			return true
 		}
 	}
+	return false
+}
 
-	if gatewayAPIConfig.TLSRouteSelector != nil {
-		selector, err := metav1.LabelSelectorAsSelector(gatewayAPIConfig.TLSRouteSelector)
+// getDiscoveryNamespaces returns the namespaces searched by route discovery, sorted by
+// name: the ones listed in Namespaces and the ones matching NamespaceSelector, or only
+// Namespace when neither is set.
+func (r *RpcPlugin) getDiscoveryNamespaces(ctx context.Context, gatewayAPIConfig *GatewayAPITrafficRouting) ([]string, error) {
+	if gatewayAPIConfig.NamespaceSelector == nil && len(gatewayAPIConfig.Namespaces) == 0 {
+		return []string{gatewayAPIConfig.Namespace}, nil
+	}
+	namespaceSet := make(map[string]bool)
+	for _, namespace := range gatewayAPIConfig.Namespaces {
+		namespaceSet[namespace] = true
+	}
+	if gatewayAPIConfig.NamespaceSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(gatewayAPIConfig.NamespaceSelector)
 		if err != nil {
-			return err
+			return nil, err
 		}
-
-		tlsRouteList, err := r.GatewayAPIClientset.GatewayV1alpha2().TLSRoutes(namespace).List(
-			context.TODO(),
-			metav1.ListOptions{LabelSelector: selector.String()},
-		)
+		namespaceList, err := r.Clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
 		if err != nil {
-			return err
+			return nil, err
 		}
-
-		for _, route := range tlsRouteList.Items {
-			gatewayAPIConfig.TLSRoutes = append(gatewayAPIConfig.TLSRoutes, TLSRoute{
-				Name:            route.Name,
-				UseHeaderRoutes: false,
-			})
+		for _, namespace := range namespaceList.Items {
+			namespaceSet[namespace.Name] = true
 		}
+	}
+	namespaces := make([]string, 0, len(namespaceSet))
+	for namespace := range namespaceSet {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
 
-		if len(tlsRouteList.Items) > 0 {
-			r.LogCtx.Info(fmt.Sprintf("[discoverRoutesBySelector] discovered %d TLSRoutes via selector", len(tlsRouteList.Items)))
-		}
+// newRouteTarget returns the target of a route in namespace, or in Namespace when the route
+// has no namespace of its own.
+func (c *GatewayAPITrafficRouting) newRouteTarget(namespace string, ruleNames []string, ruleIndexes []int) routeTarget {
+	if namespace == "" {
+		namespace = c.Namespace
+	}
+	return routeTarget{
+		namespace:   namespace,
+		ruleNames:   ruleNames,
+		ruleIndexes: ruleIndexes,
+	}
+}
+
+// isRuleTargeted reports whether the plugin may change the rule at index with name. Every
+// rule is targeted when the route lists neither rule names nor rule indexes.
+func (t routeTarget) isRuleTargeted(index int, name *gatewayv1.SectionName) bool {
+	if len(t.ruleNames) == 0 && len(t.ruleIndexes) == 0 {
+		return true
+	}
+	if name != nil && slices.Contains(t.ruleNames, string(*name)) {
+		return true
 	}
+	return slices.Contains(t.ruleIndexes, index)
+}
 
+// checkRules returns an error when a rule name or index listed for the route does not match
+// any of ruleNames, the names of the route rules.
+func (t routeTarget) checkRules(kind, routeName string, ruleNames []*gatewayv1.SectionName) error {
+	for _, ruleIndex := range t.ruleIndexes {
+		if ruleIndex < 0 || ruleIndex >= len(ruleNames) {
+			return newPluginError("RouteRuleIndexWasNotFoundError", RouteRuleIndexWasNotFoundError, ruleIndex, kind, routeName)
+		}
+	}
+	for _, routeRuleName := range t.ruleNames {
+		isFound := false
+		for _, ruleName := range ruleNames {
+			if ruleName != nil && string(*ruleName) == routeRuleName {
+				isFound = true
+				break
+			}
+		}
+		if !isFound {
+			return newPluginError("RouteRuleNameWasNotFoundError", RouteRuleNameWasNotFoundError, routeRuleName, kind, routeName)
+		}
+	}
 	return nil
 }
 
@@ -372,6 +888,12 @@ func insertGatewayAPIRouteLists(gatewayAPIConfig *GatewayAPITrafficRouting) {
 			UseHeaderRoutes: true,
 		})
 	}
+	if gatewayAPIConfig.UDPRoute != "" {
+		gatewayAPIConfig.UDPRoutes = append(gatewayAPIConfig.UDPRoutes, UDPRoute{
+			Name:            gatewayAPIConfig.UDPRoute,
+			UseHeaderRoutes: true,
+		})
+	}
 }
 
 // isManagedRuleName reports whether ruleName was produced by this plugin for any of
@@ -393,7 +915,7 @@ func isManagedRuleName(ruleName string, managedNames map[string]bool) bool {
 	return false
 }
 
-func getRouteRule[BackendRef GatewayAPIBackendRef, RouteRule GatewayAPIRouteRule[BackendRef], RouteRuleList GatewayAPIRouteRuleList[BackendRef, RouteRule]](routeRuleList RouteRuleList, backendRefNameList ...string) (RouteRule, error) {
+func getRouteRule[BackendRef GatewayAPIBackendRef, RouteRule GatewayAPIRouteRule[BackendRef], RouteRuleList GatewayAPIRouteRuleList[BackendRef, RouteRule]](routeRuleList RouteRuleList, backendRefMatchers ...backendRefMatcher) (RouteRule, error) {
 	var backendRef BackendRef
 	var routeRule RouteRule
 	isFound := false
@@ -403,11 +925,11 @@ func getRouteRule[BackendRef GatewayAPIBackendRef, RouteRule GatewayAPIRouteRule
 		if !hasNext {
 			continue
 		}
-		for _, backendRefName := range backendRefNameList {
+		for _, backendRefMatcher := range backendRefMatchers {
 			isFound = false
 			for next, hasNext := routeRule.Iterator(); hasNext; {
 				backendRef, hasNext = next()
-				if backendRefName == backendRef.GetName() {
+				if backendRefMatcher.matches(backendRef.GetBackendObjectReference()) {
 					isFound = true
 					continue
 				}
@@ -423,10 +945,32 @@ func getRouteRule[BackendRef GatewayAPIBackendRef, RouteRule GatewayAPIRouteRule
 	return nil, routeRuleList.Error()
 }
 
-// getAllRouteRules returns every rule in routeRuleList that contains all of the named
-// backends. Used by setHeaderRoute to build one managed rule per source rule on
+// getAllRouteRules returns every rule in routeRuleList that contains a backendRef matching
+// each of backendRefMatchers. Used by setHeaderRoute to build one managed rule per source rule on
 // multi-rule routes (issue #207).
-func getAllRouteRules[BackendRef GatewayAPIBackendRef, RouteRule GatewayAPIRouteRule[BackendRef], RouteRuleList GatewayAPIRouteRuleList[BackendRef, RouteRule]](routeRuleList RouteRuleList, backendRefNameList ...string) ([]RouteRule, error) {
+// getCanaryBackendObjectReference returns a reference to the canary service with the group,
+// kind, namespace and port of the canary backend of routeRule.
+func getCanaryBackendObjectReference[BackendRef GatewayAPIBackendRef, RouteRule GatewayAPIRouteRule[BackendRef]](routeRule RouteRule, canaryMatcher backendRefMatcher) gatewayv1.BackendObjectReference {
+	var backendRef BackendRef
+	for nextRef, hasRef := routeRule.Iterator(); hasRef; {
+		backendRef, hasRef = nextRef()
+		canaryBackendRef := backendRef.GetBackendObjectReference()
+		if !canaryMatcher.matches(canaryBackendRef) {
+			continue
+		}
+		canaryGroup, canaryKind := canaryMatcher.getGroupKind(canaryBackendRef)
+		return gatewayv1.BackendObjectReference{
+			Group:     canaryGroup,
+			Kind:      canaryKind,
+			Name:      gatewayv1.ObjectName(canaryMatcher.name),
+			Namespace: canaryBackendRef.Namespace,
+			Port:      canaryBackendRef.Port,
+		}
+	}
+	return gatewayv1.BackendObjectReference{}
+}
+
+func getAllRouteRules[BackendRef GatewayAPIBackendRef, RouteRule GatewayAPIRouteRule[BackendRef], RouteRuleList GatewayAPIRouteRuleList[BackendRef, RouteRule]](routeRuleList RouteRuleList, backendRefMatchers ...backendRefMatcher) ([]RouteRule, error) {
 	var backendRef BackendRef
 	var routeRule RouteRule
 	var result []RouteRule
@@ -437,11 +981,11 @@ func getAllRouteRules[BackendRef GatewayAPIBackendRef, RouteRule GatewayAPIRoute
 			continue
 		}
 		allFound := true
-		for _, backendRefName := range backendRefNameList {
+		for _, backendRefMatcher := range backendRefMatchers {
 			found := false
 			for nextRef, hasRef := routeRule.Iterator(); hasRef; {
 				backendRef, hasRef = nextRef()
-				if backendRefName == backendRef.GetName() {
+				if backendRefMatcher.matches(backendRef.GetBackendObjectReference()) {
 					found = true
 				}
 			}
@@ -460,7 +1004,7 @@ func getAllRouteRules[BackendRef GatewayAPIBackendRef, RouteRule GatewayAPIRoute
 	return result, nil
 }
 
-func getBackendRefs[T1 GatewayAPIBackendRef, T2 GatewayAPIRouteRule[T1], T3 GatewayAPIRouteRuleList[T1, T2]](backendRefName string, routeRuleList T3) ([]T1, error) {
+func getBackendRefs[T1 GatewayAPIBackendRef, T2 GatewayAPIRouteRule[T1], T3 GatewayAPIRouteRuleList[T1, T2]](backendRefMatcher backendRefMatcher, routeRuleList T3) ([]T1, error) {
 	var backendRef T1
 	var routeRule T2
 	var matchedRefs []T1
@@ -468,7 +1012,7 @@ func getBackendRefs[T1 GatewayAPIBackendRef, T2 GatewayAPIRouteRule[T1], T3 Gate
 		routeRule, hasNext = next()
 		for next, hasNext := routeRule.Iterator(); hasNext; {
 			backendRef, hasNext = next()
-			if backendRefName == backendRef.GetName() {
+			if backendRefMatcher.matches(backendRef.GetBackendObjectReference()) {
 				matchedRefs = append(matchedRefs, backendRef)
 			}
 		}
@@ -479,15 +1023,50 @@ func getBackendRefs[T1 GatewayAPIBackendRef, T2 GatewayAPIRouteRule[T1], T3 Gate
 	return nil, routeRuleList.Error()
 }
 
+// isRouteAccepted reports whether every parent of a route has accepted the given route
+// generation and resolved all of its backendRefs. A route without any parent status has
+// not been processed by the Gateway controller yet.
+func isRouteAccepted(generation int64, routeStatus gatewayv1.RouteStatus) (bool, string) {
+	if len(routeStatus.Parents) == 0 {
+		return false, "route has no parent status yet"
+	}
+	for _, parent := range routeStatus.Parents {
+		// Mesh controllers may not report the observed generation of Service parents
+		isMeshParent := isServiceParentRef(parent.ParentRef)
+		for _, conditionType := range []gatewayv1.RouteConditionType{gatewayv1.RouteConditionAccepted, gatewayv1.RouteConditionResolvedRefs} {
+			condition := meta.FindStatusCondition(parent.Conditions, string(conditionType))
+			switch {
+			case condition == nil:
+				return false, fmt.Sprintf("parent %q has no %s condition", parent.ParentRef.Name, conditionType)
+			case condition.ObservedGeneration < generation && !(isMeshParent && condition.ObservedGeneration == 0):
+				return false, fmt.Sprintf("parent %q observed generation %d of %d", parent.ParentRef.Name, condition.ObservedGeneration, generation)
+			case condition.Status != metav1.ConditionTrue:
+				return false, fmt.Sprintf("parent %q has %s=%s: %s", parent.ParentRef.Name, conditionType, condition.Status, condition.Message)
+			}
+		}
+	}
+	return true, ""
+}
+
+// isWeightEqual treats an unset backendRef weight as the Gateway API default of 1.
+func isWeightEqual(weight *int32, expectedWeight int32) bool {
+	if weight == nil {
+		return expectedWeight == 1
+	}
+	return *weight == expectedWeight
+}
+
 func isConfigHasRoutes(config *GatewayAPITrafficRouting) bool {
-	return len(config.HTTPRoutes) > 0 || len(config.TCPRoutes) > 0 || len(config.GRPCRoutes) > 0 || len(config.TLSRoutes) > 0
+	return len(config.HTTPRoutes) > 0 || len(config.TCPRoutes) > 0 || len(config.GRPCRoutes) > 0 || len(config.TLSRoutes) > 0 || len(config.UDPRoutes) > 0
 }
 
-func forEachGatewayAPIRoute[T1 GatewayAPIRoute](routeList []T1, fn func(route T1) pluginTypes.RpcError) pluginTypes.RpcError {
-	var err pluginTypes.RpcError
+func forEachGatewayAPIRoute[T1 GatewayAPIRoute](routeList []T1, fn func(route T1) error) pluginTypes.RpcError {
 	for _, route := range routeList {
-		if err = fn(route); err.HasError() {
-			return err
+		if err := fn(route); err != nil {
+			recordRouteError(getGatewayAPIRouteKind(route), err)
+			return pluginTypes.RpcError{
+				ErrorString: err.Error(),
+			}
 		}
 	}
 	return pluginTypes.RpcError{}
@@ -3,6 +3,13 @@ package plugin
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -11,14 +18,30 @@ import (
 	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-gatewayapi/internal/utils"
 	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-gatewayapi/pkg/mocks"
 	"github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
+	rolloutFake "github.com/argoproj/argo-rollouts/pkg/client/clientset/versioned/fake"
 	rolloutsPlugin "github.com/argoproj/argo-rollouts/rollout/trafficrouting/plugin/rpc"
+	pluginTypes "github.com/argoproj/argo-rollouts/utils/plugin/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	"sigs.k8s.io/gateway-api/apis/v1alpha2"
+	gatewayv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
 
 	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	kubeFake "k8s.io/client-go/kubernetes/fake"
+	coreListers "k8s.io/client-go/listers/core/v1"
+	k8stesting "k8s.io/client-go/testing"
+	toolsCache "k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	gwFake "sigs.k8s.io/gateway-api/pkg/client/clientset/versioned/fake"
+	gatewayListersV1 "sigs.k8s.io/gateway-api/pkg/client/listers/apis/v1"
 
 	goPlugin "github.com/hashicorp/go-plugin"
 )
@@ -239,6 +262,66 @@ func TestRunSuccessfully(t *testing.T) {
 		_, exists := updatedTLS.Labels[defaults.InProgressLabelKey]
 		assert.False(t, exists)
 	})
+	t.Run("SetUDPRouteWeight", func(t *testing.T) {
+		var desiredWeight int32 = 30
+		rpcPluginImp.GatewayAPIClientset = gwFake.NewSimpleClientset(&mocks.HTTPRouteObj, &mocks.GRPCRouteObj, &mocks.TCPPRouteObj, &mocks.TLSRouteObj, &mocks.UDPRouteObj)
+		rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName,
+			&GatewayAPITrafficRouting{
+				Namespace: mocks.RolloutNamespace,
+				UDPRoute:  mocks.UDPRouteName,
+			})
+		err := pluginInstance.SetWeight(rollout, desiredWeight, []v1alpha1.WeightDestination{})
+
+		assert.Empty(t, err.Error())
+		updatedUDP, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1alpha2().UDPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.UDPRouteName, metav1.GetOptions{})
+		require.NoError(t, getErr)
+		assert.Equal(t, 100-desiredWeight, *(updatedUDP.Spec.Rules[0].BackendRefs[0].Weight))
+		assert.Equal(t, desiredWeight, *(updatedUDP.Spec.Rules[0].BackendRefs[1].Weight))
+	})
+	t.Run("SetUDPRouteWeightDuringExperiment", func(t *testing.T) {
+		rpcPluginImp.GatewayAPIClientset = gwFake.NewSimpleClientset(&mocks.UDPRouteObj)
+		rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName,
+			&GatewayAPITrafficRouting{
+				Namespace: mocks.RolloutNamespace,
+				UDPRoute:  mocks.UDPRouteName,
+			})
+		rollout.Status.Canary.CurrentExperiment = "experiment"
+		additionalDestinations := []v1alpha1.WeightDestination{{ServiceName: "experiment-service", Weight: 20}}
+
+		// UDPRoutes get no experiment services, so the stable service keeps the rest of the weight
+		err := pluginInstance.SetWeight(rollout, 30, additionalDestinations)
+		require.Empty(t, err.Error())
+		updatedUDP, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1alpha2().UDPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.UDPRouteName, metav1.GetOptions{})
+		require.NoError(t, getErr)
+		require.Len(t, updatedUDP.Spec.Rules[0].BackendRefs, 2)
+		assert.Equal(t, int32(70), *(updatedUDP.Spec.Rules[0].BackendRefs[0].Weight))
+		assert.Equal(t, int32(30), *(updatedUDP.Spec.Rules[0].BackendRefs[1].Weight))
+
+		err = pluginInstance.SetWeight(rollout, 90, additionalDestinations)
+		require.Empty(t, err.Error())
+	})
+	t.Run("SetUDPRouteWeightAddsAndRemovesLabel", func(t *testing.T) {
+		udpRoute := mocks.CreateUDPRouteWithLabels(mocks.UDPRouteName, nil)
+		rpcPluginImp.GatewayAPIClientset = gwFake.NewSimpleClientset(&mocks.HTTPRouteObj, &mocks.GRPCRouteObj, &mocks.TCPPRouteObj, &mocks.TLSRouteObj, udpRoute)
+		rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName,
+			&GatewayAPITrafficRouting{
+				Namespace: mocks.RolloutNamespace,
+				UDPRoute:  mocks.UDPRouteName,
+			})
+
+		err := pluginInstance.SetWeight(rollout, 45, []v1alpha1.WeightDestination{})
+		assert.Empty(t, err.Error())
+		updatedUDP, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1alpha2().UDPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.UDPRouteName, metav1.GetOptions{})
+		require.NoError(t, getErr)
+		assert.Equal(t, defaults.InProgressLabelValue, updatedUDP.Labels[defaults.InProgressLabelKey])
+
+		err = pluginInstance.SetWeight(rollout, 0, []v1alpha1.WeightDestination{})
+		assert.Empty(t, err.Error())
+		updatedUDP, getErr = rpcPluginImp.GatewayAPIClientset.GatewayV1alpha2().UDPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.UDPRouteName, metav1.GetOptions{})
+		require.NoError(t, getErr)
+		_, exists := updatedUDP.Labels[defaults.InProgressLabelKey]
+		assert.False(t, exists)
+	})
 	t.Run("SetWeightViaRoutes", func(t *testing.T) {
 		var desiredWeight int32 = 30
 		rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName,
@@ -587,6 +670,35 @@ func TestRunSuccessfully(t *testing.T) {
 		require.NoError(t, getErr)
 		assert.Len(t, updatedGRPC.Spec.Rules, 1)
 	})
+	t.Run("RemoveManagedRoutesKeepsForeignRulesWithCanaryName", func(t *testing.T) {
+		httpRoute := mocks.HTTPRouteObj.DeepCopy()
+		serviceImportGroup := gatewayv1.Group(backendKindGroups[serviceImportKind])
+		importKind := gatewayv1.Kind(serviceImportKind)
+		otherNamespace := gatewayv1.Namespace("other")
+		for _, backendRef := range []gatewayv1.BackendObjectReference{
+			// An unnamed rule left by an older plugin version, which is removed
+			{Name: mocks.CanaryServiceName},
+			{Name: mocks.CanaryServiceName, Group: &serviceImportGroup, Kind: &importKind},
+			{Name: mocks.CanaryServiceName, Namespace: &otherNamespace},
+		} {
+			httpRoute.Spec.Rules = append(httpRoute.Spec.Rules, gatewayv1.HTTPRouteRule{
+				BackendRefs: []gatewayv1.HTTPBackendRef{{BackendRef: gatewayv1.BackendRef{BackendObjectReference: backendRef}}},
+			})
+		}
+		rpcPluginImp.GatewayAPIClientset = gwFake.NewSimpleClientset(httpRoute)
+		rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+			Namespace: mocks.RolloutNamespace,
+			HTTPRoute: mocks.HTTPRouteName,
+		})
+		err := pluginInstance.RemoveManagedRoutes(rollout)
+
+		assert.Empty(t, err.Error())
+		updatedHTTP, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+		require.NoError(t, getErr)
+		require.Len(t, updatedHTTP.Spec.Rules, len(mocks.HTTPRouteObj.Spec.Rules)+2)
+		assert.Equal(t, &importKind, updatedHTTP.Spec.Rules[len(updatedHTTP.Spec.Rules)-2].BackendRefs[0].Kind)
+		assert.Equal(t, &otherNamespace, updatedHTTP.Spec.Rules[len(updatedHTTP.Spec.Rules)-1].BackendRefs[0].Namespace)
+	})
 	t.Run("SetWeightDoesNotClobberHTTPHeaderRouteWeight", func(t *testing.T) {
 		// Reproduces issues #158 and #169: SetWeight(0) must not touch the canary
 		// BackendRef weight in a plugin-injected header-routing rule.
@@ -725,420 +837,968 @@ func TestCombinedSelectorAndExplicitRoute(t *testing.T) {
 	assert.Equal(t, "explicit-route", parsedConfig.HTTPRoutes[0].Name)
 }
 
-func TestNamespaceDefaulting(t *testing.T) {
-	t.Run("DefaultsToRolloutNamespaceWhenNotSpecified", func(t *testing.T) {
-		// Create a rollout with namespace "my-namespace" but config without namespace
-		config := &GatewayAPITrafficRouting{
-			// Namespace intentionally not set (empty string)
-			HTTPRoute: mocks.HTTPRouteName,
-		}
-		rolloutNamespace := "my-namespace"
-		rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, config, rolloutNamespace)
+func TestUDPRouteSelectorDiscovery(t *testing.T) {
+	selectedRoute := mocks.CreateUDPRouteWithLabels(mocks.UDPRouteName, map[string]string{"app": "dns"})
+	otherRoute := mocks.CreateUDPRouteWithLabels("other-udp-route", map[string]string{"app": "other"})
+	rpcPluginImp := &RpcPlugin{
+		LogCtx:              utils.SetupLog("text"),
+		GatewayAPIClientset: gwFake.NewSimpleClientset(selectedRoute, otherRoute),
+	}
+	rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+		Namespace: mocks.RolloutNamespace,
+		UDPRouteSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{"app": "dns"},
+		},
+	})
 
-		// Parse the config - this is where namespace defaulting should happen
-		parsedConfig, err := getGatewayAPITrafficRoutingConfig(rollout)
+	err := rpcPluginImp.SetWeight(rollout, 10, []v1alpha1.WeightDestination{})
+	assert.Empty(t, err.Error())
 
-		require.NoError(t, err)
-		// Before the fix, this would be empty string. After the fix, it should default to rollout's namespace.
-		assert.Equal(t, rolloutNamespace, parsedConfig.Namespace, "Namespace should default to rollout's namespace when not specified")
-	})
+	updatedUDP, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1alpha2().UDPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.UDPRouteName, metav1.GetOptions{})
+	require.NoError(t, getErr)
+	assert.Equal(t, int32(90), *updatedUDP.Spec.Rules[0].BackendRefs[0].Weight)
+	assert.Equal(t, int32(10), *updatedUDP.Spec.Rules[0].BackendRefs[1].Weight)
+	untouchedUDP, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1alpha2().UDPRoutes(mocks.RolloutNamespace).Get(context.Background(), "other-udp-route", metav1.GetOptions{})
+	require.NoError(t, getErr)
+	assert.Equal(t, int32(100), *untouchedUDP.Spec.Rules[0].BackendRefs[0].Weight)
+}
 
-	t.Run("UsesExplicitNamespaceWhenSpecified", func(t *testing.T) {
-		// Create a rollout with explicit namespace in config
-		explicitNamespace := "explicit-namespace"
-		config := &GatewayAPITrafficRouting{
-			Namespace: explicitNamespace,
-			HTTPRoute: mocks.HTTPRouteName,
-		}
-		rolloutNamespace := "rollout-namespace"
-		rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, config, rolloutNamespace)
+func TestNamespaceSelectorDiscovery(t *testing.T) {
+	sharedRoute := mocks.CreateHTTPRouteWithLabels("shared-route", map[string]string{"app": "test-app"})
+	sharedRoute.Namespace = "gateways"
+	setHTTPRouteBackendRefNamespace(sharedRoute, mocks.RolloutNamespace)
+	localRoute := mocks.CreateHTTPRouteWithLabels(mocks.HTTPRouteName, map[string]string{"app": "test-app"})
+	ignoredRoute := mocks.CreateHTTPRouteWithLabels("ignored-route", map[string]string{"app": "test-app"})
+	ignoredRoute.Namespace = "other"
+	rpcPluginImp := &RpcPlugin{
+		LogCtx:              utils.SetupLog("text"),
+		GatewayAPIClientset: gwFake.NewSimpleClientset(sharedRoute, localRoute, ignoredRoute, newServiceReferenceGrant("HTTPRoute", "gateways", nil)),
+		Clientset: kubeFake.NewSimpleClientset(
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "gateways", Labels: map[string]string{"shared-gateway": "true"}}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "other"}},
+		),
+	}
+	rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+		Namespace:  mocks.RolloutNamespace,
+		Namespaces: []string{mocks.RolloutNamespace},
+		NamespaceSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{"shared-gateway": "true"},
+		},
+		HTTPRouteSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{"app": "test-app"},
+		},
+	})
 
-		// Parse the config
-		parsedConfig, err := getGatewayAPITrafficRoutingConfig(rollout)
+	gatewayAPIConfig, err := rpcPluginImp.getGatewayAPIConfigWithDiscovery(rollout)
+	require.NoError(t, err)
+	require.Len(t, gatewayAPIConfig.HTTPRoutes, 2)
+	assert.Equal(t, HTTPRoute{Name: mocks.HTTPRouteName, Namespace: mocks.RolloutNamespace}, gatewayAPIConfig.HTTPRoutes[0])
+	assert.Equal(t, HTTPRoute{Name: "shared-route", Namespace: "gateways"}, gatewayAPIConfig.HTTPRoutes[1])
+
+	rpcError := rpcPluginImp.SetWeight(rollout, 30, []v1alpha1.WeightDestination{})
+	require.Empty(t, rpcError.Error())
+	for _, route := range []struct{ namespace, name string }{{"gateways", "shared-route"}, {mocks.RolloutNamespace, mocks.HTTPRouteName}} {
+		updatedHTTP, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(route.namespace).Get(context.Background(), route.name, metav1.GetOptions{})
+		require.NoError(t, getErr)
+		assert.Equal(t, int32(70), *updatedHTTP.Spec.Rules[0].BackendRefs[0].Weight)
+		assert.Equal(t, int32(30), *updatedHTTP.Spec.Rules[0].BackendRefs[1].Weight)
+	}
+	untouchedHTTP, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes("other").Get(context.Background(), "ignored-route", metav1.GetOptions{})
+	require.NoError(t, getErr)
+	assert.Equal(t, int32(100), *untouchedHTTP.Spec.Rules[0].BackendRefs[0].Weight)
+}
 
-		require.NoError(t, err)
-		// Should use the explicitly specified namespace, not the rollout's namespace
-		assert.Equal(t, explicitNamespace, parsedConfig.Namespace, "Should use explicit namespace when specified")
+func TestRouteNamespaceReferenceGrant(t *testing.T) {
+	sharedRoute := mocks.CreateHTTPRouteWithLabels("shared-route", nil)
+	sharedRoute.Namespace = "gateways"
+	setHTTPRouteBackendRefNamespace(sharedRoute, mocks.RolloutNamespace)
+	rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+		HTTPRoutes: []HTTPRoute{
+			{Name: mocks.HTTPRouteName},
+			{Name: "shared-route", Namespace: "gateways"},
+		},
 	})
+	stableServiceName := gatewayv1beta1.ObjectName(mocks.StableServiceName)
 
-	t.Run("DefaultsToRolloutNamespaceWithEmptyString", func(t *testing.T) {
-		// Explicitly set namespace to empty string
-		config := &GatewayAPITrafficRouting{
-			Namespace: "", // Explicitly empty
-			GRPCRoute: mocks.GRPCRouteName,
+	t.Run("MissingReferenceGrant", func(t *testing.T) {
+		rpcPluginImp := &RpcPlugin{
+			LogCtx:              utils.SetupLog("text"),
+			GatewayAPIClientset: gwFake.NewSimpleClientset(&mocks.HTTPRouteObj, sharedRoute.DeepCopy(), newServiceReferenceGrant("GRPCRoute", "gateways", nil)),
 		}
-		rolloutNamespace := "another-namespace"
-		rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, config, rolloutNamespace)
+		rpcError := rpcPluginImp.SetWeight(rollout, 30, []v1alpha1.WeightDestination{})
+		assert.Equal(t, fmt.Sprintf(ReferenceGrantWasNotFoundError, mocks.RolloutNamespace, "HTTPRoute", "shared-route", "gateways", "Service", mocks.StableServiceName), rpcError.Error())
+	})
 
-		parsedConfig, err := getGatewayAPITrafficRoutingConfig(rollout)
+	t.Run("ReferenceGrantForOtherService", func(t *testing.T) {
+		rpcPluginImp := &RpcPlugin{
+			LogCtx:              utils.SetupLog("text"),
+			GatewayAPIClientset: gwFake.NewSimpleClientset(&mocks.HTTPRouteObj, sharedRoute.DeepCopy(), newServiceReferenceGrant("HTTPRoute", "gateways", &stableServiceName)),
+		}
+		rpcError := rpcPluginImp.SetWeight(rollout, 30, []v1alpha1.WeightDestination{})
+		assert.Equal(t, fmt.Sprintf(ReferenceGrantWasNotFoundError, mocks.RolloutNamespace, "HTTPRoute", "shared-route", "gateways", "Service", mocks.CanaryServiceName), rpcError.Error())
+	})
 
-		require.NoError(t, err)
-		assert.Equal(t, rolloutNamespace, parsedConfig.Namespace, "Empty namespace should default to rollout's namespace")
+	t.Run("ReferenceGrantForAllServices", func(t *testing.T) {
+		rpcPluginImp := &RpcPlugin{
+			LogCtx:              utils.SetupLog("text"),
+			GatewayAPIClientset: gwFake.NewSimpleClientset(&mocks.HTTPRouteObj, sharedRoute.DeepCopy(), newServiceReferenceGrant("HTTPRoute", "gateways", nil)),
+		}
+		rpcError := rpcPluginImp.SetWeight(rollout, 30, []v1alpha1.WeightDestination{})
+		require.Empty(t, rpcError.Error())
+		updatedHTTP, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes("gateways").Get(context.Background(), "shared-route", metav1.GetOptions{})
+		require.NoError(t, getErr)
+		assert.Equal(t, int32(30), *updatedHTTP.Spec.Rules[0].BackendRefs[1].Weight)
+	})
+	t.Run("ReferenceGrantWithoutExperimentService", func(t *testing.T) {
+		canaryServiceName := gatewayv1beta1.ObjectName(mocks.CanaryServiceName)
+		referenceGrant := newServiceReferenceGrant("HTTPRoute", "gateways", &stableServiceName)
+		referenceGrant.Spec.To = append(referenceGrant.Spec.To, gatewayv1beta1.ReferenceGrantTo{Kind: "Service", Name: &canaryServiceName})
+		rpcPluginImp := &RpcPlugin{
+			LogCtx:              utils.SetupLog("text"),
+			GatewayAPIClientset: gwFake.NewSimpleClientset(&mocks.HTTPRouteObj, sharedRoute.DeepCopy(), referenceGrant),
+		}
+		experimentRollout := rollout.DeepCopy()
+		experimentRollout.Status.Canary.CurrentExperiment = "experiment"
+		rpcError := rpcPluginImp.SetWeight(experimentRollout, 30, []v1alpha1.WeightDestination{{ServiceName: "experiment-service", Weight: 10}})
+		assert.Equal(t, fmt.Sprintf(ReferenceGrantWasNotFoundError, mocks.RolloutNamespace, "HTTPRoute", "shared-route", "gateways", "Service", "experiment-service"), rpcError.Error())
+
+		// Without an active experiment the experiment services are not added, so they need no grant
+		rpcError = rpcPluginImp.SetWeight(rollout, 30, []v1alpha1.WeightDestination{{ServiceName: "experiment-service", Weight: 10}})
+		require.Empty(t, rpcError.Error())
+	})
+	t.Run("ReferenceGrantWithCoreGroup", func(t *testing.T) {
+		referenceGrant := newServiceReferenceGrant("HTTPRoute", "gateways", nil)
+		referenceGrant.Spec.To[0].Group = coreGroup
+		rpcPluginImp := &RpcPlugin{
+			LogCtx:              utils.SetupLog("text"),
+			GatewayAPIClientset: gwFake.NewSimpleClientset(&mocks.HTTPRouteObj, sharedRoute.DeepCopy(), referenceGrant),
+		}
+		rpcError := rpcPluginImp.SetWeight(rollout, 30, []v1alpha1.WeightDestination{})
+		require.Empty(t, rpcError.Error())
 	})
 }
 
-func newRollout(stableSvc, canarySvc string, config *GatewayAPITrafficRouting, namespace ...string) *v1alpha1.Rollout {
-	ns := mocks.RolloutNamespace
-	if len(namespace) > 0 {
-		ns = namespace[0]
-	}
-	encodedConfig, err := json.Marshal(config)
-	if err != nil {
-		log.Fatal(err)
-	}
-	return &v1alpha1.Rollout{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "rollout",
-			Namespace: ns,
-		},
-		Spec: v1alpha1.RolloutSpec{
-			Strategy: v1alpha1.RolloutStrategy{
-				Canary: &v1alpha1.CanaryStrategy{
-					StableService: stableSvc,
-					CanaryService: canarySvc,
-					TrafficRouting: &v1alpha1.RolloutTrafficRouting{
-						ManagedRoutes: []v1alpha1.MangedRoutes{
-							{
-								Name: mocks.ManagedRouteName,
-							},
-						},
-						Plugins: map[string]json.RawMessage{
-							PluginName: encodedConfig,
-						},
-					},
-				},
-			},
-		},
+// setHTTPRouteBackendRefNamespace points every backendRef of httpRoute to namespace, which
+// routes outside of the rollout namespace need to reference the stable and canary services.
+func setHTTPRouteBackendRefNamespace(httpRoute *gatewayv1.HTTPRoute, namespace string) {
+	for i := range httpRoute.Spec.Rules {
+		for j := range httpRoute.Spec.Rules[i].BackendRefs {
+			backendRefNamespace := gatewayv1.Namespace(namespace)
+			httpRoute.Spec.Rules[i].BackendRefs[j].Namespace = &backendRefNamespace
+		}
 	}
 }
 
-// Helper function to create HTTPRoute with various match criteria for testing
-func createHTTPRouteWithMatches(name string, headers []gatewayv1.HTTPHeaderMatch, method *gatewayv1.HTTPMethod, queryParams []gatewayv1.HTTPQueryParamMatch, path *gatewayv1.HTTPPathMatch) *gatewayv1.HTTPRoute {
-	port := gatewayv1.PortNumber(80)
-	stableWeight := int32(100)
-	canaryWeight := int32(0)
-
-	match := gatewayv1.HTTPRouteMatch{}
-	if len(headers) > 0 {
-		match.Headers = headers
-	}
-	if method != nil {
-		match.Method = method
-	}
-	if len(queryParams) > 0 {
-		match.QueryParams = queryParams
-	}
-	if path != nil {
-		match.Path = path
-	}
-
-	return &gatewayv1.HTTPRoute{
+func newServiceReferenceGrant(fromKind, fromNamespace string, serviceName *gatewayv1beta1.ObjectName) *gatewayv1beta1.ReferenceGrant {
+	return &gatewayv1beta1.ReferenceGrant{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      name,
+			Name:      "allow-" + fromNamespace,
 			Namespace: mocks.RolloutNamespace,
 		},
-		Spec: gatewayv1.HTTPRouteSpec{
-			Rules: []gatewayv1.HTTPRouteRule{
+		Spec: gatewayv1beta1.ReferenceGrantSpec{
+			From: []gatewayv1beta1.ReferenceGrantFrom{
 				{
-					BackendRefs: []gatewayv1.HTTPBackendRef{
-						{
-							BackendRef: gatewayv1.BackendRef{
-								BackendObjectReference: gatewayv1.BackendObjectReference{
-									Name: mocks.StableServiceName,
-									Port: &port,
-								},
-								Weight: &stableWeight,
-							},
-						},
-						{
-							BackendRef: gatewayv1.BackendRef{
-								BackendObjectReference: gatewayv1.BackendObjectReference{
-									Name: mocks.CanaryServiceName,
-									Port: &port,
-								},
-								Weight: &canaryWeight,
-							},
-						},
-					},
-					Matches: []gatewayv1.HTTPRouteMatch{match},
+					Group:     gatewayv1.GroupName,
+					Kind:      gatewayv1beta1.Kind(fromKind),
+					Namespace: gatewayv1beta1.Namespace(fromNamespace),
+				},
+			},
+			To: []gatewayv1beta1.ReferenceGrantTo{
+				{
+					Group: "",
+					Kind:  "Service",
+					Name:  serviceName,
 				},
 			},
 		},
 	}
 }
 
-// Helper function to create GRPCRoute with various match criteria for testing
-func createGRPCRouteWithMatches(name string, headers []gatewayv1.GRPCHeaderMatch, method *gatewayv1.GRPCMethodMatch) *gatewayv1.GRPCRoute {
-	port := gatewayv1.PortNumber(80)
-	stableWeight := int32(100)
-	canaryWeight := int32(0)
+func TestDiscoverRoutesByBackendRefs(t *testing.T) {
+	stableOnlyRoute := mocks.CreateHTTPRouteWithLabels("stable-only-route", nil)
+	stableOnlyRoute.Spec.Rules[0].BackendRefs = stableOnlyRoute.Spec.Rules[0].BackendRefs[:1]
+	otherRoute := mocks.CreateHTTPRouteWithLabels("other-route", nil)
+	gatewayAPIClientset := gwFake.NewSimpleClientset(&mocks.HTTPRouteObj, otherRoute, stableOnlyRoute, &mocks.TCPPRouteObj)
+	// Clusters without the experimental channel do not serve TLSRoutes
+	gatewayAPIClientset.PrependReactor("list", "tlsroutes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewNotFound(v1alpha2.Resource("tlsroutes"), "")
+	})
+	// Experimental kinds the plugin may not list are skipped as well
+	gatewayAPIClientset.PrependReactor("list", "udproutes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(v1alpha2.Resource("udproutes"), "", errors.New("no RBAC rule"))
+	})
+	rpcPluginImp := &RpcPlugin{
+		LogCtx:              utils.SetupLog("text"),
+		GatewayAPIClientset: gatewayAPIClientset,
+	}
+	rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+		Namespace:             mocks.RolloutNamespace,
+		HTTPRoute:             mocks.HTTPRouteName,
+		DiscoverByBackendRefs: true,
+	})
 
-	match := gatewayv1.GRPCRouteMatch{}
-	if len(headers) > 0 {
-		match.Headers = headers
+	gatewayAPIConfig, err := rpcPluginImp.getGatewayAPIConfigWithDiscovery(rollout)
+	require.NoError(t, err)
+	// The configured route is not added twice and routes without the canary service are left out
+	assert.Equal(t, []HTTPRoute{
+		{Name: mocks.HTTPRouteName, UseHeaderRoutes: true},
+		{Name: "other-route", Namespace: mocks.RolloutNamespace},
+	}, gatewayAPIConfig.HTTPRoutes)
+	assert.Equal(t, []TCPRoute{{Name: mocks.TCPRouteName, Namespace: mocks.RolloutNamespace}}, gatewayAPIConfig.TCPRoutes)
+	assert.Empty(t, gatewayAPIConfig.TLSRoutes)
+
+	rpcError := rpcPluginImp.SetWeight(rollout, 30, []v1alpha1.WeightDestination{})
+	require.Empty(t, rpcError.Error())
+	updatedHTTP, getErr := gatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), "other-route", metav1.GetOptions{})
+	require.NoError(t, getErr)
+	assert.Equal(t, int32(30), *updatedHTTP.Spec.Rules[0].BackendRefs[1].Weight)
+	updatedTCP, getErr := gatewayAPIClientset.GatewayV1alpha2().TCPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.TCPRouteName, metav1.GetOptions{})
+	require.NoError(t, getErr)
+	assert.Equal(t, int32(30), *updatedTCP.Spec.Rules[0].BackendRefs[1].Weight)
+
+	// HTTPRoutes must not be skipped, since they are what most rollouts manage
+	forbiddenClientset := gwFake.NewSimpleClientset()
+	forbiddenClientset.PrependReactor("list", "httproutes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(gatewayv1.Resource("httproutes"), "", errors.New("no RBAC rule"))
+	})
+	_, err = (&RpcPlugin{LogCtx: utils.SetupLog("text"), GatewayAPIClientset: forbiddenClientset}).getGatewayAPIConfigWithDiscovery(rollout)
+	assert.True(t, apierrors.IsForbidden(err))
+
+	// With the informer cache, routes are listed from it and kinds without an informer are
+	// not listed at all
+	indexer := toolsCache.NewIndexer(toolsCache.MetaNamespaceKeyFunc, toolsCache.Indexers{})
+	require.NoError(t, indexer.Add(otherRoute))
+	rpcPluginImp.cache = &informerCache{httpRouteLister: gatewayListersV1.NewHTTPRouteLister(indexer)}
+	gatewayAPIClientset.ClearActions()
+	gatewayAPIConfig, err = rpcPluginImp.getGatewayAPIConfigWithDiscovery(rollout)
+	require.NoError(t, err)
+	assert.Equal(t, []HTTPRoute{
+		{Name: mocks.HTTPRouteName, UseHeaderRoutes: true},
+		{Name: "other-route", Namespace: mocks.RolloutNamespace},
+	}, gatewayAPIConfig.HTTPRoutes)
+	assert.Empty(t, gatewayAPIConfig.TCPRoutes)
+	assert.Empty(t, gatewayAPIClientset.Actions())
+}
+
+func TestGatewayRefDiscovery(t *testing.T) {
+	newAttachedRoute := func(name string, parentRef gatewayv1.ParentReference, accepted metav1.ConditionStatus) *gatewayv1.HTTPRoute {
+		route := mocks.CreateHTTPRouteWithLabels(name, map[string]string{"app": "test-app"})
+		route.Spec.ParentRefs = []gatewayv1.ParentReference{parentRef}
+		parentStatus := newRouteParentStatus(route.Generation, accepted, metav1.ConditionTrue)
+		parentStatus.ParentRef = parentRef
+		route.Status.Parents = []gatewayv1.RouteParentStatus{parentStatus}
+		return route
 	}
-	if method != nil {
-		match.Method = method
+	httpSection := gatewayv1.SectionName("http")
+	rpcPluginImp := &RpcPlugin{
+		LogCtx: utils.SetupLog("text"),
+		GatewayAPIClientset: gwFake.NewSimpleClientset(
+			newAttachedRoute("accepted-route", gatewayv1.ParentReference{Name: "gateway", SectionName: &httpSection}, metav1.ConditionTrue),
+			newAttachedRoute("rejected-route", gatewayv1.ParentReference{Name: "gateway"}, metav1.ConditionFalse),
+			newAttachedRoute("other-gateway-route", gatewayv1.ParentReference{Name: "other-gateway"}, metav1.ConditionTrue),
+		),
 	}
 
-	return &gatewayv1.GRPCRoute{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      name,
-			Namespace: mocks.RolloutNamespace,
-		},
-		Spec: gatewayv1.GRPCRouteSpec{
-			Rules: []gatewayv1.GRPCRouteRule{
-				{
-					BackendRefs: []gatewayv1.GRPCBackendRef{
-						{
-							BackendRef: gatewayv1.BackendRef{
-								BackendObjectReference: gatewayv1.BackendObjectReference{
-									Name: mocks.StableServiceName,
-									Port: &port,
-								},
-								Weight: &stableWeight,
-							},
-						},
-						{
-							BackendRef: gatewayv1.BackendRef{
-								BackendObjectReference: gatewayv1.BackendObjectReference{
-									Name: mocks.CanaryServiceName,
-									Port: &port,
-								},
-								Weight: &canaryWeight,
-							},
-						},
-					},
-					Matches: []gatewayv1.GRPCRouteMatch{match},
-				},
+	t.Run("WithSelector", func(t *testing.T) {
+		rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+			HTTPRouteSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"app": "test-app"},
 			},
-		},
-	}
-}
+			GatewayRef: &GatewayRef{Name: "gateway"},
+		})
+		gatewayAPIConfig, err := rpcPluginImp.getGatewayAPIConfigWithDiscovery(rollout)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"accepted-route"}, getGatewayAPIRouteNameList(gatewayAPIConfig.HTTPRoutes))
+	})
 
-// Tests to verify that SetHeaderRoute preserves and merges existing match criteria
+	t.Run("WithoutSelector", func(t *testing.T) {
+		rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+			GatewayRef: &GatewayRef{Name: "gateway", Namespace: mocks.RolloutNamespace, SectionName: "http"},
+		})
+		gatewayAPIConfig, err := rpcPluginImp.getGatewayAPIConfigWithDiscovery(rollout)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"accepted-route"}, getGatewayAPIRouteNameList(gatewayAPIConfig.HTTPRoutes))
+	})
 
-// TestSetHTTPHeaderRouteWithExistingHeaders verifies that when adding canary header-based routing
-// to an HTTPRoute that already has header match criteria (e.g., Host header), the plugin preserves
-// the original headers and merges them with the new canary headers. This ensures that existing
-// routing rules based on headers continue to work alongside the new canary routing.
-func TestSetHTTPHeaderRouteWithExistingHeaders(t *testing.T) {
-	// Create HTTPRoute with existing header match
-	existingHeaderType := gatewayv1.HeaderMatchExact
-	existingHeaders := []gatewayv1.HTTPHeaderMatch{
-		{
-			Type:  &existingHeaderType,
-			Name:  "Host",
-			Value: "example.com",
-		},
-	}
-	httpRoute := createHTTPRouteWithMatches(mocks.HTTPRouteName, existingHeaders, nil, nil, nil)
+	t.Run("OtherSectionName", func(t *testing.T) {
+		rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+			GatewayRef: &GatewayRef{Name: "gateway", SectionName: "https"},
+		})
+		gatewayAPIConfig, err := rpcPluginImp.getGatewayAPIConfigWithDiscovery(rollout)
+		require.NoError(t, err)
+		assert.Empty(t, gatewayAPIConfig.HTTPRoutes)
+	})
+}
 
-	// Setup plugin
-	rpcPluginImp := &RpcPlugin{
-		LogCtx:              utils.SetupLog("text"),
-		GatewayAPIClientset: gwFake.NewSimpleClientset(httpRoute),
+func TestRootServiceRefDiscovery(t *testing.T) {
+	coreGroup := gatewayv1.Group("core")
+	serviceKind := gatewayv1.Kind("Service")
+	rootServicePort := gatewayv1.PortNumber(80)
+	rootServiceParentRef := gatewayv1.ParentReference{Group: &coreGroup, Kind: &serviceKind, Name: "argo-rollouts-service", Port: &rootServicePort}
+	// newMeshRoute returns a GAMMA route like the one of examples/linkerd, whose mesh parent
+	// status does not report the observed generation.
+	newMeshRoute := func() *gatewayv1.HTTPRoute {
+		route := mocks.CreateHTTPRouteWithLabels("mesh-route", nil)
+		route.Generation = 2
+		route.Spec.ParentRefs = []gatewayv1.ParentReference{rootServiceParentRef}
+		for i := range route.Spec.Rules[0].BackendRefs {
+			route.Spec.Rules[0].BackendRefs[i].Group = &coreGroup
+			route.Spec.Rules[0].BackendRefs[i].Kind = &serviceKind
+		}
+		parentStatus := newRouteParentStatus(0, metav1.ConditionTrue, metav1.ConditionTrue)
+		parentStatus.ParentRef = rootServiceParentRef
+		route.Status.Parents = []gatewayv1.RouteParentStatus{parentStatus}
+		return route
 	}
+	gatewayRoute := mocks.CreateHTTPRouteWithLabels("gateway-route", nil)
+	gatewayRoute.Spec.ParentRefs = []gatewayv1.ParentReference{{Name: "gateway"}}
+	gatewayRoute.Status.Parents = []gatewayv1.RouteParentStatus{newRouteParentStatus(gatewayRoute.Generation, metav1.ConditionTrue, metav1.ConditionTrue)}
+	headerMatch := v1alpha1.StringMatch{Exact: "true"}
 
-	// Setup canary header
-	canaryHeaderName := "X-Canary"
-	canaryHeaderValue := "true"
-	headerMatch := v1alpha1.StringMatch{
-		Exact: canaryHeaderValue,
-	}
+	t.Run("Discovery", func(t *testing.T) {
+		rpcPluginImp := &RpcPlugin{
+			LogCtx:              utils.SetupLog("text"),
+			GatewayAPIClientset: gwFake.NewSimpleClientset(newMeshRoute(), gatewayRoute.DeepCopy()),
+		}
+		rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+			RootServiceRef: &ServiceRef{Name: "argo-rollouts-service"},
+		})
+		gatewayAPIConfig, err := rpcPluginImp.getGatewayAPIConfigWithDiscovery(rollout)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"mesh-route"}, getGatewayAPIRouteNameList(gatewayAPIConfig.HTTPRoutes))
+
+		port := int32(8080)
+		rollout = newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+			RootServiceRef: &ServiceRef{Name: "argo-rollouts-service", Port: &port},
+		})
+		gatewayAPIConfig, err = rpcPluginImp.getGatewayAPIConfigWithDiscovery(rollout)
+		require.NoError(t, err)
+		assert.Empty(t, gatewayAPIConfig.HTTPRoutes)
+	})
+
+	t.Run("WeightAndHeaderRoute", func(t *testing.T) {
+		rpcPluginImp := &RpcPlugin{
+			LogCtx:              utils.SetupLog("text"),
+			GatewayAPIClientset: gwFake.NewSimpleClientset(newMeshRoute()),
+		}
+		rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+			HTTPRoutes: []HTTPRoute{{Name: "mesh-route", UseHeaderRoutes: true}},
+		})
+
+		rpcError := rpcPluginImp.SetWeight(rollout, 30, []v1alpha1.WeightDestination{})
+		require.Empty(t, rpcError.Error())
+		verified, rpcError := rpcPluginImp.VerifyWeight(rollout, 30, []v1alpha1.WeightDestination{})
+		require.Empty(t, rpcError.Error())
+		assert.Equal(t, pluginTypes.Verified, verified)
+		rpcError = rpcPluginImp.SetHeaderRoute(rollout, &v1alpha1.SetHeaderRoute{
+			Name:  mocks.ManagedRouteName,
+			Match: []v1alpha1.HeaderRoutingMatch{{HeaderName: "X-Canary", HeaderValue: &headerMatch}},
+		})
+		require.Empty(t, rpcError.Error())
+
+		updatedHTTP, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), "mesh-route", metav1.GetOptions{})
+		require.NoError(t, getErr)
+		require.Len(t, updatedHTTP.Spec.Rules, 2)
+		assert.Equal(t, int32(30), *updatedHTTP.Spec.Rules[0].BackendRefs[1].Weight)
+		// The header rule shares the Service parent of the route and keeps its group spelling
+		assert.Equal(t, []gatewayv1.ParentReference{rootServiceParentRef}, updatedHTTP.Spec.ParentRefs)
+		headerBackendRef := updatedHTTP.Spec.Rules[1].BackendRefs[0]
+		assert.Equal(t, coreGroup, *headerBackendRef.Group)
+		assert.Equal(t, serviceKind, *headerBackendRef.Kind)
+		assert.Equal(t, gatewayv1.ObjectName(mocks.CanaryServiceName), headerBackendRef.Name)
+	})
+
+	t.Run("GatewayParentGeneration", func(t *testing.T) {
+		// Gateway parents must still report the observed generation
+		parentStatus := newRouteParentStatus(0, metav1.ConditionTrue, metav1.ConditionTrue)
+		isAccepted, _ := isRouteAccepted(2, gatewayv1.RouteStatus{Parents: []gatewayv1.RouteParentStatus{parentStatus}})
+		assert.False(t, isAccepted)
+	})
+}
+
+func TestSelectorDiscoveryUseHeaderRoutes(t *testing.T) {
+	headerMatch := v1alpha1.StringMatch{Exact: "true"}
 	headerRouting := v1alpha1.SetHeaderRoute{
 		Name: mocks.ManagedRouteName,
 		Match: []v1alpha1.HeaderRoutingMatch{
 			{
-				HeaderName:  canaryHeaderName,
+				HeaderName:  "X-Canary",
 				HeaderValue: &headerMatch,
 			},
 		},
 	}
-	rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
-		Namespace: mocks.RolloutNamespace,
-		HTTPRoute: mocks.HTTPRouteName,
-	})
-
-	// Call SetHeaderRoute
-	err := rpcPluginImp.SetHeaderRoute(rollout, &headerRouting)
-	assert.Empty(t, err.Error())
-	updatedHTTP, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
-	require.NoError(t, getErr)
-	assert.Equal(t, mocks.ManagedRouteName, string(*updatedHTTP.Spec.Rules[1].Name))
-
-	// Verify that managed route (index 1) includes BOTH original header AND canary header
-	managedRouteMatches := updatedHTTP.Spec.Rules[1].Matches
-	assert.NotEmpty(t, managedRouteMatches, "Managed route should have matches")
-
-	// Verify headers are merged correctly
-	if len(managedRouteMatches) > 0 && len(managedRouteMatches[0].Headers) > 0 {
-		headers := managedRouteMatches[0].Headers
-		assert.Len(t, headers, 2, "Should have both original and canary headers")
 
-		// Check if both headers are present
-		hasOriginalHeader := false
-		hasCanaryHeader := false
-		for _, h := range headers {
-			if string(h.Name) == "Host" && h.Value == "example.com" {
-				hasOriginalHeader = true
+	for _, useHeaderRoutes := range []bool{false, true} {
+		t.Run(fmt.Sprintf("UseHeaderRoutes=%t", useHeaderRoutes), func(t *testing.T) {
+			rpcPluginImp := &RpcPlugin{
+				LogCtx:              utils.SetupLog("text"),
+				GatewayAPIClientset: gwFake.NewSimpleClientset(mocks.CreateHTTPRouteWithLabels(mocks.HTTPRouteName, map[string]string{"app": "test-app"})),
 			}
-			if string(h.Name) == canaryHeaderName {
-				hasCanaryHeader = true
+			rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+				Namespace: mocks.RolloutNamespace,
+				HTTPRouteSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"app": "test-app"},
+				},
+				HTTPRouteSelectorUseHeaderRoutes: useHeaderRoutes,
+			})
+
+			err := rpcPluginImp.SetHeaderRoute(rollout, &headerRouting)
+			require.Empty(t, err.Error())
+			updatedHTTP, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+			require.NoError(t, getErr)
+			if !useHeaderRoutes {
+				assert.Len(t, updatedHTTP.Spec.Rules, 1)
+				return
 			}
-		}
-		assert.True(t, hasOriginalHeader, "Original Host header should be preserved")
-		assert.True(t, hasCanaryHeader, "Canary header should be added")
-	} else {
-		t.Fatal("Managed route should have at least one match with headers")
+			require.Len(t, updatedHTTP.Spec.Rules, 2)
+			assert.Equal(t, mocks.ManagedRouteName, string(*updatedHTTP.Spec.Rules[1].Name))
+
+			err = rpcPluginImp.RemoveManagedRoutes(rollout)
+			require.Empty(t, err.Error())
+			updatedHTTP, getErr = rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+			require.NoError(t, getErr)
+			assert.Len(t, updatedHTTP.Spec.Rules, 1)
+		})
 	}
 }
 
-// TestSetHTTPHeaderRouteWithExistingMethod verifies that when adding canary header-based routing
-// to an HTTPRoute that already has HTTP method match criteria (e.g., POST), the plugin preserves
-// the original method specification. This ensures method-based routing continues to function
-// correctly when canary headers are added for progressive delivery.
-func TestSetHTTPHeaderRouteWithExistingMethod(t *testing.T) {
-	// Create HTTPRoute with existing method match
-	postMethod := gatewayv1.HTTPMethodPost
-	httpRoute := createHTTPRouteWithMatches(mocks.HTTPRouteName, nil, &postMethod, nil, nil)
-
-	// Setup plugin
-	rpcPluginImp := &RpcPlugin{
-		LogCtx:              utils.SetupLog("text"),
-		GatewayAPIClientset: gwFake.NewSimpleClientset(httpRoute),
-	}
-
-	// Setup canary header
-	canaryHeaderName := "X-Canary"
-	canaryHeaderValue := "true"
-	headerMatch := v1alpha1.StringMatch{
-		Exact: canaryHeaderValue,
+func TestHTTPRouteRuleTargeting(t *testing.T) {
+	newRoute := func() *gatewayv1.HTTPRoute {
+		httpRoute := mocks.CreateHTTPRouteWithLabels(mocks.HTTPRouteName, nil)
+		mainRule := httpRoute.Spec.Rules[0]
+		mainName := gatewayv1.SectionName("main")
+		mainRule.Name = &mainName
+		adminRule := *mainRule.DeepCopy()
+		adminName := gatewayv1.SectionName("admin")
+		adminRule.Name = &adminName
+		unnamedRule := *mainRule.DeepCopy()
+		unnamedRule.Name = nil
+		httpRoute.Spec.Rules = []gatewayv1.HTTPRouteRule{mainRule, adminRule, unnamedRule}
+		return httpRoute
 	}
+	headerMatch := v1alpha1.StringMatch{Exact: "true"}
 	headerRouting := v1alpha1.SetHeaderRoute{
 		Name: mocks.ManagedRouteName,
 		Match: []v1alpha1.HeaderRoutingMatch{
 			{
-				HeaderName:  canaryHeaderName,
+				HeaderName:  "X-Canary",
 				HeaderValue: &headerMatch,
 			},
 		},
 	}
-	rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
-		Namespace: mocks.RolloutNamespace,
-		HTTPRoute: mocks.HTTPRouteName,
+
+	t.Run("NamesAndIndexes", func(t *testing.T) {
+		rpcPluginImp := &RpcPlugin{
+			LogCtx:              utils.SetupLog("text"),
+			GatewayAPIClientset: gwFake.NewSimpleClientset(newRoute()),
+		}
+		rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+			Namespace: mocks.RolloutNamespace,
+			HTTPRoutes: []HTTPRoute{
+				{Name: mocks.HTTPRouteName, UseHeaderRoutes: true, RuleNames: []string{"main"}, RuleIndexes: []int{2}},
+			},
+		})
+
+		rpcError := rpcPluginImp.SetWeight(rollout, 30, []v1alpha1.WeightDestination{})
+		require.Empty(t, rpcError.Error())
+		rpcError = rpcPluginImp.SetHeaderRoute(rollout, &headerRouting)
+		require.Empty(t, rpcError.Error())
+
+		updatedHTTP, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+		require.NoError(t, getErr)
+		require.Len(t, updatedHTTP.Spec.Rules, 5)
+		for _, i := range []int{0, 2} {
+			assert.Equal(t, int32(70), *updatedHTTP.Spec.Rules[i].BackendRefs[0].Weight)
+			assert.Equal(t, int32(30), *updatedHTTP.Spec.Rules[i].BackendRefs[1].Weight)
+		}
+		// The admin rule stays pinned to the stable service
+		assert.Equal(t, int32(100), *updatedHTTP.Spec.Rules[1].BackendRefs[0].Weight)
+		assert.Equal(t, int32(0), *updatedHTTP.Spec.Rules[1].BackendRefs[1].Weight)
+		// Header rules are only generated for the targeted rules
+		assert.Equal(t, mocks.ManagedRouteName, string(*updatedHTTP.Spec.Rules[3].Name))
+		assert.Equal(t, mocks.ManagedRouteName+"-1", string(*updatedHTTP.Spec.Rules[4].Name))
 	})
 
-	// Call SetHeaderRoute
-	err := rpcPluginImp.SetHeaderRoute(rollout, &headerRouting)
-	assert.Empty(t, err.Error())
-	updatedHTTP, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
-	require.NoError(t, getErr)
-	assert.Equal(t, mocks.ManagedRouteName, string(*updatedHTTP.Spec.Rules[1].Name))
+	t.Run("TargetingIsPerRoute", func(t *testing.T) {
+		otherRoute := newRoute()
+		otherRoute.Name = "other-route"
+		rpcPluginImp := &RpcPlugin{
+			LogCtx:              utils.SetupLog("text"),
+			GatewayAPIClientset: gwFake.NewSimpleClientset(newRoute(), otherRoute),
+		}
+		rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+			Namespace: mocks.RolloutNamespace,
+			HTTPRoutes: []HTTPRoute{
+				{Name: mocks.HTTPRouteName, RuleNames: []string{"main"}},
+				{Name: "other-route"},
+			},
+		})
 
-	// Verify that managed route (index 1) includes BOTH method AND canary header
-	managedRouteMatches := updatedHTTP.Spec.Rules[1].Matches
-	assert.NotEmpty(t, managedRouteMatches, "Managed route should have matches")
+		rpcError := rpcPluginImp.SetWeight(rollout, 30, []v1alpha1.WeightDestination{})
+		require.Empty(t, rpcError.Error())
+		// The rule names of the first route do not restrict the rules of the second one
+		updatedHTTP, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), "other-route", metav1.GetOptions{})
+		require.NoError(t, getErr)
+		for i := range updatedHTTP.Spec.Rules {
+			assert.Equal(t, int32(30), *updatedHTTP.Spec.Rules[i].BackendRefs[1].Weight)
+		}
+	})
 
-	// Verify method and headers are both preserved
-	if len(managedRouteMatches) > 0 {
-		match := managedRouteMatches[0]
-		assert.NotNil(t, match.Method, "Method should be preserved")
-		if match.Method != nil {
-			assert.Equal(t, postMethod, *match.Method, "POST method should be preserved")
+	t.Run("MissingRule", func(t *testing.T) {
+		rpcPluginImp := &RpcPlugin{
+			LogCtx:              utils.SetupLog("text"),
+			GatewayAPIClientset: gwFake.NewSimpleClientset(newRoute()),
 		}
-		assert.NotEmpty(t, match.Headers, "Canary headers should be present")
-	} else {
-		t.Fatal("Managed route should have at least one match")
+		rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+			Namespace:  mocks.RolloutNamespace,
+			HTTPRoutes: []HTTPRoute{{Name: mocks.HTTPRouteName, RuleNames: []string{"healthz"}}},
+		})
+		rpcError := rpcPluginImp.SetWeight(rollout, 30, []v1alpha1.WeightDestination{})
+		assert.Equal(t, fmt.Sprintf(RouteRuleNameWasNotFoundError, "healthz", "HTTPRoute", mocks.HTTPRouteName), rpcError.Error())
+
+		rollout = newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+			Namespace:  mocks.RolloutNamespace,
+			HTTPRoutes: []HTTPRoute{{Name: mocks.HTTPRouteName, RuleIndexes: []int{3}}},
+		})
+		rpcError = rpcPluginImp.SetWeight(rollout, 30, []v1alpha1.WeightDestination{})
+		assert.Equal(t, fmt.Sprintf(RouteRuleIndexWasNotFoundError, 3, "HTTPRoute", mocks.HTTPRouteName), rpcError.Error())
+	})
+}
+
+func TestBackendRefMatching(t *testing.T) {
+	// newRoute returns an HTTPRoute whose single rule also references a ServiceImport and a
+	// Service in another namespace, both named like the canary service.
+	newRoute := func() *gatewayv1.HTTPRoute {
+		httpRoute := mocks.CreateHTTPRouteWithLabels(mocks.HTTPRouteName, nil)
+		serviceImportGroup := gatewayv1.Group("multicluster.x-k8s.io")
+		serviceImportKind := gatewayv1.Kind("ServiceImport")
+		otherNamespace := gatewayv1.Namespace("other")
+		port := gatewayv1.PortNumber(8080)
+		weight := int32(5)
+		httpRoute.Spec.Rules[0].BackendRefs = append(httpRoute.Spec.Rules[0].BackendRefs,
+			gatewayv1.HTTPBackendRef{
+				BackendRef: gatewayv1.BackendRef{
+					BackendObjectReference: gatewayv1.BackendObjectReference{
+						Group: &serviceImportGroup,
+						Kind:  &serviceImportKind,
+						Name:  mocks.CanaryServiceName,
+						Port:  &port,
+					},
+					Weight: &weight,
+				},
+			},
+			gatewayv1.HTTPBackendRef{
+				BackendRef: gatewayv1.BackendRef{
+					BackendObjectReference: gatewayv1.BackendObjectReference{
+						Name:      mocks.CanaryServiceName,
+						Namespace: &otherNamespace,
+						Port:      &port,
+					},
+					Weight: &weight,
+				},
+			},
+		)
+		return httpRoute
 	}
+
+	t.Run("Service", func(t *testing.T) {
+		rpcPluginImp := &RpcPlugin{
+			LogCtx:              utils.SetupLog("text"),
+			GatewayAPIClientset: gwFake.NewSimpleClientset(newRoute()),
+		}
+		rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+			HTTPRoute: mocks.HTTPRouteName,
+		})
+
+		rpcError := rpcPluginImp.SetWeight(rollout, 30, []v1alpha1.WeightDestination{})
+		require.Empty(t, rpcError.Error())
+		updatedHTTP, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+		require.NoError(t, getErr)
+		backendRefs := updatedHTTP.Spec.Rules[0].BackendRefs
+		assert.Equal(t, int32(30), *backendRefs[1].Weight)
+		assert.Equal(t, int32(5), *backendRefs[2].Weight)
+		assert.Equal(t, int32(5), *backendRefs[3].Weight)
+	})
+
+	t.Run("ServiceImport", func(t *testing.T) {
+		httpRoute := newRoute()
+		// Only the ServiceImport backendRef on port 8080 is the canary
+		httpRoute.Spec.Rules[0].BackendRefs[1].Group = httpRoute.Spec.Rules[0].BackendRefs[2].Group
+		httpRoute.Spec.Rules[0].BackendRefs[1].Kind = httpRoute.Spec.Rules[0].BackendRefs[2].Kind
+		rpcPluginImp := &RpcPlugin{
+			LogCtx:              utils.SetupLog("text"),
+			GatewayAPIClientset: gwFake.NewSimpleClientset(httpRoute),
+		}
+		canaryPort := int32(8080)
+		rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+			HTTPRoute:        mocks.HTTPRouteName,
+			CanaryBackendRef: &BackendRefMatch{Kind: "ServiceImport", Port: &canaryPort},
+		})
+
+		rpcError := rpcPluginImp.SetWeight(rollout, 30, []v1alpha1.WeightDestination{})
+		require.Empty(t, rpcError.Error())
+		updatedHTTP, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+		require.NoError(t, getErr)
+		backendRefs := updatedHTTP.Spec.Rules[0].BackendRefs
+		assert.Equal(t, int32(70), *backendRefs[0].Weight)
+		assert.Equal(t, int32(0), *backendRefs[1].Weight)
+		assert.Equal(t, int32(30), *backendRefs[2].Weight)
+		assert.Equal(t, int32(5), *backendRefs[3].Weight)
+	})
+
+	t.Run("ServiceImportHeaderRoute", func(t *testing.T) {
+		httpRoute := newRoute()
+		httpRoute.Spec.Rules[0].BackendRefs = httpRoute.Spec.Rules[0].BackendRefs[:3]
+		httpRoute.Spec.Rules[0].BackendRefs[0].Group = httpRoute.Spec.Rules[0].BackendRefs[2].Group
+		httpRoute.Spec.Rules[0].BackendRefs[0].Kind = httpRoute.Spec.Rules[0].BackendRefs[2].Kind
+		rpcPluginImp := &RpcPlugin{
+			LogCtx:              utils.SetupLog("text"),
+			GatewayAPIClientset: gwFake.NewSimpleClientset(httpRoute),
+		}
+		rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+			HTTPRoutes:       []HTTPRoute{{Name: mocks.HTTPRouteName, UseHeaderRoutes: true}},
+			StableBackendRef: &BackendRefMatch{Kind: "ServiceImport"},
+			CanaryBackendRef: &BackendRefMatch{Kind: "ServiceImport"},
+		})
+		headerMatch := v1alpha1.StringMatch{Exact: "true"}
+
+		rpcError := rpcPluginImp.SetHeaderRoute(rollout, &v1alpha1.SetHeaderRoute{
+			Name:  mocks.ManagedRouteName,
+			Match: []v1alpha1.HeaderRoutingMatch{{HeaderName: "X-Canary", HeaderValue: &headerMatch}},
+		})
+		require.Empty(t, rpcError.Error())
+		updatedHTTP, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+		require.NoError(t, getErr)
+		require.Len(t, updatedHTTP.Spec.Rules, 2)
+		headerBackendRef := updatedHTTP.Spec.Rules[1].BackendRefs[0]
+		assert.Equal(t, gatewayv1.Group("multicluster.x-k8s.io"), *headerBackendRef.Group)
+		assert.Equal(t, gatewayv1.Kind("ServiceImport"), *headerBackendRef.Kind)
+		assert.Equal(t, gatewayv1.PortNumber(8080), *headerBackendRef.Port)
+	})
+
+	t.Run("InvalidKind", func(t *testing.T) {
+		rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+			HTTPRoute:        mocks.HTTPRouteName,
+			StableBackendRef: &BackendRefMatch{Kind: "Deployment"},
+		})
+		_, err := getGatewayAPITrafficRoutingConfig(rollout)
+		assert.Error(t, err)
+	})
 }
 
-// TestSetHTTPHeaderRouteWithExistingQueryParams verifies that when adding canary header-based
-// routing to an HTTPRoute that already has query parameter match criteria (e.g., version=v2),
-// the plugin preserves the original query parameter specifications. This ensures query
-// parameter-based routing continues to work correctly alongside canary header routing.
-func TestSetHTTPHeaderRouteWithExistingQueryParams(t *testing.T) {
-	// Create HTTPRoute with existing query param matches
-	queryParamType := gatewayv1.QueryParamMatchExact
-	queryParams := []gatewayv1.HTTPQueryParamMatch{
-		{
-			Type:  &queryParamType,
-			Name:  "version",
-			Value: "v2",
+func TestInferencePoolCanary(t *testing.T) {
+	httpRoute := mocks.CreateHTTPRouteWithLabels(mocks.HTTPRouteName, nil)
+	inferencePoolGroup := gatewayv1.Group("inference.networking.k8s.io")
+	inferencePoolKind := gatewayv1.Kind("InferencePool")
+	for i, name := range []gatewayv1.ObjectName{"vllm-stable", "vllm-canary"} {
+		backendRef := &httpRoute.Spec.Rules[0].BackendRefs[i]
+		backendRef.Group = &inferencePoolGroup
+		backendRef.Kind = &inferencePoolKind
+		backendRef.Name = name
+		backendRef.Port = nil
+	}
+	experimentService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "experiment-service",
+			Namespace: mocks.RolloutNamespace,
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{{Name: "http", Port: 8000}},
 		},
 	}
-	httpRoute := createHTTPRouteWithMatches(mocks.HTTPRouteName, nil, nil, queryParams, nil)
-
-	// Setup plugin
 	rpcPluginImp := &RpcPlugin{
 		LogCtx:              utils.SetupLog("text"),
+		Clientset:           kubeFake.NewSimpleClientset(experimentService),
 		GatewayAPIClientset: gwFake.NewSimpleClientset(httpRoute),
 	}
-
-	// Setup canary header
-	canaryHeaderName := "X-Canary"
-	canaryHeaderValue := "true"
-	headerMatch := v1alpha1.StringMatch{
-		Exact: canaryHeaderValue,
-	}
-	headerRouting := v1alpha1.SetHeaderRoute{
-		Name: mocks.ManagedRouteName,
-		Match: []v1alpha1.HeaderRoutingMatch{
-			{
-				HeaderName:  canaryHeaderName,
-				HeaderValue: &headerMatch,
-			},
-		},
-	}
 	rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
-		Namespace: mocks.RolloutNamespace,
-		HTTPRoute: mocks.HTTPRouteName,
+		HTTPRoutes:       []HTTPRoute{{Name: mocks.HTTPRouteName, UseHeaderRoutes: true}},
+		StableBackendRef: &BackendRefMatch{Name: "vllm-stable", Kind: "InferencePool"},
+		CanaryBackendRef: &BackendRefMatch{Name: "vllm-canary", Kind: "InferencePool"},
+		ExperimentPort:   &ExperimentPort{Name: "http"},
 	})
+	rollout.Status.Canary.CurrentExperiment = "experiment"
+	headerMatch := v1alpha1.StringMatch{Exact: "true"}
+
+	rpcError := rpcPluginImp.SetWeight(rollout, 20, []v1alpha1.WeightDestination{{ServiceName: experimentService.Name, Weight: 10}})
+	require.Empty(t, rpcError.Error())
+	rpcError = rpcPluginImp.SetHeaderRoute(rollout, &v1alpha1.SetHeaderRoute{
+		Name:  mocks.ManagedRouteName,
+		Match: []v1alpha1.HeaderRoutingMatch{{HeaderName: "X-Canary", HeaderValue: &headerMatch}},
+	})
+	require.Empty(t, rpcError.Error())
 
-	// Call SetHeaderRoute
-	err := rpcPluginImp.SetHeaderRoute(rollout, &headerRouting)
-	assert.Empty(t, err.Error())
 	updatedHTTP, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
 	require.NoError(t, getErr)
-	assert.Equal(t, mocks.ManagedRouteName, string(*updatedHTTP.Spec.Rules[1].Name))
+	require.Len(t, updatedHTTP.Spec.Rules, 2)
+	backendRefs := updatedHTTP.Spec.Rules[0].BackendRefs
+	require.Len(t, backendRefs, 3)
+	assert.Equal(t, int32(70), *backendRefs[0].Weight)
+	assert.Equal(t, int32(20), *backendRefs[1].Weight)
+	// Experiments create Services, so the experiment backend stays a Service
+	assert.Equal(t, gatewayv1.ObjectName(experimentService.Name), backendRefs[2].Name)
+	assert.Nil(t, backendRefs[2].Kind)
+	assert.Equal(t, gatewayv1.PortNumber(8000), *backendRefs[2].Port)
+	headerBackendRef := updatedHTTP.Spec.Rules[1].BackendRefs[0]
+	assert.Equal(t, gatewayv1.ObjectName("vllm-canary"), headerBackendRef.Name)
+	assert.Equal(t, inferencePoolGroup, *headerBackendRef.Group)
+	assert.Equal(t, inferencePoolKind, *headerBackendRef.Kind)
+	assert.Nil(t, headerBackendRef.Port)
+}
 
-	// Verify that managed route (index 1) includes BOTH query params AND canary header
-	managedRouteMatches := updatedHTTP.Spec.Rules[1].Matches
-	assert.NotEmpty(t, managedRouteMatches, "Managed route should have matches")
+// TestInferencePoolCanaryWithoutExperimentPort verifies that experiments next to a canary
+// InferencePool, whose backendRefs have no port, use the only port of the experiment Service
+// and fail with an error when the Service has several ports.
+func TestInferencePoolCanaryWithoutExperimentPort(t *testing.T) {
+	httpRoute := mocks.CreateHTTPRouteWithLabels(mocks.HTTPRouteName, nil)
+	inferencePoolGroup := gatewayv1.Group("inference.networking.k8s.io")
+	inferencePoolKind := gatewayv1.Kind("InferencePool")
+	for i, name := range []gatewayv1.ObjectName{"vllm-stable", "vllm-canary"} {
+		backendRef := &httpRoute.Spec.Rules[0].BackendRefs[i]
+		backendRef.Group = &inferencePoolGroup
+		backendRef.Kind = &inferencePoolKind
+		backendRef.Name = name
+		backendRef.Port = nil
+	}
+	singlePortService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "single-port-service", Namespace: mocks.RolloutNamespace},
+		Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "http", Port: 8000}}},
+	}
+	multiPortService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "multi-port-service", Namespace: mocks.RolloutNamespace},
+		Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "http", Port: 8000}, {Name: "metrics", Port: 9090}}},
+	}
+	rpcPluginImp := &RpcPlugin{
+		LogCtx:              utils.SetupLog("text"),
+		Clientset:           kubeFake.NewSimpleClientset(singlePortService, multiPortService),
+		GatewayAPIClientset: gwFake.NewSimpleClientset(httpRoute),
+	}
+	rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+		HTTPRoute:        mocks.HTTPRouteName,
+		StableBackendRef: &BackendRefMatch{Name: "vllm-stable", Kind: "InferencePool"},
+		CanaryBackendRef: &BackendRefMatch{Name: "vllm-canary", Kind: "InferencePool"},
+	})
+	rollout.Status.Canary.CurrentExperiment = "experiment"
 
-	// Verify query params and headers are both preserved
-	if len(managedRouteMatches) > 0 {
-		match := managedRouteMatches[0]
-		assert.NotEmpty(t, match.QueryParams, "Query params should be preserved")
-		if len(match.QueryParams) > 0 {
-			assert.Equal(t, "version", string(match.QueryParams[0].Name))
-			assert.Equal(t, "v2", match.QueryParams[0].Value)
+	rpcError := rpcPluginImp.SetWeight(rollout, 20, []v1alpha1.WeightDestination{{ServiceName: multiPortService.Name, Weight: 10}})
+	assert.Equal(t, fmt.Sprintf(CanaryBackendRefPortWasNotFoundError, multiPortService.Name), rpcError.Error())
+
+	rpcError = rpcPluginImp.SetWeight(rollout, 20, []v1alpha1.WeightDestination{{ServiceName: singlePortService.Name, Weight: 10}})
+	require.Empty(t, rpcError.Error())
+	updatedHTTP, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+	require.NoError(t, getErr)
+	backendRefs := updatedHTTP.Spec.Rules[0].BackendRefs
+	require.Len(t, backendRefs, 3)
+	assert.Equal(t, gatewayv1.ObjectName(singlePortService.Name), backendRefs[2].Name)
+	assert.Equal(t, gatewayv1.PortNumber(8000), *backendRefs[2].Port)
+}
+
+func TestNamespaceDefaulting(t *testing.T) {
+	t.Run("DefaultsToRolloutNamespaceWhenNotSpecified", func(t *testing.T) {
+		// Create a rollout with namespace "my-namespace" but config without namespace
+		config := &GatewayAPITrafficRouting{
+			// Namespace intentionally not set (empty string)
+			HTTPRoute: mocks.HTTPRouteName,
 		}
-		assert.NotEmpty(t, match.Headers, "Canary headers should be present")
-	} else {
-		t.Fatal("Managed route should have at least one match")
+		rolloutNamespace := "my-namespace"
+		rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, config, rolloutNamespace)
+
+		// Parse the config - this is where namespace defaulting should happen
+		parsedConfig, err := getGatewayAPITrafficRoutingConfig(rollout)
+
+		require.NoError(t, err)
+		// Before the fix, this would be empty string. After the fix, it should default to rollout's namespace.
+		assert.Equal(t, rolloutNamespace, parsedConfig.Namespace, "Namespace should default to rollout's namespace when not specified")
+	})
+
+	t.Run("UsesExplicitNamespaceWhenSpecified", func(t *testing.T) {
+		// Create a rollout with explicit namespace in config
+		explicitNamespace := "explicit-namespace"
+		config := &GatewayAPITrafficRouting{
+			Namespace: explicitNamespace,
+			HTTPRoute: mocks.HTTPRouteName,
+		}
+		rolloutNamespace := "rollout-namespace"
+		rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, config, rolloutNamespace)
+
+		// Parse the config
+		parsedConfig, err := getGatewayAPITrafficRoutingConfig(rollout)
+
+		require.NoError(t, err)
+		// Should use the explicitly specified namespace, not the rollout's namespace
+		assert.Equal(t, explicitNamespace, parsedConfig.Namespace, "Should use explicit namespace when specified")
+	})
+
+	t.Run("DefaultsToRolloutNamespaceWithEmptyString", func(t *testing.T) {
+		// Explicitly set namespace to empty string
+		config := &GatewayAPITrafficRouting{
+			Namespace: "", // Explicitly empty
+			GRPCRoute: mocks.GRPCRouteName,
+		}
+		rolloutNamespace := "another-namespace"
+		rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, config, rolloutNamespace)
+
+		parsedConfig, err := getGatewayAPITrafficRoutingConfig(rollout)
+
+		require.NoError(t, err)
+		assert.Equal(t, rolloutNamespace, parsedConfig.Namespace, "Empty namespace should default to rollout's namespace")
+	})
+}
+
+func newRollout(stableSvc, canarySvc string, config *GatewayAPITrafficRouting, namespace ...string) *v1alpha1.Rollout {
+	ns := mocks.RolloutNamespace
+	if len(namespace) > 0 {
+		ns = namespace[0]
+	}
+	encodedConfig, err := json.Marshal(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return &v1alpha1.Rollout{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "rollout",
+			Namespace: ns,
+		},
+		Spec: v1alpha1.RolloutSpec{
+			Strategy: v1alpha1.RolloutStrategy{
+				Canary: &v1alpha1.CanaryStrategy{
+					StableService: stableSvc,
+					CanaryService: canarySvc,
+					TrafficRouting: &v1alpha1.RolloutTrafficRouting{
+						ManagedRoutes: []v1alpha1.MangedRoutes{
+							{
+								Name: mocks.ManagedRouteName,
+							},
+						},
+						Plugins: map[string]json.RawMessage{
+							PluginName: encodedConfig,
+						},
+					},
+				},
+			},
+		},
 	}
 }
 
-// TestSetHTTPHeaderRouteWithMultipleExistingHeaders verifies that when adding canary header-based
-// routing to an HTTPRoute that already has multiple header match criteria (e.g., Host and User-Agent),
-// the plugin preserves all original headers and merges them with the new canary headers. This ensures
-// complex header-based routing rules continue to work when implementing progressive delivery.
-func TestSetHTTPHeaderRouteWithMultipleExistingHeaders(t *testing.T) {
-	// Create HTTPRoute with multiple existing header matches
-	exactType := gatewayv1.HeaderMatchExact
-	regexType := gatewayv1.HeaderMatchRegularExpression
+// Helper function to create HTTPRoute with various match criteria for testing
+func createHTTPRouteWithMatches(name string, headers []gatewayv1.HTTPHeaderMatch, method *gatewayv1.HTTPMethod, queryParams []gatewayv1.HTTPQueryParamMatch, path *gatewayv1.HTTPPathMatch) *gatewayv1.HTTPRoute {
+	port := gatewayv1.PortNumber(80)
+	stableWeight := int32(100)
+	canaryWeight := int32(0)
+
+	match := gatewayv1.HTTPRouteMatch{}
+	if len(headers) > 0 {
+		match.Headers = headers
+	}
+	if method != nil {
+		match.Method = method
+	}
+	if len(queryParams) > 0 {
+		match.QueryParams = queryParams
+	}
+	if path != nil {
+		match.Path = path
+	}
+
+	return &gatewayv1.HTTPRoute{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: mocks.RolloutNamespace,
+		},
+		Spec: gatewayv1.HTTPRouteSpec{
+			Rules: []gatewayv1.HTTPRouteRule{
+				{
+					BackendRefs: []gatewayv1.HTTPBackendRef{
+						{
+							BackendRef: gatewayv1.BackendRef{
+								BackendObjectReference: gatewayv1.BackendObjectReference{
+									Name: mocks.StableServiceName,
+									Port: &port,
+								},
+								Weight: &stableWeight,
+							},
+						},
+						{
+							BackendRef: gatewayv1.BackendRef{
+								BackendObjectReference: gatewayv1.BackendObjectReference{
+									Name: mocks.CanaryServiceName,
+									Port: &port,
+								},
+								Weight: &canaryWeight,
+							},
+						},
+					},
+					Matches: []gatewayv1.HTTPRouteMatch{match},
+				},
+			},
+		},
+	}
+}
+
+// Helper function to create GRPCRoute with various match criteria for testing
+func createGRPCRouteWithMatches(name string, headers []gatewayv1.GRPCHeaderMatch, method *gatewayv1.GRPCMethodMatch) *gatewayv1.GRPCRoute {
+	port := gatewayv1.PortNumber(80)
+	stableWeight := int32(100)
+	canaryWeight := int32(0)
+
+	match := gatewayv1.GRPCRouteMatch{}
+	if len(headers) > 0 {
+		match.Headers = headers
+	}
+	if method != nil {
+		match.Method = method
+	}
+
+	return &gatewayv1.GRPCRoute{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: mocks.RolloutNamespace,
+		},
+		Spec: gatewayv1.GRPCRouteSpec{
+			Rules: []gatewayv1.GRPCRouteRule{
+				{
+					BackendRefs: []gatewayv1.GRPCBackendRef{
+						{
+							BackendRef: gatewayv1.BackendRef{
+								BackendObjectReference: gatewayv1.BackendObjectReference{
+									Name: mocks.StableServiceName,
+									Port: &port,
+								},
+								Weight: &stableWeight,
+							},
+						},
+						{
+							BackendRef: gatewayv1.BackendRef{
+								BackendObjectReference: gatewayv1.BackendObjectReference{
+									Name: mocks.CanaryServiceName,
+									Port: &port,
+								},
+								Weight: &canaryWeight,
+							},
+						},
+					},
+					Matches: []gatewayv1.GRPCRouteMatch{match},
+				},
+			},
+		},
+	}
+}
+
+// Tests to verify that SetHeaderRoute preserves and merges existing match criteria
+
+// TestSetHTTPHeaderRouteWithExistingHeaders verifies that when adding canary header-based routing
+// to an HTTPRoute that already has header match criteria (e.g., Host header), the plugin preserves
+// the original headers and merges them with the new canary headers. This ensures that existing
+// routing rules based on headers continue to work alongside the new canary routing.
+func TestSetHTTPHeaderRouteWithExistingHeaders(t *testing.T) {
+	// Create HTTPRoute with existing header match
+	existingHeaderType := gatewayv1.HeaderMatchExact
 	existingHeaders := []gatewayv1.HTTPHeaderMatch{
 		{
-			Type:  &exactType,
+			Type:  &existingHeaderType,
 			Name:  "Host",
 			Value: "example.com",
 		},
-		{
-			Type:  &regexType,
-			Name:  "User-Agent",
-			Value: ".*Mobile.*",
-		},
 	}
 	httpRoute := createHTTPRouteWithMatches(mocks.HTTPRouteName, existingHeaders, nil, nil, nil)
 
@@ -1175,22 +1835,297 @@ func TestSetHTTPHeaderRouteWithMultipleExistingHeaders(t *testing.T) {
 	require.NoError(t, getErr)
 	assert.Equal(t, mocks.ManagedRouteName, string(*updatedHTTP.Spec.Rules[1].Name))
 
-	// Verify that managed route (index 1) includes ALL original headers plus canary header
+	// Verify that managed route (index 1) includes BOTH original header AND canary header
 	managedRouteMatches := updatedHTTP.Spec.Rules[1].Matches
 	assert.NotEmpty(t, managedRouteMatches, "Managed route should have matches")
 
-	// Verify all headers are merged correctly
+	// Verify headers are merged correctly
 	if len(managedRouteMatches) > 0 && len(managedRouteMatches[0].Headers) > 0 {
 		headers := managedRouteMatches[0].Headers
-		assert.Len(t, headers, 3, "Should have 2 original headers plus 1 canary header")
+		assert.Len(t, headers, 2, "Should have both original and canary headers")
 
-		// Check for all three headers
-		hasHostHeader := false
-		hasUserAgentHeader := false
+		// Check if both headers are present
+		hasOriginalHeader := false
 		hasCanaryHeader := false
 		for _, h := range headers {
-			if string(h.Name) == "Host" {
-				hasHostHeader = true
+			if string(h.Name) == "Host" && h.Value == "example.com" {
+				hasOriginalHeader = true
+			}
+			if string(h.Name) == canaryHeaderName {
+				hasCanaryHeader = true
+			}
+		}
+		assert.True(t, hasOriginalHeader, "Original Host header should be preserved")
+		assert.True(t, hasCanaryHeader, "Canary header should be added")
+	} else {
+		t.Fatal("Managed route should have at least one match with headers")
+	}
+}
+
+// TestSetHTTPHeaderRouteWithExistingMethod verifies that when adding canary header-based routing
+// to an HTTPRoute that already has HTTP method match criteria (e.g., POST), the plugin preserves
+// the original method specification. This ensures method-based routing continues to function
+// correctly when canary headers are added for progressive delivery.
+func TestSetHTTPHeaderRouteWithExistingMethod(t *testing.T) {
+	// Create HTTPRoute with existing method match
+	postMethod := gatewayv1.HTTPMethodPost
+	httpRoute := createHTTPRouteWithMatches(mocks.HTTPRouteName, nil, &postMethod, nil, nil)
+
+	// Setup plugin
+	rpcPluginImp := &RpcPlugin{
+		LogCtx:              utils.SetupLog("text"),
+		GatewayAPIClientset: gwFake.NewSimpleClientset(httpRoute),
+	}
+
+	// Setup canary header
+	canaryHeaderName := "X-Canary"
+	canaryHeaderValue := "true"
+	headerMatch := v1alpha1.StringMatch{
+		Exact: canaryHeaderValue,
+	}
+	headerRouting := v1alpha1.SetHeaderRoute{
+		Name: mocks.ManagedRouteName,
+		Match: []v1alpha1.HeaderRoutingMatch{
+			{
+				HeaderName:  canaryHeaderName,
+				HeaderValue: &headerMatch,
+			},
+		},
+	}
+	rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+		Namespace: mocks.RolloutNamespace,
+		HTTPRoute: mocks.HTTPRouteName,
+	})
+
+	// Call SetHeaderRoute
+	err := rpcPluginImp.SetHeaderRoute(rollout, &headerRouting)
+	assert.Empty(t, err.Error())
+	updatedHTTP, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+	require.NoError(t, getErr)
+	assert.Equal(t, mocks.ManagedRouteName, string(*updatedHTTP.Spec.Rules[1].Name))
+
+	// Verify that managed route (index 1) includes BOTH method AND canary header
+	managedRouteMatches := updatedHTTP.Spec.Rules[1].Matches
+	assert.NotEmpty(t, managedRouteMatches, "Managed route should have matches")
+
+	// Verify method and headers are both preserved
+	if len(managedRouteMatches) > 0 {
+		match := managedRouteMatches[0]
+		assert.NotNil(t, match.Method, "Method should be preserved")
+		if match.Method != nil {
+			assert.Equal(t, postMethod, *match.Method, "POST method should be preserved")
+		}
+		assert.NotEmpty(t, match.Headers, "Canary headers should be present")
+	} else {
+		t.Fatal("Managed route should have at least one match")
+	}
+}
+
+// TestSetHTTPHeaderRouteWithExistingQueryParams verifies that when adding canary header-based
+// routing to an HTTPRoute that already has query parameter match criteria (e.g., version=v2),
+// the plugin preserves the original query parameter specifications. This ensures query
+// parameter-based routing continues to work correctly alongside canary header routing.
+func TestSetHTTPHeaderRouteWithExistingQueryParams(t *testing.T) {
+	// Create HTTPRoute with existing query param matches
+	queryParamType := gatewayv1.QueryParamMatchExact
+	queryParams := []gatewayv1.HTTPQueryParamMatch{
+		{
+			Type:  &queryParamType,
+			Name:  "version",
+			Value: "v2",
+		},
+	}
+	httpRoute := createHTTPRouteWithMatches(mocks.HTTPRouteName, nil, nil, queryParams, nil)
+
+	// Setup plugin
+	rpcPluginImp := &RpcPlugin{
+		LogCtx:              utils.SetupLog("text"),
+		GatewayAPIClientset: gwFake.NewSimpleClientset(httpRoute),
+	}
+
+	// Setup canary header
+	canaryHeaderName := "X-Canary"
+	canaryHeaderValue := "true"
+	headerMatch := v1alpha1.StringMatch{
+		Exact: canaryHeaderValue,
+	}
+	headerRouting := v1alpha1.SetHeaderRoute{
+		Name: mocks.ManagedRouteName,
+		Match: []v1alpha1.HeaderRoutingMatch{
+			{
+				HeaderName:  canaryHeaderName,
+				HeaderValue: &headerMatch,
+			},
+		},
+	}
+	rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+		Namespace: mocks.RolloutNamespace,
+		HTTPRoute: mocks.HTTPRouteName,
+	})
+
+	// Call SetHeaderRoute
+	err := rpcPluginImp.SetHeaderRoute(rollout, &headerRouting)
+	assert.Empty(t, err.Error())
+	updatedHTTP, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+	require.NoError(t, getErr)
+	assert.Equal(t, mocks.ManagedRouteName, string(*updatedHTTP.Spec.Rules[1].Name))
+
+	// Verify that managed route (index 1) includes BOTH query params AND canary header
+	managedRouteMatches := updatedHTTP.Spec.Rules[1].Matches
+	assert.NotEmpty(t, managedRouteMatches, "Managed route should have matches")
+
+	// Verify query params and headers are both preserved
+	if len(managedRouteMatches) > 0 {
+		match := managedRouteMatches[0]
+		assert.NotEmpty(t, match.QueryParams, "Query params should be preserved")
+		if len(match.QueryParams) > 0 {
+			assert.Equal(t, "version", string(match.QueryParams[0].Name))
+			assert.Equal(t, "v2", match.QueryParams[0].Value)
+		}
+		assert.NotEmpty(t, match.Headers, "Canary headers should be present")
+	} else {
+		t.Fatal("Managed route should have at least one match")
+	}
+}
+
+// TestSetHTTPHeaderRouteWithHeaderRouteMatches verifies that the query parameter, path and
+// method configured in headerRouteMatches for a managed route are merged into the generated
+// rule: query parameters are appended to the source ones while path and method replace them.
+func TestSetHTTPHeaderRouteWithHeaderRouteMatches(t *testing.T) {
+	queryParamType := gatewayv1.QueryParamMatchExact
+	pathType := gatewayv1.PathMatchPathPrefix
+	path := "/api"
+	canaryPath := "/canary"
+	method := gatewayv1.HTTPMethodGet
+	httpRoute := createHTTPRouteWithMatches(mocks.HTTPRouteName, nil, nil, []gatewayv1.HTTPQueryParamMatch{
+		{Type: &queryParamType, Name: "version", Value: "v2"},
+	}, &gatewayv1.HTTPPathMatch{Type: &pathType, Value: &path})
+
+	rpcPluginImp := &RpcPlugin{
+		LogCtx:              utils.SetupLog("text"),
+		GatewayAPIClientset: gwFake.NewSimpleClientset(httpRoute),
+	}
+	headerRouting := v1alpha1.SetHeaderRoute{
+		Name: mocks.ManagedRouteName,
+		Match: []v1alpha1.HeaderRoutingMatch{
+			{
+				HeaderName:  "X-Canary",
+				HeaderValue: &v1alpha1.StringMatch{Exact: "true"},
+			},
+		},
+	}
+	rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+		Namespace: mocks.RolloutNamespace,
+		HTTPRoute: mocks.HTTPRouteName,
+		HeaderRouteMatches: []HeaderRouteMatch{
+			{
+				Name:        mocks.ManagedRouteName,
+				QueryParams: []gatewayv1.HTTPQueryParamMatch{{Type: &queryParamType, Name: "canary", Value: "true"}},
+				Path:        &gatewayv1.HTTPPathMatch{Type: &pathType, Value: &canaryPath},
+				Method:      &method,
+			},
+		},
+	})
+
+	err := rpcPluginImp.SetHeaderRoute(rollout, &headerRouting)
+	require.Empty(t, err.Error())
+	updatedHTTP, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+	require.NoError(t, getErr)
+	require.Len(t, updatedHTTP.Spec.Rules, 2)
+
+	// The source rule is left untouched
+	assert.Equal(t, path, *updatedHTTP.Spec.Rules[0].Matches[0].Path.Value)
+	assert.Len(t, updatedHTTP.Spec.Rules[0].Matches[0].QueryParams, 1)
+
+	require.Len(t, updatedHTTP.Spec.Rules[1].Matches, 1)
+	match := updatedHTTP.Spec.Rules[1].Matches[0]
+	require.Len(t, match.QueryParams, 2)
+	assert.Equal(t, gatewayv1.HTTPHeaderName("version"), match.QueryParams[0].Name)
+	assert.Equal(t, gatewayv1.HTTPHeaderName("canary"), match.QueryParams[1].Name)
+	assert.Equal(t, canaryPath, *match.Path.Value)
+	assert.Equal(t, method, *match.Method)
+	require.Len(t, match.Headers, 1)
+	assert.Equal(t, gatewayv1.HTTPHeaderName("X-Canary"), match.Headers[0].Name)
+
+	// Calling SetHeaderRoute again replaces the managed rule instead of appending another one
+	err = rpcPluginImp.SetHeaderRoute(rollout, &headerRouting)
+	require.Empty(t, err.Error())
+	updatedHTTP, getErr = rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+	require.NoError(t, getErr)
+	assert.Len(t, updatedHTTP.Spec.Rules, 2)
+}
+
+// TestSetHTTPHeaderRouteWithMultipleExistingHeaders verifies that when adding canary header-based
+// routing to an HTTPRoute that already has multiple header match criteria (e.g., Host and User-Agent),
+// the plugin preserves all original headers and merges them with the new canary headers. This ensures
+// complex header-based routing rules continue to work when implementing progressive delivery.
+func TestSetHTTPHeaderRouteWithMultipleExistingHeaders(t *testing.T) {
+	// Create HTTPRoute with multiple existing header matches
+	exactType := gatewayv1.HeaderMatchExact
+	regexType := gatewayv1.HeaderMatchRegularExpression
+	existingHeaders := []gatewayv1.HTTPHeaderMatch{
+		{
+			Type:  &exactType,
+			Name:  "Host",
+			Value: "example.com",
+		},
+		{
+			Type:  &regexType,
+			Name:  "User-Agent",
+			Value: ".*Mobile.*",
+		},
+	}
+	httpRoute := createHTTPRouteWithMatches(mocks.HTTPRouteName, existingHeaders, nil, nil, nil)
+
+	// Setup plugin
+	rpcPluginImp := &RpcPlugin{
+		LogCtx:              utils.SetupLog("text"),
+		GatewayAPIClientset: gwFake.NewSimpleClientset(httpRoute),
+	}
+
+	// Setup canary header
+	canaryHeaderName := "X-Canary"
+	canaryHeaderValue := "true"
+	headerMatch := v1alpha1.StringMatch{
+		Exact: canaryHeaderValue,
+	}
+	headerRouting := v1alpha1.SetHeaderRoute{
+		Name: mocks.ManagedRouteName,
+		Match: []v1alpha1.HeaderRoutingMatch{
+			{
+				HeaderName:  canaryHeaderName,
+				HeaderValue: &headerMatch,
+			},
+		},
+	}
+	rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+		Namespace: mocks.RolloutNamespace,
+		HTTPRoute: mocks.HTTPRouteName,
+	})
+
+	// Call SetHeaderRoute
+	err := rpcPluginImp.SetHeaderRoute(rollout, &headerRouting)
+	assert.Empty(t, err.Error())
+	updatedHTTP, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+	require.NoError(t, getErr)
+	assert.Equal(t, mocks.ManagedRouteName, string(*updatedHTTP.Spec.Rules[1].Name))
+
+	// Verify that managed route (index 1) includes ALL original headers plus canary header
+	managedRouteMatches := updatedHTTP.Spec.Rules[1].Matches
+	assert.NotEmpty(t, managedRouteMatches, "Managed route should have matches")
+
+	// Verify all headers are merged correctly
+	if len(managedRouteMatches) > 0 && len(managedRouteMatches[0].Headers) > 0 {
+		headers := managedRouteMatches[0].Headers
+		assert.Len(t, headers, 3, "Should have 2 original headers plus 1 canary header")
+
+		// Check for all three headers
+		hasHostHeader := false
+		hasUserAgentHeader := false
+		hasCanaryHeader := false
+		for _, h := range headers {
+			if string(h.Name) == "Host" {
+				hasHostHeader = true
 			}
 			if string(h.Name) == "User-Agent" {
 				hasUserAgentHeader = true
@@ -1830,8 +2765,9 @@ func TestGetRouteRuleOnlyReturnsRuleWithAllBackends(t *testing.T) {
 	httpRouteRuleList := HTTPRouteRuleList(httpRoute.Spec.Rules)
 
 	// Test: Search for rule with both backends
-	backendRefNameList := []string{"argo-rollouts-canary-service", "argo-rollouts-stable-service"}
-	httpRouteRule, err := getRouteRule(httpRouteRuleList, backendRefNameList...)
+	canaryMatcher := newBackendRefMatcher("argo-rollouts-canary-service", nil, mocks.RolloutNamespace, mocks.RolloutNamespace)
+	stableMatcher := newBackendRefMatcher("argo-rollouts-stable-service", nil, mocks.RolloutNamespace, mocks.RolloutNamespace)
+	httpRouteRule, err := getRouteRule(httpRouteRuleList, canaryMatcher, stableMatcher)
 
 	// Assert: Should find the main route (second rule), not the header route (first rule)
 	require.NoError(t, err, "Should find a route with both backends")
@@ -1909,263 +2845,1564 @@ func TestSetWeightPreservesUnmanagedHTTPBackends(t *testing.T) {
 	}
 
 	rpcPluginImp := &RpcPlugin{
-		LogCtx:              utils.SetupLog("text"),
-		GatewayAPIClientset: gwFake.NewSimpleClientset(httpRoute, &mocks.GRPCRouteObj, &mocks.TCPPRouteObj, &mocks.TLSRouteObj),
+		LogCtx:              utils.SetupLog("text"),
+		GatewayAPIClientset: gwFake.NewSimpleClientset(httpRoute, &mocks.GRPCRouteObj, &mocks.TCPPRouteObj, &mocks.TLSRouteObj),
+	}
+	rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+		Namespace: mocks.RolloutNamespace,
+		HTTPRoute: mocks.HTTPRouteName,
+	})
+
+	rpcErr := rpcPluginImp.SetWeight(rollout, 30, []v1alpha1.WeightDestination{})
+	assert.Empty(t, rpcErr.Error())
+
+	updated, err := rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Len(t, updated.Spec.Rules[0].BackendRefs, 3, "extra backend must not be stripped during canary")
+	assert.Equal(t, int32(70), *updated.Spec.Rules[0].BackendRefs[0].Weight)
+	assert.Equal(t, int32(30), *updated.Spec.Rules[0].BackendRefs[1].Weight)
+	assert.Equal(t, gatewayv1.ObjectName(extraServiceName), updated.Spec.Rules[0].BackendRefs[2].Name)
+
+	rpcErr = rpcPluginImp.SetWeight(rollout, 0, []v1alpha1.WeightDestination{})
+	assert.Empty(t, rpcErr.Error())
+
+	updated, err = rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Len(t, updated.Spec.Rules[0].BackendRefs, 3, "extra backend must not be stripped after rollout completes")
+	assert.Equal(t, int32(0), *updated.Spec.Rules[0].BackendRefs[1].Weight)
+	assert.Equal(t, gatewayv1.ObjectName(extraServiceName), updated.Spec.Rules[0].BackendRefs[2].Name)
+}
+
+// TestSetWeightSkipsRulesWithoutRolloutBackends verifies that rules of an HTTPRoute without
+// the stable or canary backend keep their weights and do not count as unmanaged traffic.
+func TestSetWeightSkipsRulesWithoutRolloutBackends(t *testing.T) {
+	otherWeight := int32(5)
+	httpRoute := mocks.CreateHTTPRouteWithLabels(mocks.HTTPRouteName, nil)
+	httpRoute.Spec.Rules = append(httpRoute.Spec.Rules, gatewayv1.HTTPRouteRule{
+		BackendRefs: []gatewayv1.HTTPBackendRef{
+			{
+				BackendRef: gatewayv1.BackendRef{
+					BackendObjectReference: gatewayv1.BackendObjectReference{
+						Name: "other-service",
+					},
+					Weight: &otherWeight,
+				},
+			},
+		},
+	})
+	logger, logHook := logtest.NewNullLogger()
+	rpcPluginImp := &RpcPlugin{
+		LogCtx:              log.NewEntry(logger),
+		GatewayAPIClientset: gwFake.NewSimpleClientset(httpRoute),
+	}
+	rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+		Namespace: mocks.RolloutNamespace,
+		HTTPRoute: mocks.HTTPRouteName,
+	})
+
+	rpcErr := rpcPluginImp.SetWeight(rollout, 30, []v1alpha1.WeightDestination{})
+	require.Empty(t, rpcErr.Error())
+
+	updated, err := rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int32(30), *updated.Spec.Rules[0].BackendRefs[1].Weight)
+	assert.Equal(t, int32(5), *updated.Spec.Rules[1].BackendRefs[0].Weight)
+	for _, entry := range logHook.AllEntries() {
+		assert.NotEqual(t, log.WarnLevel, entry.Level, entry.Message)
+	}
+}
+
+// TestGetRouteRuleReturnsErrorWhenBackendNotFound verifies that getRouteRule returns
+// an error when no rule contains all requested backends.
+func TestGetRouteRuleReturnsErrorWhenBackendNotFound(t *testing.T) {
+	// Create a route with only header routes (single backend each)
+	canaryName := gatewayv1.SectionName("header-route")
+
+	httpRoute := &gatewayv1.HTTPRoute{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-route",
+			Namespace: "default",
+		},
+		Spec: gatewayv1.HTTPRouteSpec{
+			Rules: []gatewayv1.HTTPRouteRule{
+				{
+					Name: &canaryName,
+					BackendRefs: []gatewayv1.HTTPBackendRef{
+						{
+							BackendRef: gatewayv1.BackendRef{
+								BackendObjectReference: gatewayv1.BackendObjectReference{
+									Name: "argo-rollouts-canary-service",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	httpRouteRuleList := HTTPRouteRuleList(httpRoute.Spec.Rules)
+
+	// Test: Search for rule with both backends (only canary exists)
+	canaryMatcher := newBackendRefMatcher("argo-rollouts-canary-service", nil, mocks.RolloutNamespace, mocks.RolloutNamespace)
+	stableMatcher := newBackendRefMatcher("argo-rollouts-stable-service", nil, mocks.RolloutNamespace, mocks.RolloutNamespace)
+	httpRouteRule, err := getRouteRule(httpRouteRuleList, canaryMatcher, stableMatcher)
+
+	// Assert: Should return error because no rule has both backends
+	require.Error(t, err, "Should return error when backend not found")
+	assert.Nil(t, httpRouteRule, "Route rule should be nil on error")
+	assert.Equal(t, BackendRefWasNotFoundInHTTPRouteError, err.Error())
+}
+
+// TestSetHTTPHeaderRouteMultiRuleHTTPRoute verifies that SetHeaderRoute injects one managed
+// header rule per source rule on a multi-rule HTTPRoute (issue #207).
+//
+// A two-rule HTTPRoute with paths /a and /b should produce two managed header rules after
+// SetHeaderRoute — one covering /a and one covering /b — so the canary header applies to the
+// entire route, not just its first rule.
+func TestSetHTTPHeaderRouteMultiRuleHTTPRoute(t *testing.T) {
+	port := gatewayv1.PortNumber(80)
+	stableWeight := int32(100)
+	canaryWeight := int32(0)
+	pathPrefixType := gatewayv1.PathMatchPathPrefix
+	pathA := "/a"
+	pathB := "/b"
+
+	httpRoute := &gatewayv1.HTTPRoute{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      mocks.HTTPRouteName,
+			Namespace: mocks.RolloutNamespace,
+		},
+		Spec: gatewayv1.HTTPRouteSpec{
+			Rules: []gatewayv1.HTTPRouteRule{
+				{
+					BackendRefs: []gatewayv1.HTTPBackendRef{
+						{BackendRef: gatewayv1.BackendRef{BackendObjectReference: gatewayv1.BackendObjectReference{Name: mocks.StableServiceName, Port: &port}, Weight: &stableWeight}},
+						{BackendRef: gatewayv1.BackendRef{BackendObjectReference: gatewayv1.BackendObjectReference{Name: mocks.CanaryServiceName, Port: &port}, Weight: &canaryWeight}},
+					},
+					Matches: []gatewayv1.HTTPRouteMatch{{Path: &gatewayv1.HTTPPathMatch{Type: &pathPrefixType, Value: &pathA}}},
+				},
+				{
+					BackendRefs: []gatewayv1.HTTPBackendRef{
+						{BackendRef: gatewayv1.BackendRef{BackendObjectReference: gatewayv1.BackendObjectReference{Name: mocks.StableServiceName, Port: &port}, Weight: &stableWeight}},
+						{BackendRef: gatewayv1.BackendRef{BackendObjectReference: gatewayv1.BackendObjectReference{Name: mocks.CanaryServiceName, Port: &port}, Weight: &canaryWeight}},
+					},
+					Matches: []gatewayv1.HTTPRouteMatch{{Path: &gatewayv1.HTTPPathMatch{Type: &pathPrefixType, Value: &pathB}}},
+				},
+			},
+		},
+	}
+
+	rpcPluginImp := &RpcPlugin{
+		LogCtx:              utils.SetupLog("text"),
+		GatewayAPIClientset: gwFake.NewSimpleClientset(httpRoute),
+	}
+	rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+		Namespace: mocks.RolloutNamespace,
+		HTTPRoute: mocks.HTTPRouteName,
+	})
+
+	exactMatch := v1alpha1.StringMatch{Exact: "v2"}
+	headerRouting := v1alpha1.SetHeaderRoute{
+		Name: mocks.ManagedRouteName,
+		Match: []v1alpha1.HeaderRoutingMatch{
+			{HeaderName: "X-Version", HeaderValue: &exactMatch},
+		},
+	}
+
+	err := rpcPluginImp.SetHeaderRoute(rollout, &headerRouting)
+	assert.Empty(t, err.Error())
+
+	updated, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+	require.NoError(t, getErr)
+
+	// Expect 4 rules: 2 original source rules + 1 managed header rule per source rule.
+	assert.Len(t, updated.Spec.Rules, 4, "expected one managed header rule per source rule (2 source + 2 managed = 4 total)")
+
+	// Managed rules are identified by name: index 0 gets the bare managed name,
+	// subsequent rules get managedName-N (Gateway API requires unique names per route).
+	managedRules := []gatewayv1.HTTPRouteRule{}
+	for _, rule := range updated.Spec.Rules {
+		if rule.Name != nil && strings.HasPrefix(string(*rule.Name), mocks.ManagedRouteName) {
+			managedRules = append(managedRules, rule)
+		}
+	}
+	assert.Len(t, managedRules, 2, "expected two managed header rules (one per source path)")
+
+	// Each managed rule must carry the canary header and preserve its source path prefix.
+	paths := map[string]bool{}
+	for _, rule := range managedRules {
+		require.Len(t, rule.BackendRefs, 1, "managed rule must have exactly one (canary) backend ref")
+		assert.Equal(t, gatewayv1.ObjectName(mocks.CanaryServiceName), rule.BackendRefs[0].Name)
+		require.NotEmpty(t, rule.Matches)
+		require.NotNil(t, rule.Matches[0].Path)
+		paths[*rule.Matches[0].Path.Value] = true
+		hasCanaryHeader := false
+		for _, h := range rule.Matches[0].Headers {
+			if string(h.Name) == "X-Version" && h.Value == "v2" {
+				hasCanaryHeader = true
+			}
+		}
+		assert.True(t, hasCanaryHeader, "managed rule for path %s must carry the canary header", *rule.Matches[0].Path.Value)
+	}
+	assert.True(t, paths["/a"], "expected a managed header rule covering path /a")
+	assert.True(t, paths["/b"], "expected a managed header rule covering path /b")
+}
+
+// TestSetGRPCHeaderRouteMultiRuleGRPCRoute verifies that SetHeaderRoute injects one managed
+// header rule per source rule on a multi-rule GRPCRoute (issue #207).
+//
+// A two-rule GRPCRoute with different gRPC methods should produce two managed header rules —
+// one per method — so the canary header applies to the entire route, not just its first rule.
+func TestSetGRPCHeaderRouteMultiRuleGRPCRoute(t *testing.T) {
+	port := gatewayv1.PortNumber(80)
+	stableWeight := int32(100)
+	canaryWeight := int32(0)
+	methodMatchType := gatewayv1.GRPCMethodMatchExact
+	serviceA := "svc.Service"
+	methodA := "MethodA"
+	serviceB := "svc.Service"
+	methodB := "MethodB"
+
+	grpcRoute := &gatewayv1.GRPCRoute{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      mocks.GRPCRouteName,
+			Namespace: mocks.RolloutNamespace,
+		},
+		Spec: gatewayv1.GRPCRouteSpec{
+			Rules: []gatewayv1.GRPCRouteRule{
+				{
+					BackendRefs: []gatewayv1.GRPCBackendRef{
+						{BackendRef: gatewayv1.BackendRef{BackendObjectReference: gatewayv1.BackendObjectReference{Name: mocks.StableServiceName, Port: &port}, Weight: &stableWeight}},
+						{BackendRef: gatewayv1.BackendRef{BackendObjectReference: gatewayv1.BackendObjectReference{Name: mocks.CanaryServiceName, Port: &port}, Weight: &canaryWeight}},
+					},
+					Matches: []gatewayv1.GRPCRouteMatch{{Method: &gatewayv1.GRPCMethodMatch{Type: &methodMatchType, Service: &serviceA, Method: &methodA}}},
+				},
+				{
+					BackendRefs: []gatewayv1.GRPCBackendRef{
+						{BackendRef: gatewayv1.BackendRef{BackendObjectReference: gatewayv1.BackendObjectReference{Name: mocks.StableServiceName, Port: &port}, Weight: &stableWeight}},
+						{BackendRef: gatewayv1.BackendRef{BackendObjectReference: gatewayv1.BackendObjectReference{Name: mocks.CanaryServiceName, Port: &port}, Weight: &canaryWeight}},
+					},
+					Matches: []gatewayv1.GRPCRouteMatch{{Method: &gatewayv1.GRPCMethodMatch{Type: &methodMatchType, Service: &serviceB, Method: &methodB}}},
+				},
+			},
+		},
+	}
+
+	rpcPluginImp := &RpcPlugin{
+		LogCtx:              utils.SetupLog("text"),
+		GatewayAPIClientset: gwFake.NewSimpleClientset(grpcRoute),
+	}
+	rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+		Namespace: mocks.RolloutNamespace,
+		GRPCRoute: mocks.GRPCRouteName,
+	})
+
+	exactMatch := v1alpha1.StringMatch{Exact: "v2"}
+	headerRouting := v1alpha1.SetHeaderRoute{
+		Name: mocks.ManagedRouteName,
+		Match: []v1alpha1.HeaderRoutingMatch{
+			{HeaderName: "X-Version", HeaderValue: &exactMatch},
+		},
+	}
+
+	err := rpcPluginImp.SetHeaderRoute(rollout, &headerRouting)
+	assert.Empty(t, err.Error())
+
+	updated, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1().GRPCRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.GRPCRouteName, metav1.GetOptions{})
+	require.NoError(t, getErr)
+
+	// Expect 4 rules: 2 original source rules + 1 managed header rule per source rule.
+	assert.Len(t, updated.Spec.Rules, 4, "expected one managed header rule per source rule (2 source + 2 managed = 4 total)")
+
+	// Managed rules are identified by name: index 0 gets the bare managed name,
+	// subsequent rules get managedName-N (Gateway API requires unique names per route).
+	managedRules := []gatewayv1.GRPCRouteRule{}
+	for _, rule := range updated.Spec.Rules {
+		if rule.Name != nil && strings.HasPrefix(string(*rule.Name), mocks.ManagedRouteName) {
+			managedRules = append(managedRules, rule)
+		}
+	}
+	assert.Len(t, managedRules, 2, "expected two managed header rules (one per source method)")
+
+	// Each managed rule must carry the canary header and preserve its source method.
+	methods := map[string]bool{}
+	for _, rule := range managedRules {
+		require.Len(t, rule.BackendRefs, 1, "managed rule must have exactly one (canary) backend ref")
+		assert.Equal(t, gatewayv1.ObjectName(mocks.CanaryServiceName), rule.BackendRefs[0].Name)
+		require.NotEmpty(t, rule.Matches)
+		require.NotNil(t, rule.Matches[0].Method)
+		require.NotNil(t, rule.Matches[0].Method.Method)
+		methods[*rule.Matches[0].Method.Method] = true
+		hasCanaryHeader := false
+		for _, h := range rule.Matches[0].Headers {
+			if string(h.Name) == "X-Version" && h.Value == "v2" {
+				hasCanaryHeader = true
+			}
+		}
+		assert.True(t, hasCanaryHeader, "managed rule for method %s must carry the canary header", *rule.Matches[0].Method.Method)
+	}
+	assert.True(t, methods["MethodA"], "expected a managed header rule covering MethodA")
+	assert.True(t, methods["MethodB"], "expected a managed header rule covering MethodB")
+}
+
+// TestSetHTTPMirrorRoute verifies that SetMirrorRoute injects a managed rule that keeps the
+// source rule's backends, merges the mirror matches and mirrors requests to the canary.
+func TestSetHTTPMirrorRoute(t *testing.T) {
+	httpRoute := mocks.CreateHTTPRouteWithLabels(mocks.HTTPRouteName, nil)
+
+	rpcPluginImp := &RpcPlugin{
+		LogCtx:              utils.SetupLog("text"),
+		GatewayAPIClientset: gwFake.NewSimpleClientset(httpRoute),
+	}
+	rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+		Namespace: mocks.RolloutNamespace,
+		HTTPRoute: mocks.HTTPRouteName,
+	})
+
+	percentage := int32(40)
+	mirrorRoute := v1alpha1.SetMirrorRoute{
+		Name: mocks.ManagedRouteName,
+		Match: []v1alpha1.RouteMatch{
+			{
+				Method:  &v1alpha1.StringMatch{Exact: "get"},
+				Path:    &v1alpha1.StringMatch{Prefix: "/api"},
+				Headers: map[string]v1alpha1.StringMatch{"X-Mirror": {Exact: "true"}},
+			},
+		},
+		Percentage: &percentage,
+	}
+
+	err := rpcPluginImp.SetMirrorRoute(rollout, &mirrorRoute)
+	assert.Empty(t, err.Error())
+
+	updatedHTTP, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+	require.NoError(t, getErr)
+	require.Len(t, updatedHTTP.Spec.Rules, 2)
+
+	mirrorRule := updatedHTTP.Spec.Rules[1]
+	assert.Equal(t, mocks.ManagedRouteName, string(*mirrorRule.Name))
+	require.Len(t, mirrorRule.BackendRefs, 2, "mirror rule must keep the source rule's backends")
+	assert.Equal(t, gatewayv1.ObjectName(mocks.StableServiceName), mirrorRule.BackendRefs[0].Name)
+	assert.Equal(t, gatewayv1.ObjectName(mocks.CanaryServiceName), mirrorRule.BackendRefs[1].Name)
+
+	require.Len(t, mirrorRule.Filters, 1)
+	assert.Equal(t, gatewayv1.HTTPRouteFilterRequestMirror, mirrorRule.Filters[0].Type)
+	require.NotNil(t, mirrorRule.Filters[0].RequestMirror)
+	assert.Equal(t, gatewayv1.ObjectName(mocks.CanaryServiceName), mirrorRule.Filters[0].RequestMirror.BackendRef.Name)
+	assert.Equal(t, percentage, *mirrorRule.Filters[0].RequestMirror.Percent)
+
+	require.Len(t, mirrorRule.Matches, 1)
+	match := mirrorRule.Matches[0]
+	require.NotNil(t, match.Path)
+	assert.Equal(t, gatewayv1.PathMatchPathPrefix, *match.Path.Type)
+	assert.Equal(t, "/api", *match.Path.Value)
+	require.NotNil(t, match.Method)
+	assert.Equal(t, gatewayv1.HTTPMethodGet, *match.Method)
+	require.Len(t, match.Headers, 1)
+	assert.Equal(t, gatewayv1.HTTPHeaderName("X-Mirror"), match.Headers[0].Name)
+
+	// SetWeight must keep the mirror rule in the stable/canary split
+	err = rpcPluginImp.SetWeight(rollout, 30, []v1alpha1.WeightDestination{})
+	assert.Empty(t, err.Error())
+	updatedHTTP, getErr = rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+	require.NoError(t, getErr)
+	assert.Equal(t, int32(70), *updatedHTTP.Spec.Rules[1].BackendRefs[0].Weight)
+	assert.Equal(t, int32(30), *updatedHTTP.Spec.Rules[1].BackendRefs[1].Weight)
+
+	// Repeated calls must update the mirror rule in place
+	err = rpcPluginImp.SetMirrorRoute(rollout, &mirrorRoute)
+	assert.Empty(t, err.Error())
+	updatedHTTP, getErr = rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+	require.NoError(t, getErr)
+	assert.Len(t, updatedHTTP.Spec.Rules, 2)
+
+	err = rpcPluginImp.RemoveManagedRoutes(rollout)
+	assert.Empty(t, err.Error())
+	updatedHTTP, getErr = rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+	require.NoError(t, getErr)
+	assert.Len(t, updatedHTTP.Spec.Rules, 1)
+}
+
+// TestSetHTTPMirrorRouteWithoutMatchRemovesRoute verifies that a setMirrorRoute step without
+// matches removes only the named mirror rule.
+func TestSetHTTPMirrorRouteWithoutMatchRemovesRoute(t *testing.T) {
+	httpRoute := mocks.CreateHTTPRouteWithLabels(mocks.HTTPRouteName, nil)
+
+	rpcPluginImp := &RpcPlugin{
+		LogCtx:              utils.SetupLog("text"),
+		GatewayAPIClientset: gwFake.NewSimpleClientset(httpRoute),
+	}
+	rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+		Namespace: mocks.RolloutNamespace,
+		HTTPRoute: mocks.HTTPRouteName,
+	})
+	mirrorRouteName := "mirror-route"
+	rollout.Spec.Strategy.Canary.TrafficRouting.ManagedRoutes = append(rollout.Spec.Strategy.Canary.TrafficRouting.ManagedRoutes, v1alpha1.MangedRoutes{Name: mirrorRouteName})
+
+	headerRouting := v1alpha1.SetHeaderRoute{
+		Name: mocks.ManagedRouteName,
+		Match: []v1alpha1.HeaderRoutingMatch{
+			{HeaderName: "X-Canary", HeaderValue: &v1alpha1.StringMatch{Exact: "true"}},
+		},
+	}
+	err := rpcPluginImp.SetHeaderRoute(rollout, &headerRouting)
+	assert.Empty(t, err.Error())
+
+	mirrorRoute := v1alpha1.SetMirrorRoute{
+		Name: mirrorRouteName,
+		Match: []v1alpha1.RouteMatch{
+			{Headers: map[string]v1alpha1.StringMatch{"X-Mirror": {Exact: "true"}}},
+		},
+	}
+	err = rpcPluginImp.SetMirrorRoute(rollout, &mirrorRoute)
+	assert.Empty(t, err.Error())
+
+	// The mirror rule must not be used as a source rule for the header rule
+	err = rpcPluginImp.SetHeaderRoute(rollout, &headerRouting)
+	assert.Empty(t, err.Error())
+	updatedHTTP, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+	require.NoError(t, getErr)
+	assert.Len(t, updatedHTTP.Spec.Rules, 3)
+
+	err = rpcPluginImp.SetMirrorRoute(rollout, &v1alpha1.SetMirrorRoute{Name: mirrorRouteName})
+	assert.Empty(t, err.Error())
+	updatedHTTP, getErr = rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+	require.NoError(t, getErr)
+	require.Len(t, updatedHTTP.Spec.Rules, 2)
+	assert.Equal(t, mocks.ManagedRouteName, string(*updatedHTTP.Spec.Rules[1].Name))
+}
+
+// TestSetHTTPMirrorRouteOutsideSourcePath verifies that a mirror match is only merged into a
+// source rule whose path contains it, since the mirror rule keeps the source rule's backends.
+func TestSetHTTPMirrorRouteOutsideSourcePath(t *testing.T) {
+	pathType := gatewayv1.PathMatchPathPrefix
+	path := "/api"
+	httpRoute := createHTTPRouteWithMatches(mocks.HTTPRouteName, nil, nil, nil, &gatewayv1.HTTPPathMatch{Type: &pathType, Value: &path})
+
+	rpcPluginImp := &RpcPlugin{
+		LogCtx:              utils.SetupLog("text"),
+		GatewayAPIClientset: gwFake.NewSimpleClientset(httpRoute),
+	}
+	rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+		Namespace: mocks.RolloutNamespace,
+		HTTPRoute: mocks.HTTPRouteName,
+	})
+
+	// A path outside the source rule is rejected and the route is left untouched
+	err := rpcPluginImp.SetMirrorRoute(rollout, &v1alpha1.SetMirrorRoute{
+		Name:  mocks.ManagedRouteName,
+		Match: []v1alpha1.RouteMatch{{Path: &v1alpha1.StringMatch{Prefix: "/other"}}},
+	})
+	assert.Equal(t, fmt.Sprintf(MirrorRouteMatchOutsideSourceRulesError, 0, mocks.ManagedRouteName, mocks.HTTPRouteName), err.Error())
+	updatedHTTP, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+	require.NoError(t, getErr)
+	assert.Len(t, updatedHTTP.Spec.Rules, 1)
+
+	// A prefix that only shares characters with the source path is not nested within it
+	err = rpcPluginImp.SetMirrorRoute(rollout, &v1alpha1.SetMirrorRoute{
+		Name:  mocks.ManagedRouteName,
+		Match: []v1alpha1.RouteMatch{{Path: &v1alpha1.StringMatch{Prefix: "/apis"}}},
+	})
+	assert.Equal(t, fmt.Sprintf(MirrorRouteMatchOutsideSourceRulesError, 0, mocks.ManagedRouteName, mocks.HTTPRouteName), err.Error())
+
+	// A path nested within the source rule narrows the mirror rule
+	err = rpcPluginImp.SetMirrorRoute(rollout, &v1alpha1.SetMirrorRoute{
+		Name:  mocks.ManagedRouteName,
+		Match: []v1alpha1.RouteMatch{{Path: &v1alpha1.StringMatch{Exact: "/api/v1"}}},
+	})
+	assert.Empty(t, err.Error())
+	updatedHTTP, getErr = rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+	require.NoError(t, getErr)
+	require.Len(t, updatedHTTP.Spec.Rules, 2)
+	require.Len(t, updatedHTTP.Spec.Rules[1].Matches, 1)
+	match := updatedHTTP.Spec.Rules[1].Matches[0]
+	assert.Equal(t, gatewayv1.PathMatchExact, *match.Path.Type)
+	assert.Equal(t, "/api/v1", *match.Path.Value)
+}
+
+func TestSetHTTPMirrorRouteInvalidMethodMatch(t *testing.T) {
+	httpRoute := mocks.CreateHTTPRouteWithLabels(mocks.HTTPRouteName, nil)
+
+	rpcPluginImp := &RpcPlugin{
+		LogCtx:              utils.SetupLog("text"),
+		GatewayAPIClientset: gwFake.NewSimpleClientset(httpRoute),
+	}
+	rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+		Namespace: mocks.RolloutNamespace,
+		HTTPRoute: mocks.HTTPRouteName,
+	})
+	mirrorRoute := v1alpha1.SetMirrorRoute{
+		Name: mocks.ManagedRouteName,
+		Match: []v1alpha1.RouteMatch{
+			{Method: &v1alpha1.StringMatch{Prefix: "G"}},
+		},
+	}
+
+	err := rpcPluginImp.SetMirrorRoute(rollout, &mirrorRoute)
+	assert.Equal(t, InvalidMethodMatchTypeError, err.Error())
+}
+
+// TestSetGRPCMirrorRoute verifies that SetMirrorRoute injects a managed mirror rule into a
+// GRPCRoute and rejects HTTP-only match criteria.
+func TestSetGRPCMirrorRoute(t *testing.T) {
+	grpcRoute := mocks.CreateGRPCRouteWithLabels(mocks.GRPCRouteName, nil)
+
+	rpcPluginImp := &RpcPlugin{
+		LogCtx:              utils.SetupLog("text"),
+		GatewayAPIClientset: gwFake.NewSimpleClientset(grpcRoute),
+	}
+	rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+		Namespace: mocks.RolloutNamespace,
+		GRPCRoute: mocks.GRPCRouteName,
+	})
+
+	mirrorRoute := v1alpha1.SetMirrorRoute{
+		Name: mocks.ManagedRouteName,
+		Match: []v1alpha1.RouteMatch{
+			{Headers: map[string]v1alpha1.StringMatch{"X-Mirror": {Regex: "tr.*"}}},
+		},
+	}
+	err := rpcPluginImp.SetMirrorRoute(rollout, &mirrorRoute)
+	assert.Empty(t, err.Error())
+
+	updatedGRPC, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1().GRPCRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.GRPCRouteName, metav1.GetOptions{})
+	require.NoError(t, getErr)
+	require.Len(t, updatedGRPC.Spec.Rules, 2)
+	mirrorRule := updatedGRPC.Spec.Rules[1]
+	assert.Equal(t, mocks.ManagedRouteName, string(*mirrorRule.Name))
+	assert.Len(t, mirrorRule.BackendRefs, 2)
+	require.Len(t, mirrorRule.Filters, 1)
+	assert.Equal(t, gatewayv1.GRPCRouteFilterRequestMirror, mirrorRule.Filters[0].Type)
+	assert.Equal(t, gatewayv1.ObjectName(mocks.CanaryServiceName), mirrorRule.Filters[0].RequestMirror.BackendRef.Name)
+	assert.Nil(t, mirrorRule.Filters[0].RequestMirror.Percent)
+	require.Len(t, mirrorRule.Matches, 1)
+	require.Len(t, mirrorRule.Matches[0].Headers, 1)
+	assert.Equal(t, gatewayv1.GRPCHeaderName("X-Mirror"), mirrorRule.Matches[0].Headers[0].Name)
+
+	err = rpcPluginImp.SetMirrorRoute(rollout, &v1alpha1.SetMirrorRoute{
+		Name:  mocks.ManagedRouteName,
+		Match: []v1alpha1.RouteMatch{{Path: &v1alpha1.StringMatch{Prefix: "/"}}},
+	})
+	assert.Equal(t, UnsupportedGRPCMirrorMatchError, err.Error())
+
+	err = rpcPluginImp.RemoveManagedRoutes(rollout)
+	assert.Empty(t, err.Error())
+	updatedGRPC, getErr = rpcPluginImp.GatewayAPIClientset.GatewayV1().GRPCRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.GRPCRouteName, metav1.GetOptions{})
+	require.NoError(t, getErr)
+	assert.Len(t, updatedGRPC.Spec.Rules, 1)
+}
+
+func newRouteParentStatus(generation int64, accepted, resolvedRefs metav1.ConditionStatus) gatewayv1.RouteParentStatus {
+	return gatewayv1.RouteParentStatus{
+		ParentRef:      gatewayv1.ParentReference{Name: "gateway"},
+		ControllerName: "example.com/gateway-controller",
+		Conditions: []metav1.Condition{
+			{Type: string(gatewayv1.RouteConditionAccepted), Status: accepted, ObservedGeneration: generation},
+			{Type: string(gatewayv1.RouteConditionResolvedRefs), Status: resolvedRefs, ObservedGeneration: generation},
+		},
+	}
+}
+
+func TestVerifyHTTPRouteWeight(t *testing.T) {
+	rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+		Namespace: mocks.RolloutNamespace,
+		HTTPRoute: mocks.HTTPRouteName,
+	})
+	newPlugin := func(routeStatus ...gatewayv1.RouteParentStatus) *RpcPlugin {
+		httpRoute := mocks.CreateHTTPRouteWithLabels(mocks.HTTPRouteName, nil)
+		httpRoute.Generation = 2
+		httpRoute.Status.Parents = routeStatus
+		rpcPluginImp := &RpcPlugin{
+			LogCtx:              utils.SetupLog("text"),
+			GatewayAPIClientset: gwFake.NewSimpleClientset(httpRoute),
+		}
+		err := rpcPluginImp.SetWeight(rollout, 30, []v1alpha1.WeightDestination{})
+		require.Empty(t, err.Error())
+		return rpcPluginImp
+	}
+
+	t.Run("VerifiedWhenAcceptedWithDesiredWeights", func(t *testing.T) {
+		rpcPluginImp := newPlugin(newRouteParentStatus(2, metav1.ConditionTrue, metav1.ConditionTrue))
+		verified, err := rpcPluginImp.VerifyWeight(rollout, 30, []v1alpha1.WeightDestination{})
+		assert.Empty(t, err.Error())
+		assert.Equal(t, pluginTypes.Verified, verified)
+	})
+	t.Run("NotVerifiedWhenWeightDiffers", func(t *testing.T) {
+		rpcPluginImp := newPlugin(newRouteParentStatus(2, metav1.ConditionTrue, metav1.ConditionTrue))
+		verified, err := rpcPluginImp.VerifyWeight(rollout, 50, []v1alpha1.WeightDestination{})
+		assert.Empty(t, err.Error())
+		assert.Equal(t, pluginTypes.NotVerified, verified)
+	})
+	t.Run("NotVerifiedWithoutParentStatus", func(t *testing.T) {
+		rpcPluginImp := newPlugin()
+		verified, err := rpcPluginImp.VerifyWeight(rollout, 30, []v1alpha1.WeightDestination{})
+		assert.Empty(t, err.Error())
+		assert.Equal(t, pluginTypes.NotVerified, verified)
+	})
+	t.Run("NotVerifiedWhenGenerationNotObserved", func(t *testing.T) {
+		rpcPluginImp := newPlugin(newRouteParentStatus(1, metav1.ConditionTrue, metav1.ConditionTrue))
+		verified, err := rpcPluginImp.VerifyWeight(rollout, 30, []v1alpha1.WeightDestination{})
+		assert.Empty(t, err.Error())
+		assert.Equal(t, pluginTypes.NotVerified, verified)
+	})
+	t.Run("NotVerifiedWhenRefsNotResolved", func(t *testing.T) {
+		rpcPluginImp := newPlugin(
+			newRouteParentStatus(2, metav1.ConditionTrue, metav1.ConditionTrue),
+			newRouteParentStatus(2, metav1.ConditionTrue, metav1.ConditionFalse),
+		)
+		verified, err := rpcPluginImp.VerifyWeight(rollout, 30, []v1alpha1.WeightDestination{})
+		assert.Empty(t, err.Error())
+		assert.Equal(t, pluginTypes.NotVerified, verified)
+	})
+}
+
+func TestVerifyTCPRouteWeight(t *testing.T) {
+	tcpRoute := mocks.CreateTCPRouteWithLabels(mocks.TCPRouteName, nil)
+	tcpRoute.Status.Parents = []gatewayv1.RouteParentStatus{newRouteParentStatus(0, metav1.ConditionTrue, metav1.ConditionTrue)}
+	rpcPluginImp := &RpcPlugin{
+		LogCtx:              utils.SetupLog("text"),
+		GatewayAPIClientset: gwFake.NewSimpleClientset(tcpRoute),
+	}
+	rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+		Namespace: mocks.RolloutNamespace,
+		TCPRoute:  mocks.TCPRouteName,
+	})
+
+	verified, err := rpcPluginImp.VerifyWeight(rollout, 20, []v1alpha1.WeightDestination{})
+	assert.Empty(t, err.Error())
+	assert.Equal(t, pluginTypes.NotVerified, verified)
+
+	err = rpcPluginImp.SetWeight(rollout, 20, []v1alpha1.WeightDestination{})
+	require.Empty(t, err.Error())
+	verified, err = rpcPluginImp.VerifyWeight(rollout, 20, []v1alpha1.WeightDestination{})
+	assert.Empty(t, err.Error())
+	assert.Equal(t, pluginTypes.Verified, verified)
+}
+
+func TestSetTCPRouteWeightSkipsRulesWithoutRolloutBackends(t *testing.T) {
+	otherWeight := int32(50)
+	tcpRoute := mocks.CreateTCPRouteWithLabels(mocks.TCPRouteName, nil)
+	tcpRoute.Spec.Rules = append(tcpRoute.Spec.Rules, v1alpha2.TCPRouteRule{
+		BackendRefs: []gatewayv1.BackendRef{
+			{
+				BackendObjectReference: gatewayv1.BackendObjectReference{
+					Name: "other-service",
+				},
+				Weight: &otherWeight,
+			},
+		},
+	})
+	logger, logHook := logtest.NewNullLogger()
+	rpcPluginImp := &RpcPlugin{
+		LogCtx:              log.NewEntry(logger),
+		GatewayAPIClientset: gwFake.NewSimpleClientset(tcpRoute),
+	}
+	rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+		Namespace: mocks.RolloutNamespace,
+		TCPRoute:  mocks.TCPRouteName,
+	})
+
+	rpcErr := rpcPluginImp.SetWeight(rollout, 20, []v1alpha1.WeightDestination{})
+	require.Empty(t, rpcErr.Error())
+
+	updated, err := rpcPluginImp.GatewayAPIClientset.GatewayV1alpha2().TCPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.TCPRouteName, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int32(80), *updated.Spec.Rules[0].BackendRefs[0].Weight)
+	assert.Equal(t, int32(20), *updated.Spec.Rules[0].BackendRefs[1].Weight)
+	assert.Equal(t, int32(50), *updated.Spec.Rules[1].BackendRefs[0].Weight)
+	for _, entry := range logHook.AllEntries() {
+		assert.NotEqual(t, log.WarnLevel, entry.Level, entry.Message)
+	}
+}
+
+func TestRestoreOriginalSpecOnAbort(t *testing.T) {
+	httpRoute := mocks.CreateHTTPRouteWithLabels(mocks.HTTPRouteName, nil)
+	originalSpec := *httpRoute.Spec.DeepCopy()
+
+	rpcPluginImp := &RpcPlugin{
+		LogCtx:              utils.SetupLog("text"),
+		GatewayAPIClientset: gwFake.NewSimpleClientset(httpRoute),
+	}
+	rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+		Namespace:           mocks.RolloutNamespace,
+		HTTPRoute:           mocks.HTTPRouteName,
+		RestoreOriginalSpec: true,
+	})
+	rollout.UID = "rollout-uid"
+	rollout.Status.StableRS = "stable-hash"
+	rollout.Status.CurrentPodHash = "canary-hash"
+
+	headerRouting := v1alpha1.SetHeaderRoute{
+		Name: mocks.ManagedRouteName,
+		Match: []v1alpha1.HeaderRoutingMatch{
+			{HeaderName: "X-Canary", HeaderValue: &v1alpha1.StringMatch{Exact: "true"}},
+		},
+	}
+	err := rpcPluginImp.SetHeaderRoute(rollout, &headerRouting)
+	assert.Empty(t, err.Error())
+	err = rpcPluginImp.SetWeight(rollout, 40, []v1alpha1.WeightDestination{})
+	assert.Empty(t, err.Error())
+
+	updatedHTTP, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+	require.NoError(t, getErr)
+	require.Contains(t, updatedHTTP.Annotations, defaults.OriginalSpecAnnotationKey)
+	assert.Len(t, updatedHTTP.Spec.Rules, 2)
+
+	// Going back to weight 0 while the canary is still in progress keeps the managed changes
+	err = rpcPluginImp.SetWeight(rollout, 0, []v1alpha1.WeightDestination{})
+	assert.Empty(t, err.Error())
+	updatedHTTP, getErr = rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+	require.NoError(t, getErr)
+	assert.Contains(t, updatedHTTP.Annotations, defaults.OriginalSpecAnnotationKey)
+	assert.Len(t, updatedHTTP.Spec.Rules, 2)
+
+	// A different rollout sharing the route must not restore the snapshot
+	otherRollout := rollout.DeepCopy()
+	otherRollout.UID = "other-rollout-uid"
+	otherRollout.Status.Abort = true
+	err = rpcPluginImp.SetWeight(otherRollout, 0, []v1alpha1.WeightDestination{})
+	assert.Empty(t, err.Error())
+	updatedHTTP, getErr = rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+	require.NoError(t, getErr)
+	assert.Contains(t, updatedHTTP.Annotations, defaults.OriginalSpecAnnotationKey)
+
+	rollout.Status.Abort = true
+	err = rpcPluginImp.SetWeight(rollout, 0, []v1alpha1.WeightDestination{})
+	assert.Empty(t, err.Error())
+	updatedHTTP, getErr = rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+	require.NoError(t, getErr)
+	assert.NotContains(t, updatedHTTP.Annotations, defaults.OriginalSpecAnnotationKey)
+	assert.NotContains(t, updatedHTTP.Labels, defaults.InProgressLabelKey)
+	assert.Equal(t, originalSpec, updatedHTTP.Spec)
+}
+
+// TestRestoreOriginalSpecPrunesDeletedRolloutSnapshot verifies that a snapshot left behind by
+// a rollout that no longer exists is removed instead of keeping the route from being restored.
+func TestRestoreOriginalSpecPrunesDeletedRolloutSnapshot(t *testing.T) {
+	logger, logHook := logtest.NewNullLogger()
+	tcpRoute := mocks.CreateTCPRouteWithLabels(mocks.TCPRouteName, nil)
+	originalSpec := *tcpRoute.Spec.DeepCopy()
+	tcpRoute.Annotations = map[string]string{
+		defaults.OriginalSpecAnnotationKey: `{"deleted-rollout-uid":{"rolloutNamespace":"default","rolloutName":"deleted-rollout","spec":{"parentRefs":[],"rules":[]}}}`,
+	}
+	rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+		Namespace:           mocks.RolloutNamespace,
+		TCPRoute:            mocks.TCPRouteName,
+		RestoreOriginalSpec: true,
+	})
+	rollout.UID = "rollout-uid"
+	rollout.Status.StableRS = "stable-hash"
+	rollout.Status.CurrentPodHash = "canary-hash"
+	rpcPluginImp := &RpcPlugin{
+		LogCtx:              log.NewEntry(logger),
+		GatewayAPIClientset: gwFake.NewSimpleClientset(tcpRoute),
+		RolloutClientset:    rolloutFake.NewSimpleClientset(rollout),
+	}
+
+	err := rpcPluginImp.SetWeight(rollout, 40, []v1alpha1.WeightDestination{})
+	assert.Empty(t, err.Error())
+	updatedTCP, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1alpha2().TCPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.TCPRouteName, metav1.GetOptions{})
+	require.NoError(t, getErr)
+	snapshots, snapshotErr := getRouteSpecSnapshots(updatedTCP)
+	require.NoError(t, snapshotErr)
+	require.Len(t, snapshots, 1)
+	assert.Contains(t, snapshots, rollout.UID)
+	hasWarning := false
+	for _, entry := range logHook.AllEntries() {
+		hasWarning = hasWarning || (entry.Level == log.WarnLevel && strings.Contains(entry.Message, "deleted-rollout"))
+	}
+	assert.True(t, hasWarning, "removing the snapshot of a deleted rollout must be logged")
+
+	rollout.Status.Abort = true
+	err = rpcPluginImp.SetWeight(rollout, 0, []v1alpha1.WeightDestination{})
+	assert.Empty(t, err.Error())
+	updatedTCP, getErr = rpcPluginImp.GatewayAPIClientset.GatewayV1alpha2().TCPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.TCPRouteName, metav1.GetOptions{})
+	require.NoError(t, getErr)
+	assert.NotContains(t, updatedTCP.Annotations, defaults.OriginalSpecAnnotationKey)
+	assert.Equal(t, originalSpec, updatedTCP.Spec)
+}
+
+// TestRestoreOriginalSpecSharedRoute verifies that two rollouts sharing a route keep the spec
+// from before either of them started, and that it is only restored once both are settled.
+func TestRestoreOriginalSpecSharedRoute(t *testing.T) {
+	httpRoute := mocks.CreateHTTPRouteWithLabels(mocks.HTTPRouteName, nil)
+	otherRule := httpRoute.Spec.Rules[0].DeepCopy()
+	otherRule.BackendRefs[0].Name = "other-stable-service"
+	otherRule.BackendRefs[1].Name = "other-canary-service"
+	httpRoute.Spec.Rules = append(httpRoute.Spec.Rules, *otherRule)
+	originalSpec := *httpRoute.Spec.DeepCopy()
+
+	newSharedRollout := func(name string, uid types.UID, stableService, canaryService string) *v1alpha1.Rollout {
+		rollout := newRollout(stableService, canaryService, &GatewayAPITrafficRouting{
+			Namespace:           mocks.RolloutNamespace,
+			HTTPRoute:           mocks.HTTPRouteName,
+			RestoreOriginalSpec: true,
+		})
+		rollout.Name = name
+		rollout.UID = uid
+		rollout.Status.StableRS = "stable-hash"
+		rollout.Status.CurrentPodHash = "canary-hash"
+		return rollout
+	}
+	rolloutA := newSharedRollout("rollout-a", "rollout-a-uid", mocks.StableServiceName, mocks.CanaryServiceName)
+	rolloutB := newSharedRollout("rollout-b", "rollout-b-uid", "other-stable-service", "other-canary-service")
+	rpcPluginImp := &RpcPlugin{
+		LogCtx:              utils.SetupLog("text"),
+		GatewayAPIClientset: gwFake.NewSimpleClientset(httpRoute),
+		RolloutClientset:    rolloutFake.NewSimpleClientset(rolloutA, rolloutB),
+	}
+	getHTTPRoute := func() *gatewayv1.HTTPRoute {
+		t.Helper()
+		updatedHTTP, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+		require.NoError(t, getErr)
+		return updatedHTTP
+	}
+
+	err := rpcPluginImp.SetWeight(rolloutA, 40, []v1alpha1.WeightDestination{})
+	require.Empty(t, err.Error())
+	err = rpcPluginImp.SetWeight(rolloutB, 30, []v1alpha1.WeightDestination{})
+	require.Empty(t, err.Error())
+
+	// Rollout B started while rollout A was in progress, yet its snapshot is the original spec
+	snapshots, snapshotErr := getRouteSpecSnapshots(getHTTPRoute())
+	require.NoError(t, snapshotErr)
+	require.Len(t, snapshots, 2)
+	assert.JSONEq(t, string(snapshots[rolloutA.UID].Spec), string(snapshots[rolloutB.UID].Spec))
+
+	// Rollout A ends first, the route keeps the canary of rollout B and its snapshot
+	rolloutA.Status.Abort = true
+	err = rpcPluginImp.SetWeight(rolloutA, 0, []v1alpha1.WeightDestination{})
+	require.Empty(t, err.Error())
+	updatedHTTP := getHTTPRoute()
+	assert.Equal(t, int32(0), *updatedHTTP.Spec.Rules[0].BackendRefs[1].Weight)
+	assert.Equal(t, int32(30), *updatedHTTP.Spec.Rules[1].BackendRefs[1].Weight)
+	snapshots, snapshotErr = getRouteSpecSnapshots(updatedHTTP)
+	require.NoError(t, snapshotErr)
+	require.Len(t, snapshots, 1)
+	assert.Contains(t, snapshots, rolloutB.UID)
+
+	// Once rollout B ends as well, the spec from before both rollouts is restored
+	rolloutB.Status.Abort = true
+	err = rpcPluginImp.SetWeight(rolloutB, 0, []v1alpha1.WeightDestination{})
+	require.Empty(t, err.Error())
+	updatedHTTP = getHTTPRoute()
+	assert.NotContains(t, updatedHTTP.Annotations, defaults.OriginalSpecAnnotationKey)
+	assert.Equal(t, originalSpec, updatedHTTP.Spec)
+}
+
+// TestRestoreOriginalSpecOnRemoveManagedRoutes verifies that RemoveManagedRoutes restores the
+// snapshot of every route once the rollout is settled, including routes without header routes.
+func TestRestoreOriginalSpecOnRemoveManagedRoutes(t *testing.T) {
+	httpRoute := mocks.CreateHTTPRouteWithLabels(mocks.HTTPRouteName, nil)
+	tcpRoute := mocks.CreateTCPRouteWithLabels(mocks.TCPRouteName, nil)
+	originalHTTPSpec := *httpRoute.Spec.DeepCopy()
+	originalTCPSpec := *tcpRoute.Spec.DeepCopy()
+	rpcPluginImp := &RpcPlugin{
+		LogCtx:              utils.SetupLog("text"),
+		GatewayAPIClientset: gwFake.NewSimpleClientset(httpRoute, tcpRoute),
+	}
+	rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+		Namespace:           mocks.RolloutNamespace,
+		HTTPRoute:           mocks.HTTPRouteName,
+		TCPRoute:            mocks.TCPRouteName,
+		RestoreOriginalSpec: true,
+	})
+	rollout.UID = "rollout-uid"
+	rollout.Status.StableRS = "stable-hash"
+	rollout.Status.CurrentPodHash = "canary-hash"
+
+	err := rpcPluginImp.SetWeight(rollout, 40, []v1alpha1.WeightDestination{})
+	assert.Empty(t, err.Error())
+
+	// Managed routes are removed while the canary is in progress without restoring the snapshot
+	err = rpcPluginImp.RemoveManagedRoutes(rollout)
+	assert.Empty(t, err.Error())
+	updatedTCP, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1alpha2().TCPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.TCPRouteName, metav1.GetOptions{})
+	require.NoError(t, getErr)
+	assert.Contains(t, updatedTCP.Annotations, defaults.OriginalSpecAnnotationKey)
+	assert.Equal(t, int32(40), *updatedTCP.Spec.Rules[0].BackendRefs[1].Weight)
+
+	rollout.Status.StableRS = rollout.Status.CurrentPodHash
+	err = rpcPluginImp.RemoveManagedRoutes(rollout)
+	assert.Empty(t, err.Error())
+	updatedHTTP, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+	require.NoError(t, getErr)
+	assert.NotContains(t, updatedHTTP.Annotations, defaults.OriginalSpecAnnotationKey)
+	assert.NotContains(t, updatedHTTP.Labels, defaults.InProgressLabelKey)
+	assert.Equal(t, originalHTTPSpec, updatedHTTP.Spec)
+	updatedTCP, getErr = rpcPluginImp.GatewayAPIClientset.GatewayV1alpha2().TCPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.TCPRouteName, metav1.GetOptions{})
+	require.NoError(t, getErr)
+	assert.NotContains(t, updatedTCP.Annotations, defaults.OriginalSpecAnnotationKey)
+	assert.Equal(t, originalTCPSpec, updatedTCP.Spec)
+}
+
+func TestRestoreOriginalSpecDisabledByDefault(t *testing.T) {
+	tcpRoute := mocks.CreateTCPRouteWithLabels(mocks.TCPRouteName, nil)
+	rpcPluginImp := &RpcPlugin{
+		LogCtx:              utils.SetupLog("text"),
+		GatewayAPIClientset: gwFake.NewSimpleClientset(tcpRoute),
+	}
+	rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+		Namespace: mocks.RolloutNamespace,
+		TCPRoute:  mocks.TCPRouteName,
+	})
+
+	err := rpcPluginImp.SetWeight(rollout, 40, []v1alpha1.WeightDestination{})
+	assert.Empty(t, err.Error())
+	updatedTCP, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1alpha2().TCPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.TCPRouteName, metav1.GetOptions{})
+	require.NoError(t, getErr)
+	assert.NotContains(t, updatedTCP.Annotations, defaults.OriginalSpecAnnotationKey)
+}
+
+func TestServerSideApply(t *testing.T) {
+	newPlugin := func(t *testing.T) *RpcPlugin {
+		t.Helper()
+		httpRoute := mocks.CreateHTTPRouteWithLabels(mocks.HTTPRouteName, nil)
+		httpRoute.Spec.Hostnames = []gatewayv1.Hostname{"example.com"}
+		return &RpcPlugin{
+			LogCtx:              utils.SetupLog("text"),
+			GatewayAPIClientset: gwFake.NewClientset(httpRoute),
+		}
+	}
+	newSSARollout := func(forceConflicts bool) *v1alpha1.Rollout {
+		return newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+			Namespace:       mocks.RolloutNamespace,
+			HTTPRoute:       mocks.HTTPRouteName,
+			ServerSideApply: true,
+			ForceConflicts:  forceConflicts,
+		})
+	}
+	getManagers := func(t *testing.T, route *gatewayv1.HTTPRoute) []string {
+		t.Helper()
+		managers := []string{}
+		for _, entry := range route.ManagedFields {
+			managers = append(managers, entry.Manager)
+		}
+		return managers
+	}
+
+	t.Run("ApplyWeightUnderPluginFieldManager", func(t *testing.T) {
+		rpcPluginImp := newPlugin(t)
+
+		// The route was created without server-side apply, so taking over its rules needs force
+		err := rpcPluginImp.SetWeight(newSSARollout(true), 30, []v1alpha1.WeightDestination{})
+		require.Empty(t, err.Error())
+
+		httpRoute, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+		require.NoError(t, getErr)
+		assert.Equal(t, int32(70), *httpRoute.Spec.Rules[0].BackendRefs[0].Weight)
+		assert.Equal(t, int32(30), *httpRoute.Spec.Rules[0].BackendRefs[1].Weight)
+		assert.Equal(t, []gatewayv1.Hostname{"example.com"}, httpRoute.Spec.Hostnames)
+		assert.Equal(t, defaults.InProgressLabelValue, httpRoute.Labels[defaults.InProgressLabelKey])
+		assert.Contains(t, getManagers(t, httpRoute), defaults.FieldManager)
+
+		// Once the plugin owns the rules no force is needed anymore
+		err = rpcPluginImp.SetWeight(newSSARollout(false), 0, []v1alpha1.WeightDestination{})
+		require.Empty(t, err.Error())
+		httpRoute, getErr = rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+		require.NoError(t, getErr)
+		assert.NotContains(t, httpRoute.Labels, defaults.InProgressLabelKey)
+	})
+
+	t.Run("RestoreOnlyRules", func(t *testing.T) {
+		rpcPluginImp := newPlugin(t)
+		httpRouteClient := rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace)
+		rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+			Namespace:           mocks.RolloutNamespace,
+			HTTPRoute:           mocks.HTTPRouteName,
+			ServerSideApply:     true,
+			ForceConflicts:      true,
+			RestoreOriginalSpec: true,
+		})
+		rollout.UID = "rollout-uid"
+		rollout.Status.StableRS = "stable-hash"
+		rollout.Status.CurrentPodHash = "canary-hash"
+
+		err := rpcPluginImp.SetWeight(rollout, 30, []v1alpha1.WeightDestination{})
+		require.Empty(t, err.Error())
+
+		// Another manager changes a field the plugin does not own during the canary
+		httpRoute, getErr := httpRouteClient.Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+		require.NoError(t, getErr)
+		require.Contains(t, httpRoute.Annotations, defaults.OriginalSpecAnnotationKey)
+		httpRoute.Spec.Hostnames = []gatewayv1.Hostname{"other.example.com"}
+		_, updateErr := httpRouteClient.Update(context.Background(), httpRoute, metav1.UpdateOptions{FieldManager: "gitops-controller"})
+		require.NoError(t, updateErr)
+
+		// The rules are restored while the field of the other manager is kept
+		rollout.Status.Abort = true
+		err = rpcPluginImp.SetWeight(rollout, 0, []v1alpha1.WeightDestination{})
+		require.Empty(t, err.Error())
+		httpRoute, getErr = httpRouteClient.Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+		require.NoError(t, getErr)
+		assert.Equal(t, mocks.CreateHTTPRouteWithLabels(mocks.HTTPRouteName, nil).Spec.Rules, httpRoute.Spec.Rules)
+		assert.Equal(t, []gatewayv1.Hostname{"other.example.com"}, httpRoute.Spec.Hostnames)
+		assert.NotContains(t, httpRoute.Annotations, defaults.OriginalSpecAnnotationKey)
+	})
+
+	t.Run("ReportConflictWithOtherFieldManager", func(t *testing.T) {
+		rpcPluginImp := newPlugin(t)
+		httpRouteClient := rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace)
+		httpRoute, getErr := httpRouteClient.Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+		require.NoError(t, getErr)
+		data, marshalErr := json.Marshal(newRouteApplyConfiguration(httpRoute, httpRoute.Spec.Rules, httpRouteGVK, &GatewayAPITrafficRouting{}))
+		require.NoError(t, marshalErr)
+		_, patchErr := httpRouteClient.Patch(context.Background(), mocks.HTTPRouteName, types.ApplyPatchType, data, metav1.PatchOptions{FieldManager: "gitops-controller"})
+		require.NoError(t, patchErr)
+
+		err := rpcPluginImp.SetWeight(newSSARollout(false), 30, []v1alpha1.WeightDestination{})
+		assert.Contains(t, err.Error(), "conflict with another field manager")
+
+		err = rpcPluginImp.SetWeight(newSSARollout(true), 30, []v1alpha1.WeightDestination{})
+		require.Empty(t, err.Error())
+		httpRoute, getErr = httpRouteClient.Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+		require.NoError(t, getErr)
+		assert.Equal(t, int32(30), *httpRoute.Spec.Rules[0].BackendRefs[1].Weight)
+	})
+}
+
+func TestInformerCache(t *testing.T) {
+	httpRoute := mocks.CreateHTTPRouteWithLabels(mocks.HTTPRouteName, map[string]string{"app": "cached"})
+	gatewayAPIClientset := gwFake.NewSimpleClientset(httpRoute)
+	gatewayAPIClientset.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: gatewayv1.SchemeGroupVersion.String(),
+			APIResources: []metav1.APIResource{{Name: "httproutes"}},
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cache, err := newInformerCache(ctx, kubeFake.NewSimpleClientset(), gatewayAPIClientset, "", utils.SetupLog("text"))
+	require.NoError(t, err)
+	assert.NotNil(t, cache.httpRouteLister)
+	assert.Nil(t, cache.tcpRouteLister)
+
+	rpcPluginImp := &RpcPlugin{
+		LogCtx:              utils.SetupLog("text"),
+		GatewayAPIClientset: gatewayAPIClientset,
+		cache:               cache,
+	}
+	rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+		Namespace: mocks.RolloutNamespace,
+		HTTPRouteSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{"app": "cached"},
+		},
+	})
+	gatewayAPIClientset.ClearActions()
+
+	rpcError := rpcPluginImp.SetWeight(rollout, 30, []v1alpha1.WeightDestination{})
+	require.Empty(t, rpcError.Error())
+
+	// The route was discovered and read from the cache, only the write reached the API server
+	actions := gatewayAPIClientset.Actions()
+	require.Len(t, actions, 1)
+	assert.Equal(t, "update", actions[0].GetVerb())
+
+	updatedHTTP, getErr := gatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+	require.NoError(t, getErr)
+	assert.Equal(t, int32(30), *updatedHTTP.Spec.Rules[0].BackendRefs[1].Weight)
+
+	// A namespace outside the cache is read from the API server
+	cache.namespace = "other-namespace"
+	gatewayAPIClientset.ClearActions()
+	_, getErr = rpcPluginImp.getHTTPRoute(context.Background(), mocks.RolloutNamespace, mocks.HTTPRouteName, true)
+	require.NoError(t, getErr)
+	require.Len(t, gatewayAPIClientset.Actions(), 1)
+	assert.Equal(t, "get", gatewayAPIClientset.Actions()[0].GetVerb())
+}
+
+// TestGetServiceFallsBackToAPIServer verifies that a Service missing from the informer cache,
+// such as an experiment Service created right before SetWeight, is read from the API server.
+func TestGetServiceFallsBackToAPIServer(t *testing.T) {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "experiment-service", Namespace: mocks.RolloutNamespace},
+	}
+	rpcPluginImp := &RpcPlugin{
+		LogCtx:    utils.SetupLog("text"),
+		Clientset: kubeFake.NewSimpleClientset(service),
+		cache:     &informerCache{serviceLister: coreListers.NewServiceLister(toolsCache.NewIndexer(toolsCache.MetaNamespaceKeyFunc, toolsCache.Indexers{}))},
+	}
+
+	cachedService, err := rpcPluginImp.getService(context.Background(), mocks.RolloutNamespace, "experiment-service")
+	require.NoError(t, err)
+	assert.Equal(t, "experiment-service", cachedService.Name)
+
+	_, err = rpcPluginImp.getService(context.Background(), mocks.RolloutNamespace, "missing-service")
+	assert.True(t, apierrors.IsNotFound(err))
+}
+
+func TestRemoveManagedRoutesWithStaleCache(t *testing.T) {
+	httpRoute := mocks.CreateHTTPRouteWithLabels(mocks.HTTPRouteName, nil)
+	gatewayAPIClientset := gwFake.NewSimpleClientset(httpRoute)
+	rpcPluginImp := &RpcPlugin{
+		LogCtx:              utils.SetupLog("text"),
+		GatewayAPIClientset: gatewayAPIClientset,
+	}
+	rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+		Namespace: mocks.RolloutNamespace,
+		HTTPRoute: mocks.HTTPRouteName,
+	})
+	headerMatch := v1alpha1.StringMatch{Exact: "true"}
+	rpcError := rpcPluginImp.SetHeaderRoute(rollout, &v1alpha1.SetHeaderRoute{
+		Name: mocks.ManagedRouteName,
+		Match: []v1alpha1.HeaderRoutingMatch{
+			{
+				HeaderName:  "X-Canary",
+				HeaderValue: &headerMatch,
+			},
+		},
+	})
+	require.Empty(t, rpcError.Error())
+
+	// The lister still holds the route from before the managed rule was added
+	indexer := toolsCache.NewIndexer(toolsCache.MetaNamespaceKeyFunc, toolsCache.Indexers{})
+	require.NoError(t, indexer.Add(httpRoute))
+	rpcPluginImp.cache = &informerCache{httpRouteLister: gatewayListersV1.NewHTTPRouteLister(indexer)}
+
+	rpcError = rpcPluginImp.RemoveManagedRoutes(rollout)
+	require.Empty(t, rpcError.Error())
+	updatedHTTP, getErr := gatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+	require.NoError(t, getErr)
+	assert.Len(t, updatedHTTP.Spec.Rules, 1)
+}
+
+func TestMetrics(t *testing.T) {
+	rpcPluginImp := &InstrumentedRpcPlugin{
+		RpcPlugin: &RpcPlugin{
+			LogCtx:              utils.SetupLog("text"),
+			GatewayAPIClientset: gwFake.NewSimpleClientset(&mocks.HTTPRouteObj),
+		},
+	}
+	rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+		Namespace: mocks.RolloutNamespace,
+		HTTPRoute: mocks.HTTPRouteName,
+	})
+	successCount := testutil.ToFloat64(rpcCallsTotal.WithLabelValues("SetWeight", "success"))
+	errorCount := testutil.ToFloat64(rpcCallsTotal.WithLabelValues("SetWeight", "error"))
+	routeErrorCount := testutil.ToFloat64(routeErrorsTotal.WithLabelValues("HTTPRoute", "BackendRefWasNotFoundInHTTPRouteError"))
+
+	rpcError := rpcPluginImp.SetWeight(rollout, 30, []v1alpha1.WeightDestination{})
+	require.Empty(t, rpcError.Error())
+	assert.Equal(t, successCount+1, testutil.ToFloat64(rpcCallsTotal.WithLabelValues("SetWeight", "success")))
+	assert.Equal(t, float64(30), testutil.ToFloat64(canaryWeight.WithLabelValues(rollout.Namespace, rollout.Name, "HTTPRoute", mocks.RolloutNamespace, mocks.HTTPRouteName)))
+	// Once the rollout is done with its routes their weight is not reported anymore
+	rpcError = rpcPluginImp.RemoveManagedRoutes(rollout)
+	require.Empty(t, rpcError.Error())
+	assert.False(t, canaryWeight.DeleteLabelValues(rollout.Namespace, rollout.Name, "HTTPRoute", mocks.RolloutNamespace, mocks.HTTPRouteName))
+
+	rollout = newRollout(mocks.StableServiceName, "missing-canary-service", &GatewayAPITrafficRouting{
+		Namespace: mocks.RolloutNamespace,
+		HTTPRoute: mocks.HTTPRouteName,
+	})
+	rpcError = rpcPluginImp.SetWeight(rollout, 30, []v1alpha1.WeightDestination{})
+	require.NotEmpty(t, rpcError.Error())
+	assert.Equal(t, errorCount+1, testutil.ToFloat64(rpcCallsTotal.WithLabelValues("SetWeight", "error")))
+	assert.Equal(t, routeErrorCount+1, testutil.ToFloat64(routeErrorsTotal.WithLabelValues("HTTPRoute", "BackendRefWasNotFoundInHTTPRouteError")))
+	assert.Equal(t, "FieldManagerConflictError", getErrorReason(fmt.Errorf("applying route: %w", newPluginError("FieldManagerConflictError", FieldManagerConflictError, "route", "conflict"))))
+	assert.Equal(t, "Other", getErrorReason(errors.New("connection refused")))
+}
+
+func TestErrorReasons(t *testing.T) {
+	// Every plugin error is raised with the name of its message constant as reason
+	packages, err := parser.ParseDir(token.NewFileSet(), ".", func(info fs.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	require.NoError(t, err)
+	callCount := 0
+	for _, file := range packages["plugin"].Files {
+		ast.Inspect(file, func(node ast.Node) bool {
+			call, ok := node.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			if function, ok := call.Fun.(*ast.Ident); !ok || function.Name != "newPluginError" {
+				return true
+			}
+			callCount++
+			require.GreaterOrEqual(t, len(call.Args), 2)
+			reason, isReasonLiteral := call.Args[0].(*ast.BasicLit)
+			message, isMessageConstant := call.Args[1].(*ast.Ident)
+			require.True(t, isReasonLiteral && isMessageConstant, "newPluginError must be called with a reason literal and a message constant")
+			assert.Equal(t, strconv.Quote(message.Name), reason.Value)
+			return true
+		})
+	}
+	assert.Positive(t, callCount)
+}
+
+func TestSetManagedRouteSkipsUnchangedRoute(t *testing.T) {
+	gatewayAPIClientset := gwFake.NewSimpleClientset(mocks.HTTPRouteObj.DeepCopy())
+	rpcPluginImp := &RpcPlugin{
+		LogCtx:              utils.SetupLog("text"),
+		GatewayAPIClientset: gatewayAPIClientset,
+	}
+	rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+		Namespace: mocks.RolloutNamespace,
+		HTTPRoute: mocks.HTTPRouteName,
+	})
+	rollout.Spec.Strategy.Canary.TrafficRouting.ManagedRoutes = []v1alpha1.MangedRoutes{{Name: mocks.ManagedRouteName}, {Name: "mirror-route"}}
+	headerRouting := &v1alpha1.SetHeaderRoute{
+		Name:  mocks.ManagedRouteName,
+		Match: []v1alpha1.HeaderRoutingMatch{{HeaderName: "X-Test", HeaderValue: &v1alpha1.StringMatch{Exact: "test"}}},
+	}
+	mirrorRoute := &v1alpha1.SetMirrorRoute{
+		Name:  "mirror-route",
+		Match: []v1alpha1.RouteMatch{{Method: &v1alpha1.StringMatch{Exact: "GET"}}},
+	}
+	getUpdateCount := func() int {
+		updateCount := 0
+		for _, action := range gatewayAPIClientset.Actions() {
+			if action.GetVerb() == "update" {
+				updateCount++
+			}
+		}
+		return updateCount
+	}
+
+	rpcError := rpcPluginImp.SetHeaderRoute(rollout, headerRouting)
+	require.Empty(t, rpcError.Error())
+	rpcError = rpcPluginImp.SetMirrorRoute(rollout, mirrorRoute)
+	require.Empty(t, rpcError.Error())
+	assert.Equal(t, 2, getUpdateCount())
+
+	// Setting the same header and mirror routes again leaves the route untouched
+	gatewayAPIClientset.ClearActions()
+	rpcError = rpcPluginImp.SetHeaderRoute(rollout, headerRouting)
+	require.Empty(t, rpcError.Error())
+	rpcError = rpcPluginImp.SetMirrorRoute(rollout, mirrorRoute)
+	require.Empty(t, rpcError.Error())
+	assert.Equal(t, 0, getUpdateCount())
+	updatedHTTP, getErr := gatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+	require.NoError(t, getErr)
+	assert.Len(t, updatedHTTP.Spec.Rules, 3)
+}
+
+func TestRouteEvents(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	rpcPluginImp := &RpcPlugin{
+		LogCtx:              utils.SetupLog("text"),
+		GatewayAPIClientset: gwFake.NewSimpleClientset(&mocks.HTTPRouteObj),
+		recorder:            recorder,
+	}
+	rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
+		Namespace: mocks.RolloutNamespace,
+		HTTPRoute: mocks.HTTPRouteName,
+	})
+
+	rpcError := rpcPluginImp.SetWeight(rollout, 30, []v1alpha1.WeightDestination{})
+	require.Empty(t, rpcError.Error())
+	assert.Equal(t, `Normal WeightUpdated Set weight of canary service "argo-rollouts-canary-service" to 30 and stable service "argo-rollouts-stable-service" to 70`, <-recorder.Events)
+
+	// Setting the same weight again does not change the route, so no Event is recorded
+	rpcError = rpcPluginImp.SetWeight(rollout, 30, []v1alpha1.WeightDestination{})
+	require.Empty(t, rpcError.Error())
+	assert.Empty(t, recorder.Events)
+
+	rpcError = rpcPluginImp.SetHeaderRoute(rollout, &v1alpha1.SetHeaderRoute{
+		Name: mocks.ManagedRouteName,
+		Match: []v1alpha1.HeaderRoutingMatch{
+			{HeaderName: "X-Canary", HeaderValue: &v1alpha1.StringMatch{Exact: "true"}},
+		},
+	})
+	require.Empty(t, rpcError.Error())
+	assert.Equal(t, fmt.Sprintf("Normal HeaderRouteAdded Added 1 rules for header route %q", mocks.ManagedRouteName), <-recorder.Events)
+
+	rpcError = rpcPluginImp.RemoveManagedRoutes(rollout)
+	require.Empty(t, rpcError.Error())
+	assert.Equal(t, "Normal ManagedRoutesRemoved Removed 1 managed rules", <-recorder.Events)
+
+	rollout = newRollout(mocks.StableServiceName, "missing-canary-service", &GatewayAPITrafficRouting{
+		Namespace: mocks.RolloutNamespace,
+		HTTPRoute: mocks.HTTPRouteName,
+	})
+	rpcError = rpcPluginImp.SetWeight(rollout, 30, []v1alpha1.WeightDestination{})
+	require.NotEmpty(t, rpcError.Error())
+	assert.Equal(t, "Warning UpdateFailed SetWeight failed: "+BackendRefWasNotFoundInHTTPRouteError, <-recorder.Events)
+
+	// Events name the backends of the route, which canaryBackendRef may rename
+	rpcPluginImp.GatewayAPIClientset = gwFake.NewSimpleClientset(mocks.TCPPRouteObj.DeepCopy())
+	rollout = newRollout(mocks.StableServiceName, "canary-preview", &GatewayAPITrafficRouting{
+		Namespace:        mocks.RolloutNamespace,
+		TCPRoute:         mocks.TCPRouteName,
+		CanaryBackendRef: &BackendRefMatch{Name: mocks.CanaryServiceName},
+	})
+	rpcError = rpcPluginImp.SetWeight(rollout, 30, []v1alpha1.WeightDestination{})
+	require.Empty(t, rpcError.Error())
+	assert.Equal(t, `Normal WeightUpdated Set weight of canary service "argo-rollouts-canary-service" to 30 and stable service "argo-rollouts-stable-service" to 70`, <-recorder.Events)
+}
+
+func TestDryRun(t *testing.T) {
+	logger, logHook := logtest.NewNullLogger()
+	gatewayAPIClientset := gwFake.NewSimpleClientset(&mocks.HTTPRouteObj)
+	rpcPluginImp := &RpcPlugin{
+		LogCtx:              log.NewEntry(logger),
+		GatewayAPIClientset: gatewayAPIClientset,
 	}
 	rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
 		Namespace: mocks.RolloutNamespace,
 		HTTPRoute: mocks.HTTPRouteName,
+		DryRun:    true,
 	})
+	rollout.Name = "dry-run-rollout"
 
-	rpcErr := rpcPluginImp.SetWeight(rollout, 30, []v1alpha1.WeightDestination{})
-	assert.Empty(t, rpcErr.Error())
+	rpcError := rpcPluginImp.SetWeight(rollout, 30, []v1alpha1.WeightDestination{})
+	require.Empty(t, rpcError.Error())
+	assert.Equal(t, float64(0), testutil.ToFloat64(canaryWeight.WithLabelValues(rollout.Namespace, rollout.Name, "HTTPRoute", mocks.RolloutNamespace, mocks.HTTPRouteName)))
+	rpcError = rpcPluginImp.SetHeaderRoute(rollout, &v1alpha1.SetHeaderRoute{
+		Name: mocks.ManagedRouteName,
+		Match: []v1alpha1.HeaderRoutingMatch{
+			{HeaderName: "X-Canary", HeaderValue: &v1alpha1.StringMatch{Exact: "true"}},
+		},
+	})
+	require.Empty(t, rpcError.Error())
+	verified, rpcError := rpcPluginImp.VerifyWeight(rollout, 30, []v1alpha1.WeightDestination{})
+	require.Empty(t, rpcError.Error())
+	assert.Equal(t, pluginTypes.Verified, verified)
 
-	updated, err := rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+	for _, action := range gatewayAPIClientset.Actions() {
+		assert.Equal(t, "get", action.GetVerb())
+	}
+	httpRoute, err := gatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
 	require.NoError(t, err)
-	require.Len(t, updated.Spec.Rules[0].BackendRefs, 3, "extra backend must not be stripped during canary")
-	assert.Equal(t, int32(70), *updated.Spec.Rules[0].BackendRefs[0].Weight)
-	assert.Equal(t, int32(30), *updated.Spec.Rules[0].BackendRefs[1].Weight)
-	assert.Equal(t, gatewayv1.ObjectName(extraServiceName), updated.Spec.Rules[0].BackendRefs[2].Name)
-
-	rpcErr = rpcPluginImp.SetWeight(rollout, 0, []v1alpha1.WeightDestination{})
-	assert.Empty(t, rpcErr.Error())
+	assert.Equal(t, mocks.HTTPRouteObj.Spec, httpRoute.Spec)
+	assert.NotContains(t, httpRoute.Labels, defaults.InProgressLabelKey)
 
-	updated, err = rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
-	require.NoError(t, err)
-	require.Len(t, updated.Spec.Rules[0].BackendRefs, 3, "extra backend must not be stripped after rollout completes")
-	assert.Equal(t, int32(0), *updated.Spec.Rules[0].BackendRefs[1].Weight)
-	assert.Equal(t, gatewayv1.ObjectName(extraServiceName), updated.Spec.Rules[0].BackendRefs[2].Name)
+	var dryRunEntries []*log.Entry
+	for _, entry := range logHook.AllEntries() {
+		if entry.Data["dryRun"] == true {
+			dryRunEntries = append(dryRunEntries, entry)
+		}
+	}
+	require.Len(t, dryRunEntries, 2)
+	assert.Equal(t, "HTTPRoute", dryRunEntries[0].Data["kind"])
+	assert.Equal(t, mocks.HTTPRouteName, dryRunEntries[0].Data["name"])
+	specDiff := map[string]any{}
+	require.NoError(t, json.Unmarshal([]byte(dryRunEntries[0].Data["specDiff"].(string)), &specDiff))
+	assert.Contains(t, specDiff, "rules")
 }
 
-// TestGetRouteRuleReturnsErrorWhenBackendNotFound verifies that getRouteRule returns
-// an error when no rule contains all requested backends.
-func TestGetRouteRuleReturnsErrorWhenBackendNotFound(t *testing.T) {
-	// Create a route with only header routes (single backend each)
-	canaryName := gatewayv1.SectionName("header-route")
-
-	httpRoute := &gatewayv1.HTTPRoute{
+func TestL4RouteExperiment(t *testing.T) {
+	experimentService := &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "test-route",
-			Namespace: "default",
+			Name:      "experiment-service",
+			Namespace: mocks.RolloutNamespace,
 		},
-		Spec: gatewayv1.HTTPRouteSpec{
-			Rules: []gatewayv1.HTTPRouteRule{
-				{
-					Name: &canaryName,
-					BackendRefs: []gatewayv1.HTTPBackendRef{
-						{
-							BackendRef: gatewayv1.BackendRef{
-								BackendObjectReference: gatewayv1.BackendObjectReference{
-									Name: "argo-rollouts-canary-service",
-								},
-							},
-						},
-					},
-				},
-			},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{{Name: "tcp", Port: 80}, {Name: "postgres", Port: 5432}},
 		},
 	}
+	additionalDestinations := []v1alpha1.WeightDestination{
+		{
+			ServiceName: experimentService.Name,
+			Weight:      10,
+		},
+	}
+	newExperimentRollout := func(config *GatewayAPITrafficRouting) *v1alpha1.Rollout {
+		rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, config)
+		rollout.Status.Canary.CurrentExperiment = "experiment"
+		return rollout
+	}
 
-	httpRouteRuleList := HTTPRouteRuleList(httpRoute.Spec.Rules)
+	t.Run("TCPRoute", func(t *testing.T) {
+		rpcPluginImp := &RpcPlugin{
+			LogCtx:              utils.SetupLog("text"),
+			Clientset:           kubeFake.NewSimpleClientset(experimentService),
+			GatewayAPIClientset: gwFake.NewSimpleClientset(mocks.TCPPRouteObj.DeepCopy()),
+		}
+		rollout := newExperimentRollout(&GatewayAPITrafficRouting{
+			Namespace: mocks.RolloutNamespace,
+			TCPRoute:  mocks.TCPRouteName,
+		})
 
-	// Test: Search for rule with both backends (only canary exists)
-	backendRefNameList := []string{"argo-rollouts-canary-service", "argo-rollouts-stable-service"}
-	httpRouteRule, err := getRouteRule(httpRouteRuleList, backendRefNameList...)
+		rpcError := rpcPluginImp.SetWeight(rollout, 20, additionalDestinations)
+		require.Empty(t, rpcError.Error())
+		tcpRoute, err := rpcPluginImp.GatewayAPIClientset.GatewayV1alpha2().TCPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.TCPRouteName, metav1.GetOptions{})
+		require.NoError(t, err)
+		backendRefs := tcpRoute.Spec.Rules[0].BackendRefs
+		require.Len(t, backendRefs, 3)
+		assert.Equal(t, int32(70), *backendRefs[0].Weight)
+		assert.Equal(t, int32(20), *backendRefs[1].Weight)
+		assert.Equal(t, gatewayv1.ObjectName(experimentService.Name), backendRefs[2].Name)
+		assert.Equal(t, int32(10), *backendRefs[2].Weight)
+		// The experiment backend uses the port of the canary backendRef by default
+		assert.Equal(t, *backendRefs[1].Port, *backendRefs[2].Port)
+
+		rollout.Status.Canary.CurrentExperiment = ""
+		rollout.Status.Canary.Weights = &v1alpha1.TrafficWeights{Additional: additionalDestinations}
+		rpcError = rpcPluginImp.SetWeight(rollout, 20, nil)
+		require.Empty(t, rpcError.Error())
+		tcpRoute, err = rpcPluginImp.GatewayAPIClientset.GatewayV1alpha2().TCPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.TCPRouteName, metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Len(t, tcpRoute.Spec.Rules[0].BackendRefs, 2)
+	})
 
-	// Assert: Should return error because no rule has both backends
-	require.Error(t, err, "Should return error when backend not found")
-	assert.Nil(t, httpRouteRule, "Route rule should be nil on error")
-	assert.Equal(t, BackendRefWasNotFoundInHTTPRouteError, err.Error())
-}
+	t.Run("TCPRouteMultiRule", func(t *testing.T) {
+		tcpRoute := mocks.TCPPRouteObj.DeepCopy()
+		tcpRoute.Spec.Rules = append(tcpRoute.Spec.Rules, *tcpRoute.Spec.Rules[0].DeepCopy())
+		rpcPluginImp := &RpcPlugin{
+			LogCtx:              utils.SetupLog("text"),
+			Clientset:           kubeFake.NewSimpleClientset(experimentService),
+			GatewayAPIClientset: gwFake.NewSimpleClientset(tcpRoute),
+		}
+		rollout := newExperimentRollout(&GatewayAPITrafficRouting{
+			Namespace: mocks.RolloutNamespace,
+			TCPRoute:  mocks.TCPRouteName,
+		})
 
-// TestSetHTTPHeaderRouteMultiRuleHTTPRoute verifies that SetHeaderRoute injects one managed
-// header rule per source rule on a multi-rule HTTPRoute (issue #207).
-//
-// A two-rule HTTPRoute with paths /a and /b should produce two managed header rules after
-// SetHeaderRoute — one covering /a and one covering /b — so the canary header applies to the
-// entire route, not just its first rule.
-func TestSetHTTPHeaderRouteMultiRuleHTTPRoute(t *testing.T) {
-	port := gatewayv1.PortNumber(80)
-	stableWeight := int32(100)
-	canaryWeight := int32(0)
-	pathPrefixType := gatewayv1.PathMatchPathPrefix
-	pathA := "/a"
-	pathB := "/b"
+		rpcError := rpcPluginImp.SetWeight(rollout, 20, additionalDestinations)
+		require.Empty(t, rpcError.Error())
+		updatedTCP, err := rpcPluginImp.GatewayAPIClientset.GatewayV1alpha2().TCPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.TCPRouteName, metav1.GetOptions{})
+		require.NoError(t, err)
+		// Every rule gives the experiment service the share taken from its stable service
+		for _, rule := range updatedTCP.Spec.Rules {
+			require.Len(t, rule.BackendRefs, 3)
+			assert.Equal(t, int32(70), *rule.BackendRefs[0].Weight)
+			assert.Equal(t, gatewayv1.ObjectName(experimentService.Name), rule.BackendRefs[2].Name)
+			assert.Equal(t, int32(10), *rule.BackendRefs[2].Weight)
+		}
+	})
 
-	httpRoute := &gatewayv1.HTTPRoute{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      mocks.HTTPRouteName,
+	t.Run("TLSRoute", func(t *testing.T) {
+		rpcPluginImp := &RpcPlugin{
+			LogCtx:              utils.SetupLog("text"),
+			Clientset:           kubeFake.NewSimpleClientset(experimentService),
+			GatewayAPIClientset: gwFake.NewSimpleClientset(mocks.TLSRouteObj.DeepCopy()),
+		}
+		rollout := newExperimentRollout(&GatewayAPITrafficRouting{
 			Namespace: mocks.RolloutNamespace,
-		},
-		Spec: gatewayv1.HTTPRouteSpec{
-			Rules: []gatewayv1.HTTPRouteRule{
-				{
-					BackendRefs: []gatewayv1.HTTPBackendRef{
-						{BackendRef: gatewayv1.BackendRef{BackendObjectReference: gatewayv1.BackendObjectReference{Name: mocks.StableServiceName, Port: &port}, Weight: &stableWeight}},
-						{BackendRef: gatewayv1.BackendRef{BackendObjectReference: gatewayv1.BackendObjectReference{Name: mocks.CanaryServiceName, Port: &port}, Weight: &canaryWeight}},
-					},
-					Matches: []gatewayv1.HTTPRouteMatch{{Path: &gatewayv1.HTTPPathMatch{Type: &pathPrefixType, Value: &pathA}}},
-				},
+			TLSRoutes: []TLSRoute{
 				{
-					BackendRefs: []gatewayv1.HTTPBackendRef{
-						{BackendRef: gatewayv1.BackendRef{BackendObjectReference: gatewayv1.BackendObjectReference{Name: mocks.StableServiceName, Port: &port}, Weight: &stableWeight}},
-						{BackendRef: gatewayv1.BackendRef{BackendObjectReference: gatewayv1.BackendObjectReference{Name: mocks.CanaryServiceName, Port: &port}, Weight: &canaryWeight}},
-					},
-					Matches: []gatewayv1.HTTPRouteMatch{{Path: &gatewayv1.HTTPPathMatch{Type: &pathPrefixType, Value: &pathB}}},
+					Name:           mocks.TLSRouteName,
+					ExperimentPort: &ExperimentPort{Name: "postgres"},
 				},
 			},
-		},
-	}
+		})
 
-	rpcPluginImp := &RpcPlugin{
-		LogCtx:              utils.SetupLog("text"),
-		GatewayAPIClientset: gwFake.NewSimpleClientset(httpRoute),
-	}
-	rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
-		Namespace: mocks.RolloutNamespace,
-		HTTPRoute: mocks.HTTPRouteName,
+		rpcError := rpcPluginImp.SetWeight(rollout, 20, additionalDestinations)
+		require.Empty(t, rpcError.Error())
+		tlsRoute, err := rpcPluginImp.GatewayAPIClientset.GatewayV1alpha2().TLSRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.TLSRouteName, metav1.GetOptions{})
+		require.NoError(t, err)
+		backendRefs := tlsRoute.Spec.Rules[0].BackendRefs
+		require.Len(t, backendRefs, 3)
+		assert.Equal(t, int32(70), *backendRefs[0].Weight)
+		assert.Equal(t, gatewayv1.ObjectName(experimentService.Name), backendRefs[2].Name)
+		assert.Equal(t, gatewayv1.PortNumber(5432), *backendRefs[2].Port)
+
+		rollout.Status.Canary.CurrentExperiment = ""
+		rollout.Status.Canary.Weights = &v1alpha1.TrafficWeights{Additional: additionalDestinations}
+		rpcError = rpcPluginImp.SetWeight(rollout, 20, nil)
+		require.Empty(t, rpcError.Error())
+		tlsRoute, err = rpcPluginImp.GatewayAPIClientset.GatewayV1alpha2().TLSRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.TLSRouteName, metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Len(t, tlsRoute.Spec.Rules[0].BackendRefs, 2)
 	})
 
-	exactMatch := v1alpha1.StringMatch{Exact: "v2"}
-	headerRouting := v1alpha1.SetHeaderRoute{
-		Name: mocks.ManagedRouteName,
-		Match: []v1alpha1.HeaderRoutingMatch{
-			{HeaderName: "X-Version", HeaderValue: &exactMatch},
-		},
-	}
-
-	err := rpcPluginImp.SetHeaderRoute(rollout, &headerRouting)
-	assert.Empty(t, err.Error())
-
-	updated, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
-	require.NoError(t, getErr)
-
-	// Expect 4 rules: 2 original source rules + 1 managed header rule per source rule.
-	assert.Len(t, updated.Spec.Rules, 4, "expected one managed header rule per source rule (2 source + 2 managed = 4 total)")
-
-	// Managed rules are identified by name: index 0 gets the bare managed name,
-	// subsequent rules get managedName-N (Gateway API requires unique names per route).
-	managedRules := []gatewayv1.HTTPRouteRule{}
-	for _, rule := range updated.Spec.Rules {
-		if rule.Name != nil && strings.HasPrefix(string(*rule.Name), mocks.ManagedRouteName) {
-			managedRules = append(managedRules, rule)
+	t.Run("MissingExperimentPort", func(t *testing.T) {
+		gatewayAPIClientset := gwFake.NewSimpleClientset(mocks.TCPPRouteObj.DeepCopy())
+		rpcPluginImp := &RpcPlugin{
+			LogCtx:              utils.SetupLog("text"),
+			Clientset:           kubeFake.NewSimpleClientset(experimentService),
+			GatewayAPIClientset: gatewayAPIClientset,
 		}
-	}
-	assert.Len(t, managedRules, 2, "expected two managed header rules (one per source path)")
+		rollout := newExperimentRollout(&GatewayAPITrafficRouting{
+			Namespace:      mocks.RolloutNamespace,
+			TCPRoute:       mocks.TCPRouteName,
+			ExperimentPort: &ExperimentPort{Number: 8080},
+		})
 
-	// Each managed rule must carry the canary header and preserve its source path prefix.
-	paths := map[string]bool{}
-	for _, rule := range managedRules {
-		require.Len(t, rule.BackendRefs, 1, "managed rule must have exactly one (canary) backend ref")
-		assert.Equal(t, gatewayv1.ObjectName(mocks.CanaryServiceName), rule.BackendRefs[0].Name)
-		require.NotEmpty(t, rule.Matches)
-		require.NotNil(t, rule.Matches[0].Path)
-		paths[*rule.Matches[0].Path.Value] = true
-		hasCanaryHeader := false
-		for _, h := range rule.Matches[0].Headers {
-			if string(h.Name) == "X-Version" && h.Value == "v2" {
-				hasCanaryHeader = true
-			}
-		}
-		assert.True(t, hasCanaryHeader, "managed rule for path %s must carry the canary header", *rule.Matches[0].Path.Value)
-	}
-	assert.True(t, paths["/a"], "expected a managed header rule covering path /a")
-	assert.True(t, paths["/b"], "expected a managed header rule covering path /b")
+		rpcError := rpcPluginImp.SetWeight(rollout, 20, additionalDestinations)
+		assert.Equal(t, fmt.Sprintf(ExperimentServicePortWasNotFoundError, experimentService.Name, "8080"), rpcError.Error())
+		tcpRoute, err := gatewayAPIClientset.GatewayV1alpha2().TCPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.TCPRouteName, metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Len(t, tcpRoute.Spec.Rules[0].BackendRefs, 2)
+	})
 }
 
-// TestSetGRPCHeaderRouteMultiRuleGRPCRoute verifies that SetHeaderRoute injects one managed
-// header rule per source rule on a multi-rule GRPCRoute (issue #207).
-//
-// A two-rule GRPCRoute with different gRPC methods should produce two managed header rules —
-// one per method — so the canary header applies to the entire route, not just its first rule.
-func TestSetGRPCHeaderRouteMultiRuleGRPCRoute(t *testing.T) {
+// TestHTTPRouteExperimentMultiRule verifies that experiment services are added to and removed
+// from every rule of a multi-rule HTTPRoute whose weights the plugin sets, while managed header
+// rules are left untouched.
+func TestHTTPRouteExperimentMultiRule(t *testing.T) {
 	port := gatewayv1.PortNumber(80)
 	stableWeight := int32(100)
 	canaryWeight := int32(0)
-	methodMatchType := gatewayv1.GRPCMethodMatchExact
-	serviceA := "svc.Service"
-	methodA := "MethodA"
-	serviceB := "svc.Service"
-	methodB := "MethodB"
-
-	grpcRoute := &gatewayv1.GRPCRoute{
+	pathPrefixType := gatewayv1.PathMatchPathPrefix
+	httpRoute := &gatewayv1.HTTPRoute{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      mocks.GRPCRouteName,
+			Name:      mocks.HTTPRouteName,
 			Namespace: mocks.RolloutNamespace,
 		},
-		Spec: gatewayv1.GRPCRouteSpec{
-			Rules: []gatewayv1.GRPCRouteRule{
-				{
-					BackendRefs: []gatewayv1.GRPCBackendRef{
-						{BackendRef: gatewayv1.BackendRef{BackendObjectReference: gatewayv1.BackendObjectReference{Name: mocks.StableServiceName, Port: &port}, Weight: &stableWeight}},
-						{BackendRef: gatewayv1.BackendRef{BackendObjectReference: gatewayv1.BackendObjectReference{Name: mocks.CanaryServiceName, Port: &port}, Weight: &canaryWeight}},
-					},
-					Matches: []gatewayv1.GRPCRouteMatch{{Method: &gatewayv1.GRPCMethodMatch{Type: &methodMatchType, Service: &serviceA, Method: &methodA}}},
-				},
-				{
-					BackendRefs: []gatewayv1.GRPCBackendRef{
-						{BackendRef: gatewayv1.BackendRef{BackendObjectReference: gatewayv1.BackendObjectReference{Name: mocks.StableServiceName, Port: &port}, Weight: &stableWeight}},
-						{BackendRef: gatewayv1.BackendRef{BackendObjectReference: gatewayv1.BackendObjectReference{Name: mocks.CanaryServiceName, Port: &port}, Weight: &canaryWeight}},
-					},
-					Matches: []gatewayv1.GRPCRouteMatch{{Method: &gatewayv1.GRPCMethodMatch{Type: &methodMatchType, Service: &serviceB, Method: &methodB}}},
-				},
+	}
+	for _, path := range []string{"/api", "/static", "/ws"} {
+		httpRoute.Spec.Rules = append(httpRoute.Spec.Rules, gatewayv1.HTTPRouteRule{
+			BackendRefs: []gatewayv1.HTTPBackendRef{
+				{BackendRef: gatewayv1.BackendRef{BackendObjectReference: gatewayv1.BackendObjectReference{Name: mocks.StableServiceName, Port: &port}, Weight: &stableWeight}},
+				{BackendRef: gatewayv1.BackendRef{BackendObjectReference: gatewayv1.BackendObjectReference{Name: mocks.CanaryServiceName, Port: &port}, Weight: &canaryWeight}},
 			},
+			Matches: []gatewayv1.HTTPRouteMatch{{Path: &gatewayv1.HTTPPathMatch{Type: &pathPrefixType, Value: &path}}},
+		})
+	}
+	experimentService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "experiment-service",
+			Namespace: mocks.RolloutNamespace,
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{{Name: "http", Port: 80}},
 		},
 	}
-
 	rpcPluginImp := &RpcPlugin{
 		LogCtx:              utils.SetupLog("text"),
-		GatewayAPIClientset: gwFake.NewSimpleClientset(grpcRoute),
+		Clientset:           kubeFake.NewSimpleClientset(experimentService),
+		GatewayAPIClientset: gwFake.NewSimpleClientset(httpRoute),
 	}
 	rollout := newRollout(mocks.StableServiceName, mocks.CanaryServiceName, &GatewayAPITrafficRouting{
 		Namespace: mocks.RolloutNamespace,
-		GRPCRoute: mocks.GRPCRouteName,
+		HTTPRoute: mocks.HTTPRouteName,
 	})
+	rollout.Status.Canary.CurrentExperiment = "experiment"
+	additionalDestinations := []v1alpha1.WeightDestination{
+		{
+			ServiceName: experimentService.Name,
+			Weight:      10,
+		},
+	}
 
-	exactMatch := v1alpha1.StringMatch{Exact: "v2"}
-	headerRouting := v1alpha1.SetHeaderRoute{
+	rpcError := rpcPluginImp.SetHeaderRoute(rollout, &v1alpha1.SetHeaderRoute{
 		Name: mocks.ManagedRouteName,
 		Match: []v1alpha1.HeaderRoutingMatch{
-			{HeaderName: "X-Version", HeaderValue: &exactMatch},
+			{HeaderName: "X-Canary", HeaderValue: &v1alpha1.StringMatch{Exact: "true"}},
 		},
-	}
-
-	err := rpcPluginImp.SetHeaderRoute(rollout, &headerRouting)
-	assert.Empty(t, err.Error())
-
-	updated, getErr := rpcPluginImp.GatewayAPIClientset.GatewayV1().GRPCRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.GRPCRouteName, metav1.GetOptions{})
-	require.NoError(t, getErr)
-
-	// Expect 4 rules: 2 original source rules + 1 managed header rule per source rule.
-	assert.Len(t, updated.Spec.Rules, 4, "expected one managed header rule per source rule (2 source + 2 managed = 4 total)")
+	})
+	require.Empty(t, rpcError.Error())
+	rpcError = rpcPluginImp.SetWeight(rollout, 20, additionalDestinations)
+	require.Empty(t, rpcError.Error())
 
-	// Managed rules are identified by name: index 0 gets the bare managed name,
-	// subsequent rules get managedName-N (Gateway API requires unique names per route).
-	managedRules := []gatewayv1.GRPCRouteRule{}
-	for _, rule := range updated.Spec.Rules {
-		if rule.Name != nil && strings.HasPrefix(string(*rule.Name), mocks.ManagedRouteName) {
-			managedRules = append(managedRules, rule)
-		}
+	updatedHTTP, err := rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Len(t, updatedHTTP.Spec.Rules, 6)
+	for _, rule := range updatedHTTP.Spec.Rules[:3] {
+		require.Len(t, rule.BackendRefs, 3)
+		assert.Equal(t, int32(70), *rule.BackendRefs[0].Weight)
+		assert.Equal(t, int32(20), *rule.BackendRefs[1].Weight)
+		assert.Equal(t, gatewayv1.ObjectName(experimentService.Name), rule.BackendRefs[2].Name)
+		assert.Equal(t, int32(10), *rule.BackendRefs[2].Weight)
+		assert.Equal(t, port, *rule.BackendRefs[2].Port)
+	}
+	for _, rule := range updatedHTTP.Spec.Rules[3:] {
+		assert.Len(t, rule.BackendRefs, 1)
 	}
-	assert.Len(t, managedRules, 2, "expected two managed header rules (one per source method)")
 
-	// Each managed rule must carry the canary header and preserve its source method.
-	methods := map[string]bool{}
-	for _, rule := range managedRules {
-		require.Len(t, rule.BackendRefs, 1, "managed rule must have exactly one (canary) backend ref")
-		assert.Equal(t, gatewayv1.ObjectName(mocks.CanaryServiceName), rule.BackendRefs[0].Name)
-		require.NotEmpty(t, rule.Matches)
-		require.NotNil(t, rule.Matches[0].Method)
-		require.NotNil(t, rule.Matches[0].Method.Method)
-		methods[*rule.Matches[0].Method.Method] = true
-		hasCanaryHeader := false
-		for _, h := range rule.Matches[0].Headers {
-			if string(h.Name) == "X-Version" && h.Value == "v2" {
-				hasCanaryHeader = true
-			}
-		}
-		assert.True(t, hasCanaryHeader, "managed rule for method %s must carry the canary header", *rule.Matches[0].Method.Method)
+	rollout.Status.Canary.CurrentExperiment = ""
+	rollout.Status.Canary.Weights = &v1alpha1.TrafficWeights{Additional: additionalDestinations}
+	rpcError = rpcPluginImp.SetWeight(rollout, 20, nil)
+	require.Empty(t, rpcError.Error())
+
+	updatedHTTP, err = rpcPluginImp.GatewayAPIClientset.GatewayV1().HTTPRoutes(mocks.RolloutNamespace).Get(context.Background(), mocks.HTTPRouteName, metav1.GetOptions{})
+	require.NoError(t, err)
+	for _, rule := range updatedHTTP.Spec.Rules[:3] {
+		require.Len(t, rule.BackendRefs, 2)
+		assert.Equal(t, int32(80), *rule.BackendRefs[0].Weight)
+		assert.Equal(t, int32(20), *rule.BackendRefs[1].Weight)
 	}
-	assert.True(t, methods["MethodA"], "expected a managed header rule covering MethodA")
-	assert.True(t, methods["MethodB"], "expected a managed header rule covering MethodB")
 }
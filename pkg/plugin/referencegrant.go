@@ -0,0 +1,99 @@
+package plugin
+
+import (
+	"context"
+
+	"github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+// crossNamespaceRoute is a route outside of the rollout namespace, whose backendRefs to the
+// stable, canary and experiment Services need a ReferenceGrant.
+type crossNamespaceRoute struct {
+	kind      string
+	namespace string
+	name      string
+}
+
+// checkReferenceGrants returns an error for the first route outside of the rollout namespace
+// that no ReferenceGrant in the rollout namespace allows to reference the stable and canary
+// backends, or the experiment services of additionalDestinations the plugin adds to it.
+// Gateway controllers do not resolve such backendRefs, so the route would not send any
+// traffic to the rollout.
+func (r *RpcPlugin) checkReferenceGrants(ctx context.Context, rollout *v1alpha1.Rollout, additionalDestinations []v1alpha1.WeightDestination, gatewayAPIConfig *GatewayAPITrafficRouting) error {
+	var routes []crossNamespaceRoute
+	routes = appendCrossNamespaceRoutes(routes, gatewayAPIConfig.HTTPRoutes, rollout.Namespace, gatewayAPIConfig.Namespace)
+	routes = appendCrossNamespaceRoutes(routes, gatewayAPIConfig.GRPCRoutes, rollout.Namespace, gatewayAPIConfig.Namespace)
+	routes = appendCrossNamespaceRoutes(routes, gatewayAPIConfig.TCPRoutes, rollout.Namespace, gatewayAPIConfig.Namespace)
+	routes = appendCrossNamespaceRoutes(routes, gatewayAPIConfig.TLSRoutes, rollout.Namespace, gatewayAPIConfig.Namespace)
+	routes = appendCrossNamespaceRoutes(routes, gatewayAPIConfig.UDPRoutes, rollout.Namespace, gatewayAPIConfig.Namespace)
+	if len(routes) == 0 {
+		return nil
+	}
+
+	referenceGrantList, err := r.GatewayAPIClientset.GatewayV1beta1().ReferenceGrants(rollout.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	experimentDestinations := getExperimentDestinations(rollout, additionalDestinations)
+	for _, route := range routes {
+		canaryMatcher := gatewayAPIConfig.getCanaryBackendRefMatcher(rollout, route.namespace)
+		backendRefMatchers := []backendRefMatcher{gatewayAPIConfig.getStableBackendRefMatcher(rollout, route.namespace), canaryMatcher}
+		// Experiment services are never added to UDPRoutes
+		if route.kind != udpRouteGVK.Kind {
+			for _, destination := range experimentDestinations {
+				backendRefMatchers = append(backendRefMatchers, newExperimentBackendRefMatcher(destination.ServiceName, canaryMatcher))
+			}
+		}
+		for _, backendRefMatcher := range backendRefMatchers {
+			if !isReferenceGranted(referenceGrantList.Items, route, backendRefMatcher) {
+				return newPluginError("ReferenceGrantWasNotFoundError", ReferenceGrantWasNotFoundError, rollout.Namespace, route.kind, route.name, route.namespace, backendRefMatcher.kind, backendRefMatcher.name)
+			}
+		}
+	}
+	return nil
+}
+
+func appendCrossNamespaceRoutes[T1 GatewayAPIRoute](routes []crossNamespaceRoute, routeList []T1, rolloutNamespace, defaultNamespace string) []crossNamespaceRoute {
+	for _, route := range routeList {
+		namespace := route.GetNamespace()
+		if namespace == "" {
+			namespace = defaultNamespace
+		}
+		if namespace == rolloutNamespace {
+			continue
+		}
+		routes = append(routes, crossNamespaceRoute{
+			kind:      getGatewayAPIRouteKind(route),
+			namespace: namespace,
+			name:      route.GetName(),
+		})
+	}
+	return routes
+}
+
+// isReferenceGranted reports whether one of referenceGrants allows route to reference the
+// backend of backendRefMatcher. A grant without a name allows every backend of its kind, and
+// a grant to the "core" group allows Services like the empty group.
+func isReferenceGranted(referenceGrants []gatewayv1beta1.ReferenceGrant, route crossNamespaceRoute, backendRefMatcher backendRefMatcher) bool {
+	for _, referenceGrant := range referenceGrants {
+		isFromAllowed := false
+		for _, from := range referenceGrant.Spec.From {
+			if string(from.Group) == gatewayv1.GroupName && string(from.Kind) == route.kind && string(from.Namespace) == route.namespace {
+				isFromAllowed = true
+				break
+			}
+		}
+		if !isFromAllowed {
+			continue
+		}
+		for _, to := range referenceGrant.Spec.To {
+			if normalizeBackendGroup(string(to.Group), string(to.Kind)) == backendRefMatcher.group && string(to.Kind) == backendRefMatcher.kind && (to.Name == nil || string(*to.Name) == backendRefMatcher.name) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,310 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
+	pluginTypes "github.com/argoproj/argo-rollouts/utils/plugin/types"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	"sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-gatewayapi/internal/defaults"
+)
+
+// routeSpecSnapshot is the pre-rollout spec of a route saved for one rollout. Snapshots are
+// stored in the route itself, keyed by rollout UID, so the plugin stays stateless and
+// several rollouts can share a route. The rollout name is kept to find out whether the
+// rollout still exists.
+type routeSpecSnapshot struct {
+	RolloutNamespace string          `json:"rolloutNamespace"`
+	RolloutName      string          `json:"rolloutName"`
+	Spec             json.RawMessage `json:"spec"`
+}
+
+type routeSpecSnapshots map[types.UID]routeSpecSnapshot
+
+// syncRouteSpecSnapshot takes a snapshot of spec when a canary step moves traffic and
+// restores it once the rollout went back to weight 0 with no canary in progress. It
+// reports whether spec was restored, in which case the caller must not apply any other change.
+func syncRouteSpecSnapshot[T any](r *RpcPlugin, obj metav1.Object, spec *T, rollout *v1alpha1.Rollout, desiredWeight int32, config *GatewayAPITrafficRouting) (bool, error) {
+	if desiredWeight > 0 {
+		return false, ensureRouteSpecSnapshot(r, obj, spec, rollout, config)
+	}
+	if !isRolloutSettled(rollout) {
+		return false, nil
+	}
+	return restoreRouteSpecSnapshot(r, obj, spec, rollout, config)
+}
+
+// ensureRouteSpecSnapshot takes a snapshot of spec for the rollout unless it already has
+// one. While other rollouts hold a snapshot of the route, spec may already carry their
+// canary changes, so their snapshot of the original spec is shared instead.
+func ensureRouteSpecSnapshot[T any](r *RpcPlugin, obj metav1.Object, spec *T, rollout *v1alpha1.Rollout, config *GatewayAPITrafficRouting) error {
+	if obj == nil || config == nil || !config.RestoreOriginalSpec {
+		return nil
+	}
+	snapshots, err := getRouteSpecSnapshots(obj)
+	if err != nil {
+		return err
+	}
+	if _, ok := snapshots[rollout.UID]; ok {
+		return nil
+	}
+	r.pruneRouteSpecSnapshots(obj, snapshots)
+
+	var rawSpec json.RawMessage
+	for _, uid := range getSortedSnapshotUIDs(snapshots) {
+		rawSpec = snapshots[uid].Spec
+		break
+	}
+	if rawSpec == nil {
+		if rawSpec, err = json.Marshal(spec); err != nil {
+			return err
+		}
+	}
+	snapshots[rollout.UID] = routeSpecSnapshot{
+		RolloutNamespace: rollout.Namespace,
+		RolloutName:      rollout.Name,
+		Spec:             rawSpec,
+	}
+	return setRouteSpecSnapshots(obj, snapshots)
+}
+
+// restoreRouteSpecSnapshot removes the snapshot of the rollout and replaces spec with it
+// once no other rollout holds a snapshot of the route. It reports whether spec was
+// restored. The snapshots may change without spec being restored, in which case the
+// caller must still write the route.
+func restoreRouteSpecSnapshot[T any](r *RpcPlugin, obj metav1.Object, spec *T, rollout *v1alpha1.Rollout, config *GatewayAPITrafficRouting) (bool, error) {
+	if obj == nil || config == nil || !config.RestoreOriginalSpec {
+		return false, nil
+	}
+	snapshots, err := getRouteSpecSnapshots(obj)
+	if err != nil {
+		return false, err
+	}
+	snapshot, ok := snapshots[rollout.UID]
+	if !ok {
+		return false, nil
+	}
+	delete(snapshots, rollout.UID)
+	r.pruneRouteSpecSnapshots(obj, snapshots)
+	if len(snapshots) > 0 {
+		r.LogCtx.Info(fmt.Sprintf("keeping the spec of route %q, %d other rollouts still hold a snapshot of it", obj.GetName(), len(snapshots)))
+		return false, setRouteSpecSnapshots(obj, snapshots)
+	}
+	var restoredSpec T
+	if err := json.Unmarshal(snapshot.Spec, &restoredSpec); err != nil {
+		return false, newPluginError("InvalidRouteSpecSnapshotError", InvalidRouteSpecSnapshotError, obj.GetName(), err)
+	}
+	*spec = restoredSpec
+	return true, setRouteSpecSnapshots(obj, snapshots)
+}
+
+func getRouteSpecSnapshots(obj metav1.Object) (routeSpecSnapshots, error) {
+	snapshots := routeSpecSnapshots{}
+	rawSnapshots, ok := obj.GetAnnotations()[defaults.OriginalSpecAnnotationKey]
+	if !ok {
+		return snapshots, nil
+	}
+	if err := json.Unmarshal([]byte(rawSnapshots), &snapshots); err != nil {
+		return nil, newPluginError("InvalidRouteSpecSnapshotError", InvalidRouteSpecSnapshotError, obj.GetName(), err)
+	}
+	return snapshots, nil
+}
+
+// setRouteSpecSnapshots stores snapshots in the annotation of obj, which is removed once no
+// snapshot is left.
+func setRouteSpecSnapshots(obj metav1.Object, snapshots routeSpecSnapshots) error {
+	annotations := obj.GetAnnotations()
+	if len(snapshots) == 0 {
+		delete(annotations, defaults.OriginalSpecAnnotationKey)
+		obj.SetAnnotations(annotations)
+		return nil
+	}
+	rawSnapshots, err := json.Marshal(snapshots)
+	if err != nil {
+		return err
+	}
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[defaults.OriginalSpecAnnotationKey] = string(rawSnapshots)
+	obj.SetAnnotations(annotations)
+	return nil
+}
+
+// pruneRouteSpecSnapshots removes the snapshots of rollouts that no longer exist, which
+// would otherwise keep the route from ever being restored.
+func (r *RpcPlugin) pruneRouteSpecSnapshots(obj metav1.Object, snapshots routeSpecSnapshots) {
+	for _, uid := range getSortedSnapshotUIDs(snapshots) {
+		snapshot := snapshots[uid]
+		if !r.isRolloutDeleted(uid, snapshot) {
+			continue
+		}
+		r.LogCtx.Warn(fmt.Sprintf("removing the original spec snapshot of route %q taken by deleted rollout %s/%s", obj.GetName(), snapshot.RolloutNamespace, snapshot.RolloutName))
+		delete(snapshots, uid)
+	}
+}
+
+// isRolloutDeleted reports whether the rollout that took snapshot is gone, either deleted
+// or replaced by a rollout of the same name. Snapshots are kept whenever that cannot be
+// confirmed.
+func (r *RpcPlugin) isRolloutDeleted(uid types.UID, snapshot routeSpecSnapshot) bool {
+	if r.RolloutClientset == nil {
+		return false
+	}
+	rollout, err := r.RolloutClientset.ArgoprojV1alpha1().Rollouts(snapshot.RolloutNamespace).Get(context.TODO(), snapshot.RolloutName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return true
+	}
+	return err == nil && rollout.UID != uid
+}
+
+func getSortedSnapshotUIDs(snapshots routeSpecSnapshots) []types.UID {
+	uids := make([]types.UID, 0, len(snapshots))
+	for uid := range snapshots {
+		uids = append(uids, uid)
+	}
+	sort.Slice(uids, func(i, j int) bool {
+		return uids[i] < uids[j]
+	})
+	return uids
+}
+
+// isRolloutSettled reports whether the rollout is no longer running a canary, either
+// because it was aborted or because the stable ReplicaSet already runs the current revision.
+func isRolloutSettled(rollout *v1alpha1.Rollout) bool {
+	return rollout.Status.Abort || rollout.Status.StableRS == rollout.Status.CurrentPodHash
+}
+
+// restoreRouteSpecs restores the snapshot of every route of a settled rollout. Rollouts
+// call RemoveManagedRoutes at the end of every rollout, so routes are restored there even
+// when no SetWeight(0) follows.
+func (r *RpcPlugin) restoreRouteSpecs(rollout *v1alpha1.Rollout, config *GatewayAPITrafficRouting) pluginTypes.RpcError {
+	if !config.RestoreOriginalSpec || !isRolloutSettled(rollout) {
+		return pluginTypes.RpcError{}
+	}
+	ctx := context.TODO()
+	rpcError := forEachGatewayAPIRoute(config.HTTPRoutes, func(route HTTPRoute) error {
+		namespace := config.newRouteTarget(route.Namespace, nil, nil).namespace
+		return restoreRouteSpec(r, httpRouteGVK, namespace, route.Name, r.GatewayAPIClientset.GatewayV1().HTTPRoutes(namespace), rollout, config,
+			func(useCache bool) (*gatewayv1.HTTPRoute, error) {
+				return r.getHTTPRoute(ctx, namespace, route.Name, useCache)
+			},
+			func(httpRoute *gatewayv1.HTTPRoute) (*gatewayv1.HTTPRouteSpec, any) {
+				return &httpRoute.Spec, httpRoute.Spec.Rules
+			})
+	})
+	if rpcError.HasError() {
+		return rpcError
+	}
+	rpcError = forEachGatewayAPIRoute(config.GRPCRoutes, func(route GRPCRoute) error {
+		namespace := config.newRouteTarget(route.Namespace, nil, nil).namespace
+		return restoreRouteSpec(r, grpcRouteGVK, namespace, route.Name, r.GatewayAPIClientset.GatewayV1().GRPCRoutes(namespace), rollout, config,
+			func(useCache bool) (*gatewayv1.GRPCRoute, error) {
+				return r.getGRPCRoute(ctx, namespace, route.Name, useCache)
+			},
+			func(grpcRoute *gatewayv1.GRPCRoute) (*gatewayv1.GRPCRouteSpec, any) {
+				return &grpcRoute.Spec, grpcRoute.Spec.Rules
+			})
+	})
+	if rpcError.HasError() {
+		return rpcError
+	}
+	rpcError = forEachGatewayAPIRoute(config.TCPRoutes, func(route TCPRoute) error {
+		namespace := config.newRouteTarget(route.Namespace, nil, nil).namespace
+		return restoreRouteSpec(r, tcpRouteGVK, namespace, route.Name, r.GatewayAPIClientset.GatewayV1alpha2().TCPRoutes(namespace), rollout, config,
+			func(useCache bool) (*v1alpha2.TCPRoute, error) {
+				return r.getTCPRoute(ctx, namespace, route.Name, useCache)
+			},
+			func(tcpRoute *v1alpha2.TCPRoute) (*v1alpha2.TCPRouteSpec, any) {
+				return &tcpRoute.Spec, tcpRoute.Spec.Rules
+			})
+	})
+	if rpcError.HasError() {
+		return rpcError
+	}
+	rpcError = forEachGatewayAPIRoute(config.TLSRoutes, func(route TLSRoute) error {
+		namespace := config.newRouteTarget(route.Namespace, nil, nil).namespace
+		return restoreRouteSpec(r, tlsRouteGVK, namespace, route.Name, r.GatewayAPIClientset.GatewayV1alpha2().TLSRoutes(namespace), rollout, config,
+			func(useCache bool) (*v1alpha2.TLSRoute, error) {
+				return r.getTLSRoute(ctx, namespace, route.Name, useCache)
+			},
+			func(tlsRoute *v1alpha2.TLSRoute) (*v1alpha2.TLSRouteSpec, any) {
+				return &tlsRoute.Spec, tlsRoute.Spec.Rules
+			})
+	})
+	if rpcError.HasError() {
+		return rpcError
+	}
+	return forEachGatewayAPIRoute(config.UDPRoutes, func(route UDPRoute) error {
+		namespace := config.newRouteTarget(route.Namespace, nil, nil).namespace
+		return restoreRouteSpec(r, udpRouteGVK, namespace, route.Name, r.GatewayAPIClientset.GatewayV1alpha2().UDPRoutes(namespace), rollout, config,
+			func(useCache bool) (*v1alpha2.UDPRoute, error) {
+				return r.getUDPRoute(ctx, namespace, route.Name, useCache)
+			},
+			func(udpRoute *v1alpha2.UDPRoute) (*v1alpha2.UDPRouteSpec, any) {
+				return &udpRoute.Spec, udpRoute.Spec.Rules
+			})
+	})
+}
+
+// restoreRouteSpec restores the snapshot of a single route. getSpec returns the spec of the
+// route and its rules, which are read after the snapshot was decoded into the spec.
+func restoreRouteSpec[T interface {
+	metav1.Object
+	runtime.Object
+}, Spec any](r *RpcPlugin, gvk schema.GroupVersionKind, namespace, name string, client routeClient[T], rollout *v1alpha1.Rollout, config *GatewayAPITrafficRouting, getRoute func(useCache bool) (T, error), getSpec func(T) (*Spec, any)) error {
+	ctx := context.TODO()
+	err := retryOnConflictOrStale(gvk, func(useCache bool) error {
+		route, err := getRoute(useCache)
+		if err != nil {
+			return err
+		}
+		spec, _ := getSpec(route)
+		originalSpec := *spec
+		originalSnapshots := route.GetAnnotations()[defaults.OriginalSpecAnnotationKey]
+		isRestored, err := restoreRouteSpecSnapshot(r, route, spec, rollout, config)
+		if err != nil {
+			return err
+		}
+		if !isRestored {
+			if route.GetAnnotations()[defaults.OriginalSpecAnnotationKey] == originalSnapshots {
+				return errRouteUnchanged
+			}
+			// Other rollouts still hold a snapshot, so only the snapshot of rollout is removed
+			if r.isDryRun(config) {
+				return nil
+			}
+			_, rules := getSpec(route)
+			return updateRoute(ctx, client, route, rules, gvk, config)
+		}
+		r.LogCtx.Info(fmt.Sprintf("[RemoveManagedRoutes] restoring original spec of %s %q", gvk.Kind, name))
+		ensureInProgressLabel(route, 0, config)
+		if r.isDryRun(config) {
+			return r.logDryRun(gvk, route, &originalSpec, spec)
+		}
+		// With server-side apply only the rules of the snapshot are applied, the other fields
+		// of the spec stay with their current field managers
+		_, rules := getSpec(route)
+		if err = updateRoute(ctx, client, route, rules, gvk, config); err != nil {
+			return err
+		}
+		r.recordEvent(route, corev1.EventTypeNormal, OriginalSpecRestoredReason, "Restored the spec saved before the canary started")
+		return nil
+	})
+	if err != nil {
+		r.recordRouteFailure(gvk, namespace, name, "RemoveManagedRoutes", err)
+		return err
+	}
+	return nil
+}
@@ -2,56 +2,155 @@ package plugin
 
 import (
 	"context"
-	"errors"
+	"fmt"
 
 	"github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
-	pluginTypes "github.com/argoproj/argo-rollouts/utils/plugin/types"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/util/retry"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	"sigs.k8s.io/gateway-api/apis/v1alpha2"
 )
 
-func (r *RpcPlugin) setTCPRouteWeight(rollout *v1alpha1.Rollout, desiredWeight int32, gatewayAPIConfig *GatewayAPITrafficRouting) pluginTypes.RpcError {
+func (r *RpcPlugin) setTCPRouteWeight(rollout *v1alpha1.Rollout, desiredWeight int32, additionalDestinations []v1alpha1.WeightDestination, experimentPort *ExperimentPort, target routeTarget, gatewayAPIConfig *GatewayAPITrafficRouting) error {
 	ctx := context.TODO()
-	tcpRouteClient := r.GatewayAPIClientset.GatewayV1alpha2().TCPRoutes(gatewayAPIConfig.Namespace)
+	tcpRouteClient := r.GatewayAPIClientset.GatewayV1alpha2().TCPRoutes(target.namespace)
 
-	canaryServiceName := rollout.Spec.Strategy.Canary.CanaryService
-	stableServiceName := rollout.Spec.Strategy.Canary.StableService
-	restWeight := 100 - desiredWeight
+	canaryMatcher := gatewayAPIConfig.getCanaryBackendRefMatcher(rollout, target.namespace)
+	stableMatcher := gatewayAPIConfig.getStableBackendRefMatcher(rollout, target.namespace)
+	canaryServiceName := canaryMatcher.name
+	stableServiceName := stableMatcher.name
+	experimentDestinations := getExperimentDestinations(rollout, additionalDestinations)
+	ownedMatchers := gatewayAPIConfig.getOwnedBackendRefMatchers(rollout, target.namespace, experimentDestinations)
 
-	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-		tcpRoute, err := tcpRouteClient.Get(ctx, gatewayAPIConfig.TCPRoute, metav1.GetOptions{})
+	err := retryOnConflict(tcpRouteGVK, func(useCache bool) error {
+		tcpRoute, err := r.getTCPRoute(ctx, target.namespace, gatewayAPIConfig.TCPRoute, useCache)
 		if err != nil {
 			return err
 		}
+		originalSpec := tcpRoute.Spec.DeepCopy()
 
-		routeRuleList := TCPRouteRuleList(tcpRoute.Spec.Rules)
-		canaryBackendRefs, err := getBackendRefs(canaryServiceName, routeRuleList)
+		isRestored, err := syncRouteSpecSnapshot(r, tcpRoute, &tcpRoute.Spec, rollout, desiredWeight, gatewayAPIConfig)
 		if err != nil {
 			return err
 		}
-		for _, ref := range canaryBackendRefs {
-			ref.Weight = &desiredWeight
+		if isRestored {
+			r.LogCtx.Info(fmt.Sprintf("[SetWeight] restoring original spec of TCPRoute %q", tcpRoute.Name))
+			ensureInProgressLabel(tcpRoute, desiredWeight, gatewayAPIConfig)
+			if r.isDryRun(gatewayAPIConfig) {
+				return r.logDryRun(tcpRouteGVK, tcpRoute, originalSpec, &tcpRoute.Spec)
+			}
+			if err = updateRoute(ctx, tcpRouteClient, tcpRoute, tcpRoute.Spec.Rules, tcpRouteGVK, gatewayAPIConfig); err != nil {
+				return err
+			}
+			r.recordEvent(tcpRoute, corev1.EventTypeNormal, OriginalSpecRestoredReason, "Restored the spec saved before the canary started")
+			return nil
 		}
-		stableBackendRefs, err := getBackendRefs(stableServiceName, routeRuleList)
-		if err != nil {
-			return err
+
+		canaryFound, stableFound := false, false
+		var allocation weightAllocation
+		for i := range tcpRoute.Spec.Rules {
+			backendRefs := tcpRoute.Spec.Rules[i].BackendRefs
+			if !hasRolloutBackendRef[*TCPBackendRef]((*TCPRouteRule)(&tcpRoute.Spec.Rules[i]), canaryMatcher, stableMatcher) {
+				continue
+			}
+			allocation, err = allocateWeights(desiredWeight, experimentDestinations, getUnmanagedWeight(backendRefs, getExperimentBackendRef, ownedMatchers))
+			if err != nil {
+				return err
+			}
+			r.warnUnmanagedWeight(tcpRouteGVK.Kind, tcpRoute.Name, i, allocation)
+			canaryWeight, stableWeight := allocation.canaryWeight, allocation.stableWeight
+			for j := range backendRefs {
+				switch {
+				case canaryMatcher.matches(backendRefs[j].BackendObjectReference):
+					backendRefs[j].Weight = &canaryWeight
+					canaryFound = true
+				case stableMatcher.matches(backendRefs[j].BackendObjectReference):
+					backendRefs[j].Weight = &stableWeight
+					stableFound = true
+				}
+			}
 		}
-		for _, ref := range stableBackendRefs {
-			ref.Weight = &restWeight
+		if !canaryFound || !stableFound {
+			return newPluginError("BackendRefWasNotFoundInTCPRouteError", BackendRefWasNotFoundInTCPRouteError)
+		}
+
+		previousBackendRefs := getTCPBackendRefKeys(tcpRoute.Spec.Rules, target.namespace)
+		if err = HandleTCPExperiment(ctx, r.getService, r.LogCtx, rollout, tcpRoute, additionalDestinations, experimentPort, target, gatewayAPIConfig); err != nil {
+			return err
 		}
 
 		ensureInProgressLabel(tcpRoute, desiredWeight, gatewayAPIConfig)
 
-		_, err = tcpRouteClient.Update(ctx, tcpRoute, metav1.UpdateOptions{})
-		return err
+		if r.isDryRun(gatewayAPIConfig) {
+			return r.logDryRun(tcpRouteGVK, tcpRoute, originalSpec, &tcpRoute.Spec)
+		}
+		if err = updateRoute(ctx, tcpRouteClient, tcpRoute, tcpRoute.Spec.Rules, tcpRouteGVK, gatewayAPIConfig); err != nil {
+			return err
+		}
+		if !equality.Semantic.DeepEqual(originalSpec.Rules, tcpRoute.Spec.Rules) {
+			r.recordEvent(tcpRoute, corev1.EventTypeNormal, WeightUpdatedReason, "Set weight of canary service %q to %d and stable service %q to %d", canaryServiceName, allocation.canaryWeight, stableServiceName, allocation.stableWeight)
+		}
+		r.recordExperimentBackendEvents(tcpRoute, previousBackendRefs, getTCPBackendRefKeys(tcpRoute.Spec.Rules, target.namespace))
+		return nil
 	})
 
 	if err != nil {
-		return pluginTypes.RpcError{
-			ErrorString: err.Error(),
+		r.recordRouteFailure(tcpRouteGVK, target.namespace, gatewayAPIConfig.TCPRoute, "SetWeight", err)
+		return err
+	}
+	r.recordCanaryWeight(rollout, tcpRouteGVK.Kind, target.namespace, gatewayAPIConfig.TCPRoute, desiredWeight, gatewayAPIConfig)
+	return nil
+}
+
+func (r *RpcPlugin) verifyTCPRouteWeight(rollout *v1alpha1.Rollout, desiredWeight int32, additionalDestinations []v1alpha1.WeightDestination, target routeTarget, gatewayAPIConfig *GatewayAPITrafficRouting) (bool, error) {
+	ctx := context.TODO()
+	allocation, err := allocateWeights(desiredWeight, getExperimentDestinations(rollout, additionalDestinations), 0)
+	if err != nil {
+		return false, err
+	}
+
+	tcpRoute, err := r.getTCPRoute(ctx, target.namespace, gatewayAPIConfig.TCPRoute, true)
+	if err != nil {
+		return false, err
+	}
+
+	routeRuleList := TCPRouteRuleList(tcpRoute.Spec.Rules)
+	canaryBackendRefs, err := getBackendRefs(gatewayAPIConfig.getCanaryBackendRefMatcher(rollout, target.namespace), routeRuleList)
+	if err != nil {
+		return false, err
+	}
+	for _, ref := range canaryBackendRefs {
+		if !isWeightEqual(ref.Weight, desiredWeight) {
+			r.LogCtx.Info(fmt.Sprintf("[VerifyWeight] TCPRoute %q canary weight is not %d yet", tcpRoute.Name, desiredWeight))
+			return false, nil
+		}
+	}
+	stableBackendRefs, err := getBackendRefs(gatewayAPIConfig.getStableBackendRefMatcher(rollout, target.namespace), routeRuleList)
+	if err != nil {
+		return false, err
+	}
+	for _, ref := range stableBackendRefs {
+		if !isWeightEqual(ref.Weight, allocation.stableWeight) {
+			r.LogCtx.Info(fmt.Sprintf("[VerifyWeight] TCPRoute %q stable weight is not %d yet", tcpRoute.Name, allocation.stableWeight))
+			return false, nil
 		}
 	}
-	return pluginTypes.RpcError{}
+
+	if isAccepted, reason := isRouteAccepted(tcpRoute.Generation, tcpRoute.Status.RouteStatus); !isAccepted {
+		r.LogCtx.Info(fmt.Sprintf("[VerifyWeight] TCPRoute %q is not accepted yet: %s", tcpRoute.Name, reason))
+		return false, nil
+	}
+	return true, nil
+}
+
+func getTCPBackendRefKeys(rules []v1alpha2.TCPRouteRule, routeNamespace string) map[backendRefKey]bool {
+	keys := make(map[backendRefKey]bool)
+	for _, rule := range rules {
+		for _, backendRef := range rule.BackendRefs {
+			keys[newBackendRefKey(backendRef.BackendObjectReference, routeNamespace)] = true
+		}
+	}
+	return keys
 }
 
 func (r *TCPRouteRule) Iterator() (GatewayAPIRouteRuleIterator[*TCPBackendRef], bool) {
@@ -83,13 +182,21 @@ func (r TCPRouteRuleList) Iterator() (GatewayAPIRouteRuleListIterator[*TCPBacken
 }
 
 func (r TCPRouteRuleList) Error() error {
-	return errors.New(BackendRefListWasNotFoundInTCPRouteError)
+	return newPluginError("BackendRefListWasNotFoundInTCPRouteError", BackendRefListWasNotFoundInTCPRouteError)
 }
 
 func (r *TCPBackendRef) GetName() string {
 	return string(r.Name)
 }
 
+func (r *TCPBackendRef) GetBackendObjectReference() gatewayv1.BackendObjectReference {
+	return r.BackendObjectReference
+}
+
 func (r TCPRoute) GetName() string {
 	return r.Name
 }
+
+func (r TCPRoute) GetNamespace() string {
+	return r.Namespace
+}
@@ -2,56 +2,155 @@ package plugin
 
 import (
 	"context"
-	"errors"
+	"fmt"
 
 	"github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
-	pluginTypes "github.com/argoproj/argo-rollouts/utils/plugin/types"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/util/retry"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	"sigs.k8s.io/gateway-api/apis/v1alpha2"
 )
 
-func (r *RpcPlugin) setTLSRouteWeight(rollout *v1alpha1.Rollout, desiredWeight int32, gatewayAPIConfig *GatewayAPITrafficRouting) pluginTypes.RpcError {
+func (r *RpcPlugin) setTLSRouteWeight(rollout *v1alpha1.Rollout, desiredWeight int32, additionalDestinations []v1alpha1.WeightDestination, experimentPort *ExperimentPort, target routeTarget, gatewayAPIConfig *GatewayAPITrafficRouting) error {
 	ctx := context.TODO()
-	tlsRouteClient := r.GatewayAPIClientset.GatewayV1alpha2().TLSRoutes(gatewayAPIConfig.Namespace)
+	tlsRouteClient := r.GatewayAPIClientset.GatewayV1alpha2().TLSRoutes(target.namespace)
 
-	canaryServiceName := rollout.Spec.Strategy.Canary.CanaryService
-	stableServiceName := rollout.Spec.Strategy.Canary.StableService
-	restWeight := 100 - desiredWeight
+	canaryMatcher := gatewayAPIConfig.getCanaryBackendRefMatcher(rollout, target.namespace)
+	stableMatcher := gatewayAPIConfig.getStableBackendRefMatcher(rollout, target.namespace)
+	canaryServiceName := canaryMatcher.name
+	stableServiceName := stableMatcher.name
+	experimentDestinations := getExperimentDestinations(rollout, additionalDestinations)
+	ownedMatchers := gatewayAPIConfig.getOwnedBackendRefMatchers(rollout, target.namespace, experimentDestinations)
 
-	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-		tlsRoute, err := tlsRouteClient.Get(ctx, gatewayAPIConfig.TLSRoute, metav1.GetOptions{})
+	err := retryOnConflict(tlsRouteGVK, func(useCache bool) error {
+		tlsRoute, err := r.getTLSRoute(ctx, target.namespace, gatewayAPIConfig.TLSRoute, useCache)
 		if err != nil {
 			return err
 		}
+		originalSpec := tlsRoute.Spec.DeepCopy()
 
-		routeRuleList := TLSRouteRuleList(tlsRoute.Spec.Rules)
-		canaryBackendRefs, err := getBackendRefs(canaryServiceName, routeRuleList)
+		isRestored, err := syncRouteSpecSnapshot(r, tlsRoute, &tlsRoute.Spec, rollout, desiredWeight, gatewayAPIConfig)
 		if err != nil {
 			return err
 		}
-		for _, ref := range canaryBackendRefs {
-			ref.Weight = &desiredWeight
+		if isRestored {
+			r.LogCtx.Info(fmt.Sprintf("[SetWeight] restoring original spec of TLSRoute %q", tlsRoute.Name))
+			ensureInProgressLabel(tlsRoute, desiredWeight, gatewayAPIConfig)
+			if r.isDryRun(gatewayAPIConfig) {
+				return r.logDryRun(tlsRouteGVK, tlsRoute, originalSpec, &tlsRoute.Spec)
+			}
+			if err = updateRoute(ctx, tlsRouteClient, tlsRoute, tlsRoute.Spec.Rules, tlsRouteGVK, gatewayAPIConfig); err != nil {
+				return err
+			}
+			r.recordEvent(tlsRoute, corev1.EventTypeNormal, OriginalSpecRestoredReason, "Restored the spec saved before the canary started")
+			return nil
 		}
-		stableBackendRefs, err := getBackendRefs(stableServiceName, routeRuleList)
-		if err != nil {
-			return err
+
+		canaryFound, stableFound := false, false
+		var allocation weightAllocation
+		for i := range tlsRoute.Spec.Rules {
+			backendRefs := tlsRoute.Spec.Rules[i].BackendRefs
+			if !hasRolloutBackendRef[*TLSBackendRef]((*TLSRouteRule)(&tlsRoute.Spec.Rules[i]), canaryMatcher, stableMatcher) {
+				continue
+			}
+			allocation, err = allocateWeights(desiredWeight, experimentDestinations, getUnmanagedWeight(backendRefs, getExperimentBackendRef, ownedMatchers))
+			if err != nil {
+				return err
+			}
+			r.warnUnmanagedWeight(tlsRouteGVK.Kind, tlsRoute.Name, i, allocation)
+			canaryWeight, stableWeight := allocation.canaryWeight, allocation.stableWeight
+			for j := range backendRefs {
+				switch {
+				case canaryMatcher.matches(backendRefs[j].BackendObjectReference):
+					backendRefs[j].Weight = &canaryWeight
+					canaryFound = true
+				case stableMatcher.matches(backendRefs[j].BackendObjectReference):
+					backendRefs[j].Weight = &stableWeight
+					stableFound = true
+				}
+			}
 		}
-		for _, ref := range stableBackendRefs {
-			ref.Weight = &restWeight
+		if !canaryFound || !stableFound {
+			return newPluginError("BackendRefWasNotFoundInTLSRouteError", BackendRefWasNotFoundInTLSRouteError)
+		}
+
+		previousBackendRefs := getTLSBackendRefKeys(tlsRoute.Spec.Rules, target.namespace)
+		if err = HandleTLSExperiment(ctx, r.getService, r.LogCtx, rollout, tlsRoute, additionalDestinations, experimentPort, target, gatewayAPIConfig); err != nil {
+			return err
 		}
 
 		ensureInProgressLabel(tlsRoute, desiredWeight, gatewayAPIConfig)
 
-		_, err = tlsRouteClient.Update(ctx, tlsRoute, metav1.UpdateOptions{})
-		return err
+		if r.isDryRun(gatewayAPIConfig) {
+			return r.logDryRun(tlsRouteGVK, tlsRoute, originalSpec, &tlsRoute.Spec)
+		}
+		if err = updateRoute(ctx, tlsRouteClient, tlsRoute, tlsRoute.Spec.Rules, tlsRouteGVK, gatewayAPIConfig); err != nil {
+			return err
+		}
+		if !equality.Semantic.DeepEqual(originalSpec.Rules, tlsRoute.Spec.Rules) {
+			r.recordEvent(tlsRoute, corev1.EventTypeNormal, WeightUpdatedReason, "Set weight of canary service %q to %d and stable service %q to %d", canaryServiceName, allocation.canaryWeight, stableServiceName, allocation.stableWeight)
+		}
+		r.recordExperimentBackendEvents(tlsRoute, previousBackendRefs, getTLSBackendRefKeys(tlsRoute.Spec.Rules, target.namespace))
+		return nil
 	})
 
 	if err != nil {
-		return pluginTypes.RpcError{
-			ErrorString: err.Error(),
+		r.recordRouteFailure(tlsRouteGVK, target.namespace, gatewayAPIConfig.TLSRoute, "SetWeight", err)
+		return err
+	}
+	r.recordCanaryWeight(rollout, tlsRouteGVK.Kind, target.namespace, gatewayAPIConfig.TLSRoute, desiredWeight, gatewayAPIConfig)
+	return nil
+}
+
+func (r *RpcPlugin) verifyTLSRouteWeight(rollout *v1alpha1.Rollout, desiredWeight int32, additionalDestinations []v1alpha1.WeightDestination, target routeTarget, gatewayAPIConfig *GatewayAPITrafficRouting) (bool, error) {
+	ctx := context.TODO()
+	allocation, err := allocateWeights(desiredWeight, getExperimentDestinations(rollout, additionalDestinations), 0)
+	if err != nil {
+		return false, err
+	}
+
+	tlsRoute, err := r.getTLSRoute(ctx, target.namespace, gatewayAPIConfig.TLSRoute, true)
+	if err != nil {
+		return false, err
+	}
+
+	routeRuleList := TLSRouteRuleList(tlsRoute.Spec.Rules)
+	canaryBackendRefs, err := getBackendRefs(gatewayAPIConfig.getCanaryBackendRefMatcher(rollout, target.namespace), routeRuleList)
+	if err != nil {
+		return false, err
+	}
+	for _, ref := range canaryBackendRefs {
+		if !isWeightEqual(ref.Weight, desiredWeight) {
+			r.LogCtx.Info(fmt.Sprintf("[VerifyWeight] TLSRoute %q canary weight is not %d yet", tlsRoute.Name, desiredWeight))
+			return false, nil
+		}
+	}
+	stableBackendRefs, err := getBackendRefs(gatewayAPIConfig.getStableBackendRefMatcher(rollout, target.namespace), routeRuleList)
+	if err != nil {
+		return false, err
+	}
+	for _, ref := range stableBackendRefs {
+		if !isWeightEqual(ref.Weight, allocation.stableWeight) {
+			r.LogCtx.Info(fmt.Sprintf("[VerifyWeight] TLSRoute %q stable weight is not %d yet", tlsRoute.Name, allocation.stableWeight))
+			return false, nil
 		}
 	}
-	return pluginTypes.RpcError{}
+
+	if isAccepted, reason := isRouteAccepted(tlsRoute.Generation, tlsRoute.Status.RouteStatus); !isAccepted {
+		r.LogCtx.Info(fmt.Sprintf("[VerifyWeight] TLSRoute %q is not accepted yet: %s", tlsRoute.Name, reason))
+		return false, nil
+	}
+	return true, nil
+}
+
+func getTLSBackendRefKeys(rules []v1alpha2.TLSRouteRule, routeNamespace string) map[backendRefKey]bool {
+	keys := make(map[backendRefKey]bool)
+	for _, rule := range rules {
+		for _, backendRef := range rule.BackendRefs {
+			keys[newBackendRefKey(backendRef.BackendObjectReference, routeNamespace)] = true
+		}
+	}
+	return keys
 }
 
 func (r *TLSRouteRule) Iterator() (GatewayAPIRouteRuleIterator[*TLSBackendRef], bool) {
@@ -83,13 +182,21 @@ func (r TLSRouteRuleList) Iterator() (GatewayAPIRouteRuleListIterator[*TLSBacken
 }
 
 func (r TLSRouteRuleList) Error() error {
-	return errors.New(BackendRefListWasNotFoundInTLSRouteError)
+	return newPluginError("BackendRefListWasNotFoundInTLSRouteError", BackendRefListWasNotFoundInTLSRouteError)
 }
 
 func (r *TLSBackendRef) GetName() string {
 	return string(r.Name)
 }
 
+func (r *TLSBackendRef) GetBackendObjectReference() gatewayv1.BackendObjectReference {
+	return r.BackendObjectReference
+}
+
 func (r TLSRoute) GetName() string {
 	return r.Name
 }
+
+func (r TLSRoute) GetNamespace() string {
+	return r.Namespace
+}
@@ -1,9 +1,11 @@
 package plugin
 
 import (
+	rolloutsClientset "github.com/argoproj/argo-rollouts/pkg/client/clientset/versioned"
 	"github.com/sirupsen/logrus"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
 	"sigs.k8s.io/gateway-api/apis/v1alpha2"
 	gatewayAPIClientset "sigs.k8s.io/gateway-api/pkg/client/clientset/versioned"
@@ -12,13 +14,22 @@ import (
 type CommandLineOpts struct {
 	KubeClientQPS   float32
 	KubeClientBurst int
+	// EnableInformerCache serves route and Service reads from shared informers
+	EnableInformerCache bool
+	// InformerNamespace restricts the informers to one namespace, all namespaces if empty
+	InformerNamespace string
+	// DryRun logs route changes instead of writing them for every rollout
+	DryRun bool
 }
 
 type RpcPlugin struct {
 	CommandLineOpts     CommandLineOpts
 	GatewayAPIClientset gatewayAPIClientset.Interface
-	Clientset           *kubernetes.Clientset
+	Clientset           kubernetes.Interface
+	RolloutClientset    rolloutsClientset.Interface
 	LogCtx              *logrus.Entry
+	cache               *informerCache
+	recorder            record.EventRecorder
 }
 
 type GatewayAPITrafficRouting struct {
@@ -34,6 +45,9 @@ type GatewayAPITrafficRouting struct {
 	// TLSRoute refers to the name of the TLSRoute used to route traffic to the
 	// service
 	TLSRoute string `json:"tlsRoute,omitempty"`
+	// UDPRoute refers to the name of the UDPRoute used to route traffic to the
+	// service
+	UDPRoute string `json:"udpRoute,omitempty"`
 	// Namespace refers to the namespace of the specified resource
 	Namespace string `json:"namespace,omitempty"`
 	// HTTPRoutes refer to names of HTTPRoute resources used to route traffic to the
@@ -48,49 +62,217 @@ type GatewayAPITrafficRouting struct {
 	// TLSRoutes refer to names of TLSRoute resources used to route traffic to the
 	// service
 	TLSRoutes []TLSRoute `json:"tlsRoutes,omitempty"`
+	// UDPRoutes refer to names of UDPRoute resources used to route traffic to the
+	// service
+	UDPRoutes []UDPRoute `json:"udpRoutes,omitempty"`
 	// HTTPRouteSelector refers to label selector for auto-discovery of HTTPRoutes
 	HTTPRouteSelector *metav1.LabelSelector `json:"httpRouteSelector,omitempty"`
+	// HTTPRouteSelectorUseHeaderRoutes adds header routes to the HTTPRoutes discovered by
+	// HTTPRouteSelector during setHeaderRoute step
+	HTTPRouteSelectorUseHeaderRoutes bool `json:"httpRouteSelectorUseHeaderRoutes,omitempty"`
 	// GRPCRouteSelector refers to label selector for auto-discovery of GRPCRoutes
 	GRPCRouteSelector *metav1.LabelSelector `json:"grpcRouteSelector,omitempty"`
+	// GRPCRouteSelectorUseHeaderRoutes adds header routes to the GRPCRoutes discovered by
+	// GRPCRouteSelector during setHeaderRoute step
+	GRPCRouteSelectorUseHeaderRoutes bool `json:"grpcRouteSelectorUseHeaderRoutes,omitempty"`
 	// TCPRouteSelector refers to label selector for auto-discovery of TCPRoutes
 	TCPRouteSelector *metav1.LabelSelector `json:"tcpRouteSelector,omitempty"`
 	// TLSRouteSelector refers to label selector for auto-discovery of TLSRoutes
 	TLSRouteSelector *metav1.LabelSelector `json:"tlsRouteSelector,omitempty"`
+	// UDPRouteSelector refers to label selector for auto-discovery of UDPRoutes
+	UDPRouteSelector *metav1.LabelSelector `json:"udpRouteSelector,omitempty"`
+	// NamespaceSelector refers to label selector for the namespaces searched by the route
+	// selectors and DiscoverByBackendRefs, which only search Namespace when neither it nor
+	// Namespaces is set
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	// Namespaces refer to names of namespaces searched by the route selectors and
+	// DiscoverByBackendRefs, on top of the ones matching NamespaceSelector
+	Namespaces []string `json:"namespaces,omitempty"`
+	// DiscoverByBackendRefs manages every route in the searched namespaces with a rule that
+	// references both the stable and canary services
+	DiscoverByBackendRefs bool `json:"discoverByBackendRefs,omitempty"`
+	// GatewayRef keeps only the discovered routes attached to and accepted by this Gateway.
+	// Without route selectors it discovers the routes of the Gateway like
+	// DiscoverByBackendRefs
+	GatewayRef *GatewayRef `json:"gatewayRef,omitempty"`
+	// RootServiceRef keeps only the discovered routes attached to and accepted for this
+	// Service, the parentRef of GAMMA routes for east-west traffic in a service mesh. Without
+	// route selectors it discovers the routes of the Service like DiscoverByBackendRefs
+	RootServiceRef *ServiceRef `json:"rootServiceRef,omitempty"`
+	// StableBackendRef describes the backendRefs of the stable service, or of another stable
+	// backend such as an InferencePool. Only backendRefs to a Service in the rollout
+	// namespace are changed when it is not set
+	StableBackendRef *BackendRefMatch `json:"stableBackendRef,omitempty"`
+	// CanaryBackendRef describes the backendRefs of the canary service, or of another canary
+	// backend such as an InferencePool. Only backendRefs to a Service in the rollout
+	// namespace are changed when it is not set
+	CanaryBackendRef *BackendRefMatch `json:"canaryBackendRef,omitempty"`
 	// DisableInProgressLabel disables the automatic label that marks routes as managed during canary steps
 	DisableInProgressLabel bool `json:"disableInProgressLabel,omitempty"`
 	// InProgressLabelKey overrides the label key used while a canary is running
 	InProgressLabelKey string `json:"inProgressLabelKey,omitempty"`
 	// InProgressLabelValue overrides the label value used while a canary is running
 	InProgressLabelValue string `json:"inProgressLabelValue,omitempty"`
+	// RestoreOriginalSpec saves the spec of every route before the canary changes it and
+	// restores it once the rollout is aborted or fully promoted
+	RestoreOriginalSpec bool `json:"restoreOriginalSpec,omitempty"`
+	// ServerSideApply writes routes with server-side apply patches that only contain the
+	// rules and metadata owned by the plugin instead of updating the whole object
+	ServerSideApply bool `json:"serverSideApply,omitempty"`
+	// FieldManager overrides the field manager used with ServerSideApply
+	FieldManager string `json:"fieldManager,omitempty"`
+	// ForceConflicts takes ownership of fields owned by other field managers with
+	// ServerSideApply instead of reporting a conflict
+	ForceConflicts bool `json:"forceConflicts,omitempty"`
+	// DryRun logs the changes the plugin would make to the routes instead of writing them
+	DryRun bool `json:"dryRun,omitempty"`
+	// HeaderRouteMatches adds query parameter, path and method matches to the HTTPRoute
+	// rules generated for a managed route during setHeaderRoute step
+	HeaderRouteMatches []HeaderRouteMatch `json:"headerRouteMatches,omitempty"`
+	// ExperimentPort selects the Service port of experiment backends for every route that
+	// does not set its own experimentPort
+	ExperimentPort *ExperimentPort `json:"experimentPort,omitempty"`
+}
+
+// routeTarget is what the plugin needs to know about a route besides its name while it
+// changes the route: where it is and which of its rules it may change.
+type routeTarget struct {
+	// namespace is the namespace of the route
+	namespace string
+	// ruleNames and ruleIndexes are the RuleNames and RuleIndexes of the route, every rule is
+	// targeted when both are empty
+	ruleNames   []string
+	ruleIndexes []int
+}
+
+type GatewayRef struct {
+	// Name refers to the name of the Gateway
+	Name string `json:"name" validate:"required"`
+	// Namespace refers to the namespace of the Gateway, defaults to
+	// GatewayAPITrafficRouting.Namespace
+	Namespace string `json:"namespace,omitempty"`
+	// SectionName refers to a listener of the Gateway, every listener matches when empty
+	SectionName string `json:"sectionName,omitempty"`
+}
+
+type ServiceRef struct {
+	// Name refers to the name of the Service
+	Name string `json:"name" validate:"required"`
+	// Namespace refers to the namespace of the Service, defaults to
+	// GatewayAPITrafficRouting.Namespace
+	Namespace string `json:"namespace,omitempty"`
+	// Port refers to the port of the Service, every port matches when it is not set
+	Port *int32 `json:"port,omitempty"`
+}
+
+type BackendRefMatch struct {
+	// Name refers to the name of the backend, defaults to the name of the stable or canary
+	// service of the rollout
+	Name string `json:"name,omitempty"`
+	// Kind refers to the kind of the backend, one of Service, ServiceImport or
+	// InferencePool. Defaults to Service
+	Kind string `json:"kind,omitempty" validate:"omitempty,oneof=Service ServiceImport InferencePool"`
+	// Group refers to the API group of the backend, defaults to the group of Kind
+	Group string `json:"group,omitempty"`
+	// Port refers to the port of the backendRef, backendRefs to every port match when it
+	// is not set
+	Port *int32 `json:"port,omitempty"`
+}
+
+type ExperimentPort struct {
+	// Name refers to the name of the experiment Service port
+	Name string `json:"name,omitempty"`
+	// Number refers to the number of the experiment Service port, used when Name is empty
+	Number int32 `json:"number,omitempty"`
+}
+
+type HeaderRouteMatch struct {
+	// Name refers to the name of the managed route used in setHeaderRoute step
+	Name string `json:"name"`
+	// QueryParams are added to the query parameter matches of the generated rules
+	QueryParams []gatewayv1.HTTPQueryParamMatch `json:"queryParams,omitempty"`
+	// Path replaces the path match of the generated rules
+	Path *gatewayv1.HTTPPathMatch `json:"path,omitempty"`
+	// Method replaces the method match of the generated rules
+	Method *gatewayv1.HTTPMethod `json:"method,omitempty"`
 }
 
 type HTTPRoute struct {
 	// Name refers to the HTTPRoute name
 	Name string `json:"name" validate:"required"`
+	// Namespace refers to the namespace of the HTTPRoute, which overrides
+	// GatewayAPITrafficRouting.Namespace
+	Namespace string `json:"namespace,omitempty"`
 	// UseHeaderRoutes defines header routes will be added to this route or not
 	// during setHeaderRoute step
 	UseHeaderRoutes bool `json:"useHeaderRoutes,omitempty"`
+	// ExperimentPort selects the Service port of experiment backends added to this route.
+	// The port of the canary backendRef is used when it is not set
+	ExperimentPort *ExperimentPort `json:"experimentPort,omitempty"`
+	// RuleNames refer to the names of the rules whose weights and header routes the plugin
+	// changes. Every rule referencing the stable and canary services is changed when neither
+	// RuleNames nor RuleIndexes is set
+	RuleNames []string `json:"ruleNames,omitempty"`
+	// RuleIndexes refer to the indexes of the rules the plugin changes, on top of the ones
+	// named in RuleNames
+	RuleIndexes []int `json:"ruleIndexes,omitempty"`
 }
 
 type TCPRoute struct {
 	// Name refers to the TCPRoute name
 	Name string `json:"name" validate:"required"`
+	// Namespace refers to the namespace of the TCPRoute, which overrides
+	// GatewayAPITrafficRouting.Namespace
+	Namespace string `json:"namespace,omitempty"`
 	// UseHeaderRoutes indicates header routes will be added to this route or not
 	// during setHeaderRoute step
 	UseHeaderRoutes bool `json:"useHeaderRoutes"`
+	// ExperimentPort selects the Service port of experiment backends added to this route.
+	// The port of the canary backendRef is used when it is not set
+	ExperimentPort *ExperimentPort `json:"experimentPort,omitempty"`
 }
 
 type GRPCRoute struct {
 	// Name refers to the GRPCRoute name
 	Name string `json:"name" validate:"required"`
+	// Namespace refers to the namespace of the GRPCRoute, which overrides
+	// GatewayAPITrafficRouting.Namespace
+	Namespace string `json:"namespace,omitempty"`
 	// UseHeaderRoutes indicates header routes will be added to this route or not
 	// during setHeaderRoute step
 	UseHeaderRoutes bool `json:"useHeaderRoutes"`
+	// ExperimentPort selects the Service port of experiment backends added to this route.
+	// The port of the canary backendRef is used when it is not set
+	ExperimentPort *ExperimentPort `json:"experimentPort,omitempty"`
+	// RuleNames refer to the names of the rules whose weights and header routes the plugin
+	// changes. Every rule referencing the stable and canary services is changed when neither
+	// RuleNames nor RuleIndexes is set
+	RuleNames []string `json:"ruleNames,omitempty"`
+	// RuleIndexes refer to the indexes of the rules the plugin changes, on top of the ones
+	// named in RuleNames
+	RuleIndexes []int `json:"ruleIndexes,omitempty"`
 }
 
 type TLSRoute struct {
 	// Name refers to the TLSRoute name
 	Name string `json:"name" validate:"required"`
+	// Namespace refers to the namespace of the TLSRoute, which overrides
+	// GatewayAPITrafficRouting.Namespace
+	Namespace string `json:"namespace,omitempty"`
+	// UseHeaderRoutes indicates header routes will be added to this route or not
+	// during setHeaderRoute step
+	UseHeaderRoutes bool `json:"useHeaderRoutes"`
+	// ExperimentPort selects the Service port of experiment backends added to this route.
+	// The port of the canary backendRef is used when it is not set
+	ExperimentPort *ExperimentPort `json:"experimentPort,omitempty"`
+}
+
+type UDPRoute struct {
+	// Name refers to the UDPRoute name
+	Name string `json:"name" validate:"required"`
+	// Namespace refers to the namespace of the UDPRoute, which overrides
+	// GatewayAPITrafficRouting.Namespace
+	Namespace string `json:"namespace,omitempty"`
 	// UseHeaderRoutes indicates header routes will be added to this route or not
 	// during setHeaderRoute step
 	UseHeaderRoutes bool `json:"useHeaderRoutes"`
@@ -104,6 +286,8 @@ type TCPRouteRule v1alpha2.TCPRouteRule
 
 type TLSRouteRule v1alpha2.TLSRouteRule
 
+type UDPRouteRule v1alpha2.UDPRouteRule
+
 type HTTPRouteRuleList []gatewayv1.HTTPRouteRule
 
 type GRPCRouteRuleList []gatewayv1.GRPCRouteRule
@@ -112,6 +296,8 @@ type TCPRouteRuleList []v1alpha2.TCPRouteRule
 
 type TLSRouteRuleList []v1alpha2.TLSRouteRule
 
+type UDPRouteRuleList []v1alpha2.UDPRouteRule
+
 type HTTPBackendRef gatewayv1.HTTPBackendRef
 
 type GRPCBackendRef gatewayv1.GRPCBackendRef
@@ -120,25 +306,29 @@ type TCPBackendRef gatewayv1.BackendRef
 
 type TLSBackendRef gatewayv1.BackendRef
 
+type UDPBackendRef gatewayv1.BackendRef
+
 type GatewayAPIRoute interface {
-	HTTPRoute | GRPCRoute | TCPRoute | TLSRoute
+	HTTPRoute | GRPCRoute | TCPRoute | TLSRoute | UDPRoute
 	GetName() string
+	GetNamespace() string
 }
 
 type GatewayAPIRouteRule[T1 GatewayAPIBackendRef] interface {
-	*HTTPRouteRule | *GRPCRouteRule | *TCPRouteRule | *TLSRouteRule
+	*HTTPRouteRule | *GRPCRouteRule | *TCPRouteRule | *TLSRouteRule | *UDPRouteRule
 	Iterator() (GatewayAPIRouteRuleIterator[T1], bool)
 }
 
 type GatewayAPIRouteRuleList[T1 GatewayAPIBackendRef, T2 GatewayAPIRouteRule[T1]] interface {
-	HTTPRouteRuleList | GRPCRouteRuleList | TCPRouteRuleList | TLSRouteRuleList
+	HTTPRouteRuleList | GRPCRouteRuleList | TCPRouteRuleList | TLSRouteRuleList | UDPRouteRuleList
 	Iterator() (GatewayAPIRouteRuleListIterator[T1, T2], bool)
 	Error() error
 }
 
 type GatewayAPIBackendRef interface {
-	*HTTPBackendRef | *GRPCBackendRef | *TCPBackendRef | *TLSBackendRef
+	*HTTPBackendRef | *GRPCBackendRef | *TCPBackendRef | *TLSBackendRef | *UDPBackendRef
 	GetName() string
+	GetBackendObjectReference() gatewayv1.BackendObjectReference
 }
 
 type GatewayAPIRouteRuleListIterator[T1 GatewayAPIBackendRef, T2 GatewayAPIRouteRule[T1]] func() (T2, bool)
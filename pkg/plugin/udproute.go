@@ -0,0 +1,189 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+// setUDPRouteWeight sets the canary and stable weights of udpRoute. Experiment services are
+// never added to UDPRoutes, so the stable service gets the whole weight the canary does not.
+func (r *RpcPlugin) setUDPRouteWeight(rollout *v1alpha1.Rollout, desiredWeight int32, target routeTarget, gatewayAPIConfig *GatewayAPITrafficRouting) error {
+	ctx := context.TODO()
+	udpRouteClient := r.GatewayAPIClientset.GatewayV1alpha2().UDPRoutes(target.namespace)
+
+	canaryMatcher := gatewayAPIConfig.getCanaryBackendRefMatcher(rollout, target.namespace)
+	stableMatcher := gatewayAPIConfig.getStableBackendRefMatcher(rollout, target.namespace)
+	canaryServiceName := canaryMatcher.name
+	stableServiceName := stableMatcher.name
+	ownedMatchers := gatewayAPIConfig.getOwnedBackendRefMatchers(rollout, target.namespace, nil)
+	if rollout.Status.Canary.CurrentExperiment != "" {
+		r.LogCtx.Warn(fmt.Sprintf("[SetWeight] UDPRoute %q does not support experiment services, so experiment %q receives none of its traffic", gatewayAPIConfig.UDPRoute, rollout.Status.Canary.CurrentExperiment))
+	}
+
+	err := retryOnConflict(udpRouteGVK, func(useCache bool) error {
+		udpRoute, err := r.getUDPRoute(ctx, target.namespace, gatewayAPIConfig.UDPRoute, useCache)
+		if err != nil {
+			return err
+		}
+		originalSpec := udpRoute.Spec.DeepCopy()
+
+		isRestored, err := syncRouteSpecSnapshot(r, udpRoute, &udpRoute.Spec, rollout, desiredWeight, gatewayAPIConfig)
+		if err != nil {
+			return err
+		}
+		if isRestored {
+			r.LogCtx.Info(fmt.Sprintf("[SetWeight] restoring original spec of UDPRoute %q", udpRoute.Name))
+			ensureInProgressLabel(udpRoute, desiredWeight, gatewayAPIConfig)
+			if r.isDryRun(gatewayAPIConfig) {
+				return r.logDryRun(udpRouteGVK, udpRoute, originalSpec, &udpRoute.Spec)
+			}
+			if err = updateRoute(ctx, udpRouteClient, udpRoute, udpRoute.Spec.Rules, udpRouteGVK, gatewayAPIConfig); err != nil {
+				return err
+			}
+			r.recordEvent(udpRoute, corev1.EventTypeNormal, OriginalSpecRestoredReason, "Restored the spec saved before the canary started")
+			return nil
+		}
+
+		canaryFound, stableFound := false, false
+		var allocation weightAllocation
+		for i := range udpRoute.Spec.Rules {
+			backendRefs := udpRoute.Spec.Rules[i].BackendRefs
+			if !hasRolloutBackendRef[*UDPBackendRef]((*UDPRouteRule)(&udpRoute.Spec.Rules[i]), canaryMatcher, stableMatcher) {
+				continue
+			}
+			allocation, err = allocateWeights(desiredWeight, nil, getUnmanagedWeight(backendRefs, getExperimentBackendRef, ownedMatchers))
+			if err != nil {
+				return err
+			}
+			r.warnUnmanagedWeight(udpRouteGVK.Kind, udpRoute.Name, i, allocation)
+			canaryWeight, stableWeight := allocation.canaryWeight, allocation.stableWeight
+			for j := range backendRefs {
+				switch {
+				case canaryMatcher.matches(backendRefs[j].BackendObjectReference):
+					backendRefs[j].Weight = &canaryWeight
+					canaryFound = true
+				case stableMatcher.matches(backendRefs[j].BackendObjectReference):
+					backendRefs[j].Weight = &stableWeight
+					stableFound = true
+				}
+			}
+		}
+		if !canaryFound || !stableFound {
+			return newPluginError("BackendRefWasNotFoundInUDPRouteError", BackendRefWasNotFoundInUDPRouteError)
+		}
+
+		ensureInProgressLabel(udpRoute, desiredWeight, gatewayAPIConfig)
+
+		if r.isDryRun(gatewayAPIConfig) {
+			return r.logDryRun(udpRouteGVK, udpRoute, originalSpec, &udpRoute.Spec)
+		}
+		if err = updateRoute(ctx, udpRouteClient, udpRoute, udpRoute.Spec.Rules, udpRouteGVK, gatewayAPIConfig); err != nil {
+			return err
+		}
+		if !equality.Semantic.DeepEqual(originalSpec.Rules, udpRoute.Spec.Rules) {
+			r.recordEvent(udpRoute, corev1.EventTypeNormal, WeightUpdatedReason, "Set weight of canary service %q to %d and stable service %q to %d", canaryServiceName, allocation.canaryWeight, stableServiceName, allocation.stableWeight)
+		}
+		return nil
+	})
+
+	if err != nil {
+		r.recordRouteFailure(udpRouteGVK, target.namespace, gatewayAPIConfig.UDPRoute, "SetWeight", err)
+		return err
+	}
+	r.recordCanaryWeight(rollout, udpRouteGVK.Kind, target.namespace, gatewayAPIConfig.UDPRoute, desiredWeight, gatewayAPIConfig)
+	return nil
+}
+
+func (r *RpcPlugin) verifyUDPRouteWeight(rollout *v1alpha1.Rollout, desiredWeight int32, target routeTarget, gatewayAPIConfig *GatewayAPITrafficRouting) (bool, error) {
+	ctx := context.TODO()
+	allocation, err := allocateWeights(desiredWeight, nil, 0)
+	if err != nil {
+		return false, err
+	}
+
+	udpRoute, err := r.getUDPRoute(ctx, target.namespace, gatewayAPIConfig.UDPRoute, true)
+	if err != nil {
+		return false, err
+	}
+
+	routeRuleList := UDPRouteRuleList(udpRoute.Spec.Rules)
+	canaryBackendRefs, err := getBackendRefs(gatewayAPIConfig.getCanaryBackendRefMatcher(rollout, target.namespace), routeRuleList)
+	if err != nil {
+		return false, err
+	}
+	for _, ref := range canaryBackendRefs {
+		if !isWeightEqual(ref.Weight, desiredWeight) {
+			r.LogCtx.Info(fmt.Sprintf("[VerifyWeight] UDPRoute %q canary weight is not %d yet", udpRoute.Name, desiredWeight))
+			return false, nil
+		}
+	}
+	stableBackendRefs, err := getBackendRefs(gatewayAPIConfig.getStableBackendRefMatcher(rollout, target.namespace), routeRuleList)
+	if err != nil {
+		return false, err
+	}
+	for _, ref := range stableBackendRefs {
+		if !isWeightEqual(ref.Weight, allocation.stableWeight) {
+			r.LogCtx.Info(fmt.Sprintf("[VerifyWeight] UDPRoute %q stable weight is not %d yet", udpRoute.Name, allocation.stableWeight))
+			return false, nil
+		}
+	}
+
+	if isAccepted, reason := isRouteAccepted(udpRoute.Generation, udpRoute.Status.RouteStatus); !isAccepted {
+		r.LogCtx.Info(fmt.Sprintf("[VerifyWeight] UDPRoute %q is not accepted yet: %s", udpRoute.Name, reason))
+		return false, nil
+	}
+	return true, nil
+}
+
+func (r *UDPRouteRule) Iterator() (GatewayAPIRouteRuleIterator[*UDPBackendRef], bool) {
+	backendRefList := r.BackendRefs
+	index := 0
+	next := func() (*UDPBackendRef, bool) {
+		if len(backendRefList) == index {
+			return nil, false
+		}
+		backendRef := (*UDPBackendRef)(&backendRefList[index])
+		index = index + 1
+		return backendRef, len(backendRefList) > index
+	}
+	return next, len(backendRefList) > index
+}
+
+func (r UDPRouteRuleList) Iterator() (GatewayAPIRouteRuleListIterator[*UDPBackendRef, *UDPRouteRule], bool) {
+	routeRuleList := r
+	index := 0
+	next := func() (*UDPRouteRule, bool) {
+		if len(routeRuleList) == index {
+			return nil, false
+		}
+		routeRule := (*UDPRouteRule)(&routeRuleList[index])
+		index = index + 1
+		return routeRule, len(routeRuleList) > index
+	}
+	return next, len(routeRuleList) > index
+}
+
+func (r UDPRouteRuleList) Error() error {
+	return newPluginError("BackendRefListWasNotFoundInUDPRouteError", BackendRefListWasNotFoundInUDPRouteError)
+}
+
+func (r *UDPBackendRef) GetName() string {
+	return string(r.Name)
+}
+
+func (r *UDPBackendRef) GetBackendObjectReference() gatewayv1.BackendObjectReference {
+	return r.BackendObjectReference
+}
+
+func (r UDPRoute) GetName() string {
+	return r.Name
+}
+
+func (r UDPRoute) GetNamespace() string {
+	return r.Namespace
+}
@@ -0,0 +1,87 @@
+package plugin
+
+import (
+	"fmt"
+
+	"github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+// weightAllocation holds the weights the plugin writes to the backends of a rule.
+type weightAllocation struct {
+	canaryWeight int32
+	stableWeight int32
+	// unmanagedWeight is the total weight of the backends the plugin does not own, which
+	// keep their weight
+	unmanagedWeight int32
+}
+
+// allocateWeights splits 100 between the canary service, the experiment services of
+// additionalDestinations and the stable service, which gets whatever is left. Backends the
+// plugin does not own keep unmanagedWeight, so the weights are relative to 100 plus
+// unmanagedWeight. Weights outside of 0-100 and canary and experiment weights adding up
+// to more than 100 are rejected. Weights are only allocated for the rules of a route that
+// reference the stable or canary service, every other rule keeps its weights.
+func allocateWeights(desiredWeight int32, additionalDestinations []v1alpha1.WeightDestination, unmanagedWeight int32) (weightAllocation, error) {
+	if desiredWeight < 0 || desiredWeight > 100 {
+		return weightAllocation{}, newPluginError("InvalidWeightError", InvalidWeightError, desiredWeight, "canary")
+	}
+	allocation := weightAllocation{
+		canaryWeight:    desiredWeight,
+		unmanagedWeight: unmanagedWeight,
+	}
+	allocatedWeight := desiredWeight
+	for _, destination := range additionalDestinations {
+		if destination.Weight < 0 || destination.Weight > 100 {
+			return weightAllocation{}, newPluginError("InvalidWeightError", InvalidWeightError, destination.Weight, destination.ServiceName)
+		}
+		allocatedWeight += destination.Weight
+	}
+	if allocatedWeight > 100 {
+		return weightAllocation{}, newPluginError("WeightOverAllocationError", WeightOverAllocationError, desiredWeight, allocatedWeight-desiredWeight)
+	}
+	allocation.stableWeight = 100 - allocatedWeight
+	return allocation, nil
+}
+
+// canaryTrafficShare returns the percentage of the requests the canary service receives,
+// which is lower than its weight when unmanaged backends have a weight too.
+func (a weightAllocation) canaryTrafficShare() float64 {
+	return float64(a.canaryWeight) * 100 / float64(100+a.unmanagedWeight)
+}
+
+// warnUnmanagedWeight warns when backends the plugin does not own take part of the
+// traffic of a rule, so that the canary service receives less than its weight.
+func (r *RpcPlugin) warnUnmanagedWeight(kind, name string, ruleIndex int, allocation weightAllocation) {
+	if allocation.unmanagedWeight == 0 {
+		return
+	}
+	r.LogCtx.Warn(fmt.Sprintf("[SetWeight] backends not managed by the plugin have a weight of %d in rule %d of %s %q, so the canary service receives %.1f%% of the requests instead of %d%%", allocation.unmanagedWeight, ruleIndex, kind, name, allocation.canaryTrafficShare(), allocation.canaryWeight))
+}
+
+// getUnmanagedWeight returns the total weight of the backendRefs that none of ownedMatchers
+// matches. A backendRef without a weight has the Gateway API default weight of 1.
+func getUnmanagedWeight[T any](backendRefs []T, getBackendRef func(*T) *gatewayv1.BackendRef, ownedMatchers []backendRefMatcher) int32 {
+	unmanagedWeight := int32(0)
+	for i := range backendRefs {
+		backendRef := getBackendRef(&backendRefs[i])
+		if matchesAny(ownedMatchers, backendRef.BackendObjectReference) {
+			continue
+		}
+		if backendRef.Weight == nil {
+			unmanagedWeight++
+			continue
+		}
+		unmanagedWeight += *backendRef.Weight
+	}
+	return unmanagedWeight
+}
+
+// getExperimentDestinations returns additionalDestinations while an experiment of rollout
+// is active, since experiment services are only added to routes in that case.
+func getExperimentDestinations(rollout *v1alpha1.Rollout, additionalDestinations []v1alpha1.WeightDestination) []v1alpha1.WeightDestination {
+	if rollout.Status.Canary.CurrentExperiment == "" {
+		return nil
+	}
+	return additionalDestinations
+}
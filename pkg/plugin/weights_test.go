@@ -0,0 +1,76 @@
+package plugin
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+func TestAllocateWeights(t *testing.T) {
+	t.Run("WithoutExperiment", func(t *testing.T) {
+		allocation, err := allocateWeights(30, nil, 0)
+		require.NoError(t, err)
+		assert.Equal(t, int32(30), allocation.canaryWeight)
+		assert.Equal(t, int32(70), allocation.stableWeight)
+		assert.Equal(t, float64(30), allocation.canaryTrafficShare())
+	})
+	t.Run("WithExperiment", func(t *testing.T) {
+		allocation, err := allocateWeights(20, []v1alpha1.WeightDestination{
+			{ServiceName: "exp-svc-1", Weight: 10},
+			{ServiceName: "exp-svc-2", Weight: 15},
+		}, 0)
+		require.NoError(t, err)
+		assert.Equal(t, int32(20), allocation.canaryWeight)
+		assert.Equal(t, int32(55), allocation.stableWeight)
+	})
+	t.Run("WithUnmanagedBackends", func(t *testing.T) {
+		allocation, err := allocateWeights(50, nil, 100)
+		require.NoError(t, err)
+		assert.Equal(t, int32(50), allocation.canaryWeight)
+		assert.Equal(t, int32(50), allocation.stableWeight)
+		assert.Equal(t, float64(25), allocation.canaryTrafficShare())
+	})
+	t.Run("RejectsOverAllocation", func(t *testing.T) {
+		_, err := allocateWeights(60, []v1alpha1.WeightDestination{
+			{ServiceName: "exp-svc-1", Weight: 50},
+		}, 0)
+		assert.EqualError(t, err, fmt.Sprintf(WeightOverAllocationError, 60, 50))
+	})
+	t.Run("RejectsInvalidWeight", func(t *testing.T) {
+		_, err := allocateWeights(101, nil, 0)
+		assert.EqualError(t, err, fmt.Sprintf(InvalidWeightError, 101, "canary"))
+		_, err = allocateWeights(10, []v1alpha1.WeightDestination{
+			{ServiceName: "exp-svc-1", Weight: -1},
+		}, 0)
+		assert.EqualError(t, err, fmt.Sprintf(InvalidWeightError, -1, "exp-svc-1"))
+	})
+}
+
+func TestGetUnmanagedWeight(t *testing.T) {
+	weight := int32(30)
+	backendRefs := []gatewayv1.BackendRef{
+		{BackendObjectReference: gatewayv1.BackendObjectReference{Name: "stable-svc"}, Weight: &weight},
+		{BackendObjectReference: gatewayv1.BackendObjectReference{Name: "canary-svc"}, Weight: &weight},
+		{BackendObjectReference: gatewayv1.BackendObjectReference{Name: "external-svc"}, Weight: &weight},
+		{BackendObjectReference: gatewayv1.BackendObjectReference{Name: "default-weight-svc"}},
+	}
+	ownedMatchers := []backendRefMatcher{
+		newBackendRefMatcher("stable-svc", nil, "default", "default"),
+		newBackendRefMatcher("canary-svc", nil, "default", "default"),
+	}
+	assert.Equal(t, int32(31), getUnmanagedWeight(backendRefs, getExperimentBackendRef, ownedMatchers))
+
+	// Backends with an owned name but another kind or namespace are not owned
+	serviceImportGroup := gatewayv1.Group(backendKindGroups[serviceImportKind])
+	importKind := gatewayv1.Kind(serviceImportKind)
+	otherNamespace := gatewayv1.Namespace("other")
+	backendRefs = append(backendRefs,
+		gatewayv1.BackendRef{BackendObjectReference: gatewayv1.BackendObjectReference{Name: "canary-svc", Group: &serviceImportGroup, Kind: &importKind}, Weight: &weight},
+		gatewayv1.BackendRef{BackendObjectReference: gatewayv1.BackendObjectReference{Name: "stable-svc", Namespace: &otherNamespace}, Weight: &weight},
+	)
+	assert.Equal(t, int32(91), getUnmanagedWeight(backendRefs, getExperimentBackendRef, ownedMatchers))
+}